@@ -0,0 +1,1033 @@
+package surrender
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// Indefinite is used for durations that never end, as given by "indefinite" in an animation element
+const Indefinite = time.Duration(math.MaxInt64)
+
+// SvgAnimation struct, for the SMIL animation elements: animate, set, animateColor, animateTransform and animateMotion
+type SvgAnimation struct {
+	Tag           string          // the name of the animation element
+	Target        string          // the id of the element to animate, or "" for the parent element
+	AttributeName string          //
+	Type          string          // for animateTransform: "translate", "scale", "rotate", "skewX" or "skewY"
+	Begin         []time.Duration // the begin times, which is empty if the animation never begins
+	Dur           time.Duration   // the simple duration, or Indefinite
+	End           []time.Duration // the end times, which is empty if only the duration limits the animation
+	RepeatCount   float64         // 0 if not set, or math.Inf(1) for "indefinite"
+	RepeatDur     time.Duration   // 0 if not set, or Indefinite
+	Fill          string          // "remove" or "freeze"
+	CalcMode      string          // "discrete", "linear", "paced" or "spline"
+	Values        []string        //
+	From, To, By  string          //
+	KeyTimes      []float64       //
+	KeySplines    [][4]float64    //
+	Additive      string          // "replace" or "sum"
+	Accumulate    string          // "none" or "sum"
+	Path          *SvgPath        // for animateMotion, the path attribute, or nil
+	MPath         string          // for animateMotion, the id of the path in an mpath child element
+	KeyPoints     []float64       // for animateMotion, the distances along the path for each of the key times
+	Rotate        string          // for animateMotion: "auto", "auto-reverse" or an angle in degrees
+}
+
+// Color returns nil, since animation elements are not drawn
+func (a SvgAnimation) Color() color.Color {
+	return nil
+}
+
+// Draw does nothing, since animation elements are not drawn
+func (a SvgAnimation) Draw(img draw.Image, clr color.Color) {}
+
+// isAnimationTag checks if the given tag is one of the SMIL animation elements
+func isAnimationTag(tag string) bool {
+	switch tag {
+	case "animate", "set", "animateColor", "animateTransform", "animateMotion":
+		return true
+	}
+	return false
+}
+
+// parseAnimations parses the animation elements among the given elements, and skips the ones that are in error
+func (p *parser) parseAnimations(elements []*etree.Element) []SvgAnimation {
+	var animations []SvgAnimation
+	for _, el := range elements {
+		if !isAnimationTag(el.Tag) {
+			continue
+		}
+		if animation, ok := p.parseAnimation(el); ok {
+			animations = append(animations, animation)
+		}
+	}
+	return animations
+}
+
+// parseAnimation parses a SMIL animation element. It returns false if the element is in error.
+func (p *parser) parseAnimation(el *etree.Element) (SvgAnimation, bool) {
+	a := SvgAnimation{
+		Tag:           el.Tag,
+		Target:        strings.TrimPrefix(el.SelectAttrValue("xlink:href", el.SelectAttrValue("href", "")), "#"),
+		AttributeName: el.SelectAttrValue("attributeName", ""),
+		Type:          el.SelectAttrValue("type", "translate"),
+		Fill:          el.SelectAttrValue("fill", "remove"),
+		CalcMode:      el.SelectAttrValue("calcMode", ""),
+		From:          el.SelectAttrValue("from", ""),
+		To:            el.SelectAttrValue("to", ""),
+		By:            el.SelectAttrValue("by", ""),
+		Additive:      el.SelectAttrValue("additive", "replace"),
+		Accumulate:    el.SelectAttrValue("accumulate", "none"),
+		Rotate:        el.SelectAttrValue("rotate", "0"),
+	}
+	fail := func(name string, err error) (SvgAnimation, bool) {
+		p.report(SeverityError, el, name, err)
+		return a, false
+	}
+	var err error
+	if a.Begin, err = parseTimeList(el.SelectAttrValue("begin", "0s")); err != nil {
+		return fail("begin", err)
+	}
+	if a.End, err = parseTimeList(el.SelectAttrValue("end", "indefinite")); err != nil {
+		return fail("end", err)
+	}
+	if a.Dur, err = parseDuration(el.SelectAttrValue("dur", "indefinite")); err != nil {
+		return fail("dur", err)
+	}
+	if a.RepeatDur, err = parseDuration(el.SelectAttrValue("repeatDur", "")); err != nil {
+		return fail("repeatDur", err)
+	}
+	switch rc := strings.TrimSpace(el.SelectAttrValue("repeatCount", "")); rc {
+	case "":
+	case "indefinite":
+		a.RepeatCount = math.Inf(1)
+	default:
+		if a.RepeatCount, err = strconv.ParseFloat(rc, 64); err != nil || a.RepeatCount <= 0 {
+			return fail("repeatCount", fmt.Errorf("invalid repeatCount: %q", rc))
+		}
+	}
+	switch a.Rotate = strings.TrimSpace(a.Rotate); a.Rotate {
+	case "auto", "auto-reverse":
+	default:
+		if _, err := strconv.ParseFloat(a.Rotate, 64); err != nil {
+			return fail("rotate", fmt.Errorf("invalid rotate: %q", a.Rotate))
+		}
+	}
+	if values := el.SelectAttr("values"); values != nil {
+		a.Values = splitList(values.Value)
+	}
+	if a.KeyTimes, err = parseFloatList(el.SelectAttrValue("keyTimes", "")); err != nil {
+		return fail("keyTimes", fmt.Errorf("invalid keyTimes: %w", err))
+	}
+	if a.KeyPoints, err = parseFloatList(el.SelectAttrValue("keyPoints", "")); err != nil {
+		return fail("keyPoints", fmt.Errorf("invalid keyPoints: %w", err))
+	}
+	for _, spline := range splitList(el.SelectAttrValue("keySplines", "")) {
+		numbers, err := parseNumberList(spline)
+		if err != nil || len(numbers) != 4 {
+			return fail("keySplines", fmt.Errorf("invalid keySplines: %q", spline))
+		}
+		a.KeySplines = append(a.KeySplines, [4]float64{numbers[0], numbers[1], numbers[2], numbers[3]})
+	}
+	if d := el.SelectAttr("path"); d != nil {
+		path, err := ParsePath(d.Value)
+		if err != nil {
+			return fail("path", err)
+		}
+		a.Path = &path
+	}
+	if mpath := el.SelectElement("mpath"); mpath != nil {
+		a.MPath = strings.TrimPrefix(mpath.SelectAttrValue("xlink:href", mpath.SelectAttrValue("href", "")), "#")
+	}
+	return a, true
+}
+
+// splitList splits a semicolon separated list, and drops empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// parseFloatList parses a semicolon separated list of numbers
+func parseFloatList(s string) ([]float64, error) {
+	var numbers []float64
+	for _, v := range splitList(s) {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, f)
+	}
+	return numbers, nil
+}
+
+// parseClockValue parses a SMIL clock value, like "02:30:03", "02:33", "10.5s", "200ms", "2min" or "1h"
+func parseClockValue(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	seconds := 0.0
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("invalid clock value: %q", s)
+		}
+		for _, part := range parts {
+			v, err := strconv.ParseFloat(part, 64)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid clock value: %q", s)
+			}
+			seconds = seconds*60 + v
+		}
+	} else {
+		unit := 1.0
+		for _, metric := range []struct {
+			suffix string
+			scale  float64
+		}{{"ms", 0.001}, {"min", 60}, {"h", 3600}, {"s", 1}} {
+			if strings.HasSuffix(s, metric.suffix) {
+				s, unit = strings.TrimSuffix(s, metric.suffix), metric.scale
+				break
+			}
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid clock value: %q", s)
+		}
+		seconds = v * unit
+	}
+	return time.Duration(math.Round(seconds * float64(time.Second))), nil
+}
+
+// parseDuration parses a dur or repeatDur attribute, where "" means that it is not set
+func parseDuration(s string) (time.Duration, error) {
+	switch s = strings.TrimSpace(s); s {
+	case "":
+		return 0, nil
+	case "indefinite", "media":
+		return Indefinite, nil
+	}
+	d, err := parseClockValue(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		// A duration of zero is invalid and is treated as if it was not set
+		return Indefinite, nil
+	}
+	return d, nil
+}
+
+// parseTimeList parses a begin or end attribute. Only offset values are supported, since the
+// animation is sampled at given times. Other kinds of values, like events, are skipped.
+func parseTimeList(s string) ([]time.Duration, error) {
+	var times []time.Duration
+	for _, v := range splitList(s) {
+		if v == "indefinite" {
+			continue
+		}
+		sign := time.Duration(1)
+		offset := v
+		switch {
+		case strings.HasPrefix(offset, "-"):
+			sign, offset = -1, offset[1:]
+		case strings.HasPrefix(offset, "+"):
+			offset = offset[1:]
+		}
+		if offset == "" || !(offset[0] >= '0' && offset[0] <= '9' || offset[0] == '.') {
+			// Event, syncbase, accessKey and wallclock values can not be sampled
+			continue
+		}
+		d, err := parseClockValue(offset)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, sign*d)
+	}
+	return times, nil
+}
+
+// seconds converts a duration to seconds, where Indefinite becomes +Inf
+func seconds(d time.Duration) float64 {
+	if d == Indefinite {
+		return math.Inf(1)
+	}
+	return d.Seconds()
+}
+
+// beginAt returns the begin time of the interval that is current at time t, in seconds,
+// and false if the animation has not begun at time t
+func (a SvgAnimation) beginAt(t float64) (float64, bool) {
+	begin, found := math.Inf(-1), false
+	for _, b := range a.Begin {
+		if bs := b.Seconds(); bs <= t && bs > begin {
+			begin, found = bs, true
+		}
+	}
+	return begin, found
+}
+
+// progressAt returns how far into the simple duration the animation is at time t, from 0 to 1,
+// and which repetition it is in. It returns false when the animation has no effect at time t.
+func (a SvgAnimation) progressAt(t float64) (float64, int, bool) {
+	begin, ok := a.beginAt(t)
+	if !ok {
+		return 0, 0, false
+	}
+	dur := math.Inf(1)
+	if a.Dur > 0 {
+		dur = seconds(a.Dur)
+	}
+
+	// Find the active duration, from the simple duration, repeatCount and repeatDur
+	active := dur
+	if a.RepeatCount > 0 || a.RepeatDur > 0 {
+		active = math.Inf(1)
+		if a.RepeatCount > 0 {
+			active = dur * a.RepeatCount
+		}
+		if a.RepeatDur > 0 {
+			active = math.Min(active, seconds(a.RepeatDur))
+		}
+	}
+	activeEnd := begin + active
+	for _, e := range a.End {
+		if es := e.Seconds(); es > begin && es < activeEnd {
+			activeEnd = es
+		}
+	}
+
+	elapsed := t - begin
+	if t >= activeEnd {
+		if a.Fill != "freeze" {
+			return 0, 0, false
+		}
+		elapsed = activeEnd - begin
+	}
+	if math.IsInf(dur, 1) {
+		return 0, 0, true
+	}
+	iteration := math.Floor(elapsed / dur)
+	progress := (elapsed - iteration*dur) / dur
+	if t >= activeEnd && progress == 0 && iteration > 0 {
+		// A frozen animation that ends at the end of a repetition keeps the last value
+		iteration--
+		progress = 1
+	}
+	return progress, int(iteration), true
+}
+
+// calcMode returns the interpolation mode, where the default depends on the animation element
+func (a SvgAnimation) calcMode() string {
+	if a.CalcMode != "" {
+		return a.CalcMode
+	}
+	if a.Tag == "animateMotion" {
+		return "paced"
+	}
+	return "linear"
+}
+
+// animKind is the kind of value that an animated attribute has, which decides how it is interpolated
+type animKind int
+
+const (
+	kindString animKind = iota
+	kindNumber
+	kindColor
+	kindPath
+	kindTransform
+	kindPoint
+)
+
+// attributeKind returns the kind of value that the named attribute has
+func attributeKind(name string) animKind {
+	switch name {
+	case "fill", "stroke", "color", "stop-color", "solid-color", "viewport-fill":
+		return kindColor
+	case "cx", "cy", "r", "rx", "ry", "x", "y", "x1", "y1", "x2", "y2", "width", "height",
+		"opacity", "fill-opacity", "stroke-opacity", "stroke-width", "font-size", "line-increment":
+		return kindNumber
+	case "d":
+		return kindPath
+	}
+	return kindString
+}
+
+// vector is an animated value that can be interpolated, with a template for formatting it again
+type vector struct {
+	v        []float64
+	template []PathCommand // the path structure, for path values
+}
+
+// parseVector parses an attribute value as a list of numbers that can be interpolated,
+// and returns false if the value can not be interpolated
+func parseVector(kind animKind, transformType, s string) (vector, bool) {
+	s = strings.TrimSpace(s)
+	switch kind {
+	case kindNumber:
+		f, err := strconv.ParseFloat(strings.TrimSuffix(s, "px"), 64)
+		return vector{v: []float64{f}}, err == nil
+	case kindColor:
+		switch s {
+		case "", "none", "inherit", "currentColor":
+			return vector{}, false
+		}
+		c := color.RGBAModel.Convert(GetColor(s)).(color.RGBA)
+		return vector{v: []float64{float64(c.R), float64(c.G), float64(c.B)}}, true
+	case kindPath:
+		path, err := ParsePath(s)
+		if err != nil {
+			return vector{}, false
+		}
+		var v []float64
+		for _, command := range path.Commands {
+			for _, p := range command.Points {
+				v = append(v, float64(p.X), float64(p.Y))
+			}
+		}
+		return vector{v: v, template: path.Commands}, true
+	case kindTransform:
+		numbers, err := parseNumberList(s)
+		if err != nil || len(numbers) == 0 {
+			return vector{}, false
+		}
+		// Fill in the optional arguments, so that all values have the same length
+		switch transformType {
+		case "translate":
+			if len(numbers) == 1 {
+				numbers = append(numbers, 0)
+			}
+		case "scale":
+			if len(numbers) == 1 {
+				numbers = append(numbers, numbers[0])
+			}
+		case "rotate":
+			if len(numbers) == 1 {
+				numbers = append(numbers, 0, 0)
+			}
+		}
+		return vector{v: numbers}, true
+	case kindPoint:
+		numbers, err := parseNumberList(s)
+		if err != nil || len(numbers) != 2 {
+			return vector{}, false
+		}
+		return vector{v: numbers}, true
+	}
+	return vector{}, false
+}
+
+// compatible checks if two vectors can be interpolated
+func (v vector) compatible(w vector) bool {
+	if len(v.v) != len(w.v) || len(v.template) != len(w.template) {
+		return false
+	}
+	for i := range v.template {
+		if v.template[i].Type != w.template[i].Type || len(v.template[i].Points) != len(w.template[i].Points) {
+			return false
+		}
+	}
+	return true
+}
+
+// add returns v + scale * w
+func (v vector) add(w vector, scale float64) vector {
+	sum := vector{v: make([]float64, len(v.v)), template: v.template}
+	for i := range v.v {
+		sum.v[i] = v.v[i] + scale*w.v[i]
+	}
+	return sum
+}
+
+// distance returns the distance between two vectors, for paced animations
+func (v vector) distance(w vector) float64 {
+	d := 0.0
+	for i := range v.v {
+		d += (v.v[i] - w.v[i]) * (v.v[i] - w.v[i])
+	}
+	return math.Sqrt(d)
+}
+
+// format formats a vector as an attribute value
+func (v vector) format(kind animKind) string {
+	switch kind {
+	case kindColor:
+		c := [3]uint8{}
+		for i := range c {
+			c[i] = uint8(math.Max(0, math.Min(255, math.Round(v.v[i]))))
+		}
+		return fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2])
+	case kindPath:
+		commands := make([]PathCommand, len(v.template))
+		i := 0
+		for j, command := range v.template {
+			commands[j] = PathCommand{Type: command.Type, Points: make([]image.Point, len(command.Points))}
+			for k := range command.Points {
+				commands[j].Points[k] = image.Point{X: roundCoordinate(v.v[i]), Y: roundCoordinate(v.v[i+1])}
+				i += 2
+			}
+		}
+		return pathData(commands)
+	}
+	numbers := make([]string, len(v.v))
+	for i, f := range v.v {
+		numbers[i] = formatNumber(f)
+	}
+	return strings.Join(numbers, " ")
+}
+
+// keyframes returns the animation values as vectors, and whether they are added to the base value.
+// This handles values, from, to and by. It returns false if the values can not be interpolated.
+func (a SvgAnimation) keyframes(kind animKind, base string) ([]vector, bool, bool) {
+	parse := func(s string) (vector, bool) {
+		return parseVector(kind, a.Type, s)
+	}
+	additive := a.Additive == "sum"
+	var frames []vector
+	switch {
+	case len(a.Values) > 0:
+		for _, s := range a.Values {
+			v, ok := parse(s)
+			if !ok {
+				return nil, false, false
+			}
+			frames = append(frames, v)
+		}
+	case a.From != "" && a.To != "":
+		from, ok1 := parse(a.From)
+		to, ok2 := parse(a.To)
+		if !ok1 || !ok2 {
+			return nil, false, false
+		}
+		frames = []vector{from, to}
+	case a.From != "" && a.By != "":
+		from, ok1 := parse(a.From)
+		by, ok2 := parse(a.By)
+		if !ok1 || !ok2 || !from.compatible(by) {
+			return nil, false, false
+		}
+		frames = []vector{from, from.add(by, 1)}
+	case a.By != "":
+		// A by animation goes from zero to the by value, added to the base value
+		by, ok := parse(a.By)
+		if !ok {
+			return nil, false, false
+		}
+		frames, additive = []vector{by.add(by, -1), by}, true
+	case a.To != "":
+		// A to animation goes from the base value to the to value
+		to, ok := parse(a.To)
+		if !ok {
+			return nil, false, false
+		}
+		from, ok := parse(base)
+		if !ok {
+			from = to.add(to, -1)
+		}
+		frames, additive = []vector{from, to}, false
+	default:
+		return nil, false, false
+	}
+	for _, v := range frames[1:] {
+		if !frames[0].compatible(v) {
+			return nil, false, false
+		}
+	}
+	return frames, additive, true
+}
+
+// discreteIndex returns which of n values is used at the given progress, for discrete animations
+func discreteIndex(n int, progress float64, keyTimes []float64) int {
+	if len(keyTimes) == n {
+		i := 0
+		for j, kt := range keyTimes {
+			if kt <= progress {
+				i = j
+			}
+		}
+		return i
+	}
+	i := int(progress * float64(n))
+	if i >= n {
+		i = n - 1
+	}
+	return i
+}
+
+// segmentAt returns which segment between the n values is used at the given progress,
+// and how far into the segment the progress is, from 0 to 1
+func segmentAt(n int, progress float64, keyTimes []float64) (int, float64) {
+	if len(keyTimes) == n {
+		i := 0
+		for i < n-2 && keyTimes[i+1] <= progress {
+			i++
+		}
+		span := keyTimes[i+1] - keyTimes[i]
+		if span <= 0 {
+			return i, 1
+		}
+		return i, math.Max(0, math.Min(1, (progress-keyTimes[i])/span))
+	}
+	s := progress * float64(n-1)
+	i := int(s)
+	if i > n-2 {
+		i = n - 2
+	}
+	return i, s - float64(i)
+}
+
+// splineEase maps the progress through a keySplines Bézier curve that goes from (0, 0) to (1, 1)
+func splineEase(spline [4]float64, progress float64) float64 {
+	x1, y1, x2, y2 := spline[0], spline[1], spline[2], spline[3]
+	bezier := func(a, b, s float64) float64 {
+		ms := 1 - s
+		return 3*ms*ms*s*a + 3*ms*s*s*b + s*s*s
+	}
+	// Find s where x(s) is the progress, with bisection, which is deterministic and stable
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 50; i++ {
+		mid := (lo + hi) / 2
+		if bezier(x1, x2, mid) < progress {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return bezier(y1, y2, (lo+hi)/2)
+}
+
+// interpolate returns the value at the given progress between the animation values
+func (a SvgAnimation) interpolate(frames []vector, progress float64) vector {
+	n := len(frames)
+	if n == 1 {
+		return frames[0]
+	}
+	switch a.calcMode() {
+	case "discrete":
+		return frames[discreteIndex(n, progress, a.KeyTimes)]
+	case "paced":
+		// Move at a constant speed over the distance between all the values
+		total := 0.0
+		for i := 1; i < n; i++ {
+			total += frames[i-1].distance(frames[i])
+		}
+		if total == 0 {
+			return frames[0]
+		}
+		target := progress * total
+		for i := 1; i < n; i++ {
+			d := frames[i-1].distance(frames[i])
+			if target <= d || i == n-1 {
+				if d == 0 {
+					return frames[i]
+				}
+				return frames[i-1].add(frames[i].add(frames[i-1], -1), math.Min(1, target/d))
+			}
+			target -= d
+		}
+	}
+	i, local := segmentAt(n, progress, a.KeyTimes)
+	if a.calcMode() == "spline" && i < len(a.KeySplines) {
+		local = splineEase(a.KeySplines[i], local)
+	}
+	return frames[i].add(frames[i+1].add(frames[i], -1), local)
+}
+
+// valueAt returns the animated value at time t, given the current value of the attribute,
+// and false if the animation has no effect at time t
+func (a SvgAnimation) valueAt(t float64, kind animKind, base string) (string, bool) {
+	progress, iteration, ok := a.progressAt(t)
+	if !ok {
+		return "", false
+	}
+	if a.Tag == "set" {
+		return a.To, true
+	}
+	frames, additive, ok := a.keyframes(kind, base)
+	if !ok || kind == kindString {
+		// Values that can not be interpolated are animated as discrete values
+		values := a.Values
+		if len(values) == 0 {
+			switch {
+			case a.From != "" && a.To != "":
+				values = []string{a.From, a.To}
+			case a.To != "":
+				values = []string{base, a.To}
+			default:
+				return "", false
+			}
+		}
+		return values[discreteIndex(len(values), progress, a.KeyTimes)], true
+	}
+	v := a.interpolate(frames, progress)
+	if a.Accumulate == "sum" && iteration > 0 && !(len(a.Values) == 0 && a.From == "" && a.By == "") {
+		v = v.add(frames[len(frames)-1], float64(iteration))
+	}
+	if additive {
+		if b, ok := parseVector(kind, a.Type, base); ok && b.compatible(v) {
+			v = v.add(b, 1)
+		}
+	}
+	return v.format(kind), true
+}
+
+// transformAt returns the matrix of an animateTransform element at time t, and false if it has no effect
+func (a SvgAnimation) transformAt(t float64) (Matrix, bool) {
+	progress, iteration, ok := a.progressAt(t)
+	if !ok {
+		return Identity, false
+	}
+	frames, _, ok := a.keyframes(kindTransform, "")
+	if !ok {
+		return Identity, false
+	}
+	v := a.interpolate(frames, progress)
+	if a.Accumulate == "sum" && iteration > 0 {
+		v = v.add(frames[len(frames)-1], float64(iteration))
+	}
+	m, err := transformFunction(a.Type, v.v)
+	if err != nil {
+		return Identity, false
+	}
+	return m, true
+}
+
+// motionAt returns the supplemental transformation of an animateMotion element at time t,
+// and false if it has no effect. The path is the motion path, or nil if values, from, to or by are used.
+func (a SvgAnimation) motionAt(t float64, path *SvgPath) (Matrix, bool) {
+	progress, iteration, ok := a.progressAt(t)
+	if !ok {
+		return Identity, false
+	}
+	var pos, last fpoint
+	var angle float64
+	if path != nil {
+		points := polyline(path.outline(flattenTolerance))
+		if len(points) == 0 {
+			return Identity, false
+		}
+		distance := progress
+		if len(a.KeyPoints) > 0 && len(a.KeyPoints) == len(a.KeyTimes) {
+			frames := make([]vector, len(a.KeyPoints))
+			for i, kp := range a.KeyPoints {
+				frames[i] = vector{v: []float64{kp}}
+			}
+			linear := a
+			if linear.CalcMode == "paced" || linear.CalcMode == "" {
+				linear.CalcMode = "linear"
+			}
+			distance = linear.interpolate(frames, progress).v[0]
+		}
+		pos, angle = pointAlong(points, distance)
+		last, _ = pointAlong(points, 1)
+	} else {
+		frames, _, ok := a.keyframes(kindPoint, "0 0")
+		if !ok {
+			return Identity, false
+		}
+		v := a.interpolate(frames, progress)
+		pos = fpoint{v.v[0], v.v[1]}
+		last = fpoint{frames[len(frames)-1].v[0], frames[len(frames)-1].v[1]}
+		// The direction of motion is the direction of the current segment
+		if len(frames) > 1 {
+			i, _ := segmentAt(len(frames), progress, a.KeyTimes)
+			angle = math.Atan2(frames[i+1].v[1]-frames[i].v[1], frames[i+1].v[0]-frames[i].v[0]) * 180 / math.Pi
+		}
+	}
+	if a.Accumulate == "sum" && iteration > 0 {
+		pos.X += float64(iteration) * last.X
+		pos.Y += float64(iteration) * last.Y
+	}
+
+	switch a.Rotate {
+	case "auto":
+	case "auto-reverse":
+		angle += 180
+	default:
+		angle, _ = strconv.ParseFloat(a.Rotate, 64)
+	}
+	return Translate(pos.X, pos.Y).Multiply(Rotate(angle)), true
+}
+
+// polyline joins the subpaths of an outline into one list of points
+func polyline(subpaths [][]fpoint) []fpoint {
+	var points []fpoint
+	for _, subpath := range subpaths {
+		points = append(points, subpath...)
+	}
+	return points
+}
+
+// pointAlong returns the point at the given fraction of the length of the polyline, and the direction there in degrees
+func pointAlong(points []fpoint, fraction float64) (fpoint, float64) {
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		total += math.Hypot(points[i].X-points[i-1].X, points[i].Y-points[i-1].Y)
+	}
+	target := math.Max(0, math.Min(1, fraction)) * total
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		d := math.Hypot(b.X-a.X, b.Y-a.Y)
+		if d == 0 {
+			continue
+		}
+		if target <= d || i == len(points)-1 {
+			f := math.Min(1, target/d)
+			return fpoint{a.X + (b.X-a.X)*f, a.Y + (b.Y-a.Y)*f}, math.Atan2(b.Y-a.Y, b.X-a.X) * 180 / math.Pi
+		}
+		target -= d
+	}
+	return points[len(points)-1], 0
+}
+
+// sampler applies the animations of a list of elements at a given time
+type sampler struct {
+	t        float64                   // the time in seconds
+	targeted map[string][]SvgAnimation // animations that refer to the element they animate, by id
+	paths    map[string]SvgPath        // paths by id, for mpath elements
+}
+
+// collect finds the animations that refer to their target by id, and the paths that have an id
+func (s *sampler) collect(elements []SvgElement) {
+	for _, el := range elements {
+		switch e := el.(type) {
+		case *SvgAnimation:
+			if e.Target != "" {
+				s.targeted[e.Target] = append(s.targeted[e.Target], *e)
+			}
+			continue
+		case *SvgPath:
+			if e.ID != "" {
+				s.paths[e.ID] = *e
+			}
+		case *SvgGroup:
+			s.collect(e.Elements)
+		}
+		if a, ok := el.(animatable); ok {
+			for _, animation := range a.common().Animations {
+				if animation.Target != "" {
+					s.targeted[animation.Target] = append(s.targeted[animation.Target], animation)
+				}
+			}
+		}
+	}
+}
+
+// sampleElements returns a copy of the elements with the animations applied
+func (s *sampler) sampleElements(elements []SvgElement) []SvgElement {
+	sampled := make([]SvgElement, len(elements))
+	for i, el := range elements {
+		sampled[i] = s.sampleElement(el)
+	}
+	return sampled
+}
+
+// sampleElement returns a copy of the element with its animations applied
+func (s *sampler) sampleElement(el SvgElement) SvgElement {
+	a, ok := el.(animatable)
+	if !ok {
+		return el
+	}
+	common := a.common()
+	var animations []SvgAnimation
+	for _, animation := range common.Animations {
+		if animation.Target == "" {
+			animations = append(animations, animation)
+		}
+	}
+	if common.ID != "" {
+		animations = append(animations, s.targeted[common.ID]...)
+	}
+	// Animations that began later have a higher priority, and are applied last
+	sort.SliceStable(animations, func(i, j int) bool {
+		bi, _ := animations[i].beginAt(s.t)
+		bj, _ := animations[j].beginAt(s.t)
+		return bi < bj
+	})
+
+	var motion *Matrix
+	for _, animation := range animations {
+		switch animation.Tag {
+		case "animateMotion":
+			path := animation.Path
+			if path == nil && animation.MPath != "" {
+				if p, ok := s.paths[animation.MPath]; ok {
+					path = &p
+				}
+			}
+			m, ok := animation.motionAt(s.t, path)
+			if !ok {
+				continue
+			}
+			if motion != nil {
+				m = motion.Multiply(m)
+			}
+			motion = &m
+		case "animateTransform":
+			m, ok := animation.transformAt(s.t)
+			if !ok {
+				continue
+			}
+			if current := a.common().Transform; animation.Additive == "sum" && current != nil {
+				m = current.Multiply(m)
+			}
+			a = s.set(a, "transform", formatMatrix(m))
+		default:
+			kind := attributeKind(animation.AttributeName)
+			if animation.Tag == "animateColor" {
+				kind = kindColor
+			}
+			base, _ := a.attribute(animation.AttributeName)
+			if value, ok := animation.valueAt(s.t, kind, base); ok {
+				a = s.set(a, animation.AttributeName, value)
+			}
+		}
+	}
+	if motion != nil {
+		// The motion is applied after the transform attribute
+		a = s.set(a, "transform", formatMatrix(transform(*motion, a.common().Transform)))
+	}
+
+	el = a
+	if g, ok := el.(*SvgGroup); ok {
+		sampled := *g
+		sampled.Elements = s.sampleElements(g.Elements)
+		el = &sampled
+	}
+	return el
+}
+
+// set sets an attribute to an animated value, and leaves the element as it is if the value is not valid
+func (s *sampler) set(a animatable, name, value string) animatable {
+	el, err := a.withAttribute(name, value)
+	if err != nil {
+		return a
+	}
+	if changed, ok := el.(animatable); ok {
+		return changed
+	}
+	return a
+}
+
+// ElementsAt returns a copy of the elements where the animated attributes have the values
+// they have at time t of their SMIL animations
+func ElementsAt(elements []SvgElement, t time.Duration) []SvgElement {
+	return elementsAt(elements, nil, t)
+}
+
+// elementsAt returns a copy of the elements with the animations at time t applied,
+// where paths in defs can also be used by animateMotion elements
+func elementsAt(elements, defs []SvgElement, t time.Duration) []SvgElement {
+	s := &sampler{
+		t:        t.Seconds(),
+		targeted: make(map[string][]SvgAnimation),
+		paths:    make(map[string]SvgPath),
+	}
+	s.collect(defs)
+	s.collect(elements)
+	return s.sampleElements(elements)
+}
+
+// At returns a copy of the document where the animated attributes have the values
+// they have at time t of their SMIL animations
+func (d *Document) At(t time.Duration) *Document {
+	at := *d
+	at.Elements = elementsAt(d.Elements, d.Defs, t)
+	at.index()
+	return &at
+}
+
+// RenderAt renders the document as it is at time t of its SMIL animations.
+// The result only depends on the document and t, so any frame can be rendered at any time.
+func RenderAt(doc *Document, t time.Duration, img draw.Image) {
+	doc.At(t).Render(img)
+}
+
+// lastBegin returns the latest begin time of the animation, in seconds, and false if it has none
+func (a SvgAnimation) lastBegin() (float64, bool) {
+	if len(a.Begin) == 0 {
+		return 0, false
+	}
+	begin := a.Begin[0].Seconds()
+	for _, b := range a.Begin[1:] {
+		begin = math.Max(begin, b.Seconds())
+	}
+	return begin, true
+}
+
+// activeEnd returns when the last interval of the animation ends, which is the one that starts at the latest
+// begin time, in seconds. It is +Inf if that interval never ends.
+func (a SvgAnimation) activeEnd() float64 {
+	begin, ok := a.lastBegin()
+	if !ok {
+		return math.Inf(1)
+	}
+	end := math.Inf(1)
+	if a.Dur > 0 && a.Dur != Indefinite {
+		end = begin + a.Dur.Seconds()
+	}
+	if a.RepeatCount > 0 || a.RepeatDur > 0 {
+		end = math.Inf(1)
+		if a.RepeatCount > 0 {
+			end = begin + seconds(a.Dur)*a.RepeatCount
+		}
+		if a.RepeatDur > 0 {
+			end = math.Min(end, begin+seconds(a.RepeatDur))
+		}
+	}
+	for _, e := range a.End {
+		if es := e.Seconds(); es > begin && es < end {
+			end = es
+		}
+	}
+	return end
+}
+
+// AnimationDuration returns the time it takes until all animations in the elements have ended.
+// Animations that repeat forever count with one repetition. It returns 0 if nothing is animated.
+func AnimationDuration(elements []SvgElement) time.Duration {
+	longest := 0.0
+	var visit func(a SvgAnimation)
+	visit = func(a SvgAnimation) {
+		end := a.activeEnd()
+		if begin, ok := a.lastBegin(); ok && math.IsInf(end, 1) && a.Dur > 0 && a.Dur != Indefinite {
+			// Use one repetition of animations that go on forever
+			end = begin + a.Dur.Seconds()
+		}
+		if !math.IsInf(end, 1) {
+			longest = math.Max(longest, end)
+		}
+	}
+	var walk func(elements []SvgElement)
+	walk = func(elements []SvgElement) {
+		for _, el := range elements {
+			switch e := el.(type) {
+			case *SvgAnimation:
+				visit(*e)
+				continue
+			case *SvgGroup:
+				walk(e.Elements)
+			}
+			if a, ok := el.(animatable); ok {
+				for _, animation := range a.common().Animations {
+					visit(animation)
+				}
+			}
+		}
+	}
+	walk(elements)
+	return time.Duration(math.Round(longest * float64(time.Second)))
+}
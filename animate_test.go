@@ -0,0 +1,180 @@
+package surrender
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseClockValue(t *testing.T) {
+	for s, expected := range map[string]time.Duration{
+		"02:30:03": 2*time.Hour + 30*time.Minute + 3*time.Second,
+		"02:33":    2*time.Minute + 33*time.Second,
+		"00:10.25": 10250 * time.Millisecond,
+		"3.2h":     3*time.Hour + 12*time.Minute,
+		"45min":    45 * time.Minute,
+		"30s":      30 * time.Second,
+		"5ms":      5 * time.Millisecond,
+		"12.467":   12467 * time.Millisecond,
+		" 0.5s ":   500 * time.Millisecond,
+	} {
+		d, err := parseClockValue(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, d, s)
+	}
+	_, err := parseClockValue("soon")
+	assert.Error(t, err)
+}
+
+func TestParseAnimation(t *testing.T) {
+	doc, err := ParseFile("testdata/animation.svg")
+	assert.NoError(t, err)
+	elements := doc.Elements
+	assert.Len(t, elements, 6)
+
+	box := elements[0].(*SvgRectangle)
+	assert.Len(t, box.Animations, 2)
+	assert.Equal(t, "x", box.Animations[0].AttributeName)
+	assert.Equal(t, []time.Duration{0}, box.Animations[0].Begin)
+	assert.Equal(t, 3*time.Second, box.Animations[0].Dur)
+	assert.Equal(t, "freeze", box.Animations[0].Fill)
+	assert.Equal(t, []time.Duration{time.Second}, box.Animations[1].Begin)
+
+	colorAnimation := elements[2].(*SvgAnimation)
+	assert.Equal(t, "dot", colorAnimation.Target)
+	assert.Equal(t, []string{"#000000", "#ffffff"}, colorAnimation.Values)
+	assert.Equal(t, 2.0, colorAnimation.RepeatCount)
+
+	group := elements[3].(*SvgGroup)
+	assert.Len(t, group.Elements, 1)
+	assert.Len(t, group.Animations, 1)
+
+	motion := elements[5].(*SvgCircle).Animations[0]
+	assert.Equal(t, "track", motion.MPath)
+	assert.Equal(t, Indefinite, SvgAnimation{Dur: Indefinite}.Dur)
+}
+
+func TestProgressAt(t *testing.T) {
+	a := SvgAnimation{Begin: []time.Duration{time.Second}, Dur: 2 * time.Second, RepeatCount: 2}
+	_, _, ok := a.progressAt(0.5)
+	assert.False(t, ok)
+	p, i, ok := a.progressAt(2)
+	assert.True(t, ok)
+	assert.Equal(t, 0.5, p)
+	assert.Equal(t, 0, i)
+	p, i, _ = a.progressAt(4)
+	assert.Equal(t, 0.5, p)
+	assert.Equal(t, 1, i)
+	_, _, ok = a.progressAt(5)
+	assert.False(t, ok)
+
+	// Frozen at the end of the last repetition
+	a.Fill = "freeze"
+	p, i, ok = a.progressAt(10)
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, p)
+	assert.Equal(t, 1, i)
+
+	// An end time cuts the active duration short
+	a.End = []time.Duration{2 * time.Second}
+	p, _, _ = a.progressAt(10)
+	assert.Equal(t, 0.5, p)
+}
+
+func TestCalcModes(t *testing.T) {
+	a := SvgAnimation{Values: []string{"0", "10", "40"}, Begin: []time.Duration{0}, Dur: 4 * time.Second}
+	value := func(mode string, t float64) string {
+		a.CalcMode = mode
+		v, _ := a.valueAt(t, kindNumber, "0")
+		return v
+	}
+	assert.Equal(t, "5", value("linear", 1))
+	assert.Equal(t, "25", value("linear", 3))
+	assert.Equal(t, "10", value("discrete", 2))
+	assert.Equal(t, "0", value("discrete", 1))
+	// Paced moves at the same speed over the total distance of 40
+	assert.Equal(t, "20", value("paced", 2))
+
+	a.KeyTimes = []float64{0, 0.75, 1}
+	assert.Equal(t, "25", value("linear", 3.5))
+
+	// An ease-in spline is slower than linear at the start
+	a.KeyTimes = nil
+	a.KeySplines = [][4]float64{{0.42, 0, 1, 1}, {0.42, 0, 1, 1}}
+	eased, err := strconv.ParseFloat(value("spline", 1), 64)
+	assert.NoError(t, err)
+	assert.InDelta(t, 3.15, eased, 0.01)
+	assert.Equal(t, 0.0, math.Round(splineEase([4]float64{0, 0, 1, 1}, 0)))
+	assert.InDelta(t, 0.5, splineEase([4]float64{0, 0, 1, 1}, 0.5), 1e-9)
+}
+
+func TestAdditiveAnimation(t *testing.T) {
+	a := SvgAnimation{By: "10", Begin: []time.Duration{0}, Dur: 2 * time.Second, Accumulate: "sum", RepeatCount: 3}
+	v, ok := a.valueAt(1, kindNumber, "100")
+	assert.True(t, ok)
+	assert.Equal(t, "105", v)
+	// The second repetition starts where the first one ended
+	v, _ = a.valueAt(3, kindNumber, "100")
+	assert.Equal(t, "115", v)
+
+	c := SvgAnimation{From: "#000000", To: "#ff0000", Begin: []time.Duration{0}, Dur: 2 * time.Second}
+	v, _ = c.valueAt(1, kindColor, "")
+	assert.Equal(t, "#800000", v)
+}
+
+func TestElementsAt(t *testing.T) {
+	doc, err := ParseFile("testdata/animation.svg")
+	assert.NoError(t, err)
+	elements := doc.Elements
+
+	at := func(seconds float64) []SvgElement {
+		return ElementsAt(elements, time.Duration(seconds*float64(time.Second)))
+	}
+
+	box := at(1.5)[0].(*SvgRectangle)
+	assert.Equal(t, 45, box.X)
+	assert.Equal(t, color.RGBA{0, 0, 255, 255}, color.RGBAModel.Convert(box.Fill))
+	box = at(10)[0].(*SvgRectangle)
+	assert.Equal(t, 90, box.X)
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, color.RGBAModel.Convert(box.Fill))
+
+	// The original elements are not changed
+	assert.Equal(t, 0, elements[0].(*SvgRectangle).X)
+
+	dot := at(3)[1].(*SvgCircle)
+	assert.Equal(t, color.RGBA{128, 128, 128, 255}, color.RGBAModel.Convert(dot.Fill))
+	dot = at(5)[1].(*SvgCircle)
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, color.RGBAModel.Convert(dot.Fill))
+
+	group := at(1.5)[3].(*SvgGroup)
+	assert.Equal(t, color.RGBA{255, 255, 0, 255}, color.RGBAModel.Convert(group.Fill))
+	moved := group.Elements[0].(*SvgRectangle)
+	assert.NotNil(t, moved.Transform)
+	assert.InDelta(t, 10, moved.Transform.E, 1e-9)
+	assert.Nil(t, at(0.5)[3].(*SvgGroup).Elements[0].(*SvgRectangle).Transform)
+
+	ball := at(2)[5].(*SvgCircle)
+	assert.NotNil(t, ball.Transform)
+	assert.InDelta(t, 50, ball.Transform.E, 1e-9)
+	assert.InDelta(t, 50, ball.Transform.F, 1e-9)
+}
+
+func TestRenderAt(t *testing.T) {
+	doc, err := ParseFile("testdata/animation.svg")
+	assert.NoError(t, err)
+
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	RenderAt(doc, 1500*time.Millisecond, img)
+	assert.Equal(t, color.RGBA{0, 0, 255, 255}, img.RGBAAt(50, 5))
+	assert.Equal(t, color.RGBA{255, 255, 0, 255}, img.RGBAAt(15, 85))
+
+	// Rendering the same time twice gives the same image
+	again := image.NewRGBA(img.Bounds())
+	RenderAt(doc, 1500*time.Millisecond, again)
+	assert.Equal(t, img.Pix, again.Pix)
+}
@@ -0,0 +1,299 @@
+package surrender
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"time"
+)
+
+// APNGOptions configures how the animations of a document are exported as an Animated PNG
+type APNGOptions struct {
+	FPS        float64       // frames per second, 10 if not set
+	Start      time.Duration // when to start sampling the animations
+	End        time.Duration // when to stop sampling, where 0 means the end of the animations
+	Plays      int           // how many times the animation is played, where 0 means forever
+	Background color.Color   // the background color, or nil for a transparent background
+	// MaxPixels is the largest number of pixels each frame may have. If it is 0, DefaultMaxPixels is used.
+	MaxPixels int
+	// Context can cancel rendering and encoding or give them a deadline, and its error is returned if it
+	// is done first. If it is nil, they can not be cancelled.
+	Context context.Context
+}
+
+// The dispose and blend operations of an APNG frame
+const (
+	apngDisposeNone       = 0
+	apngDisposeBackground = 1
+	apngBlendSource       = 0
+	apngBlendOver         = 1
+)
+
+// apngFrame is one frame of an Animated PNG, which only covers the part of the canvas that changes
+type apngFrame struct {
+	rect    image.Rectangle
+	pixels  *image.NRGBA // the pixels of the frame, which covers rect
+	delay   int          // in 100ths of a second
+	dispose byte
+	blend   byte
+}
+
+// EncodeAPNG renders the animations of the document as an Animated PNG and writes it to w.
+// The first frame is also the default image, which is shown by PNG decoders that do not support animation.
+func EncodeAPNG(w io.Writer, doc *Document, opts APNGOptions) error {
+	ctx := opts.Context
+	frames, delays, err := renderFrames(doc, opts.Start, opts.End, opts.FPS, RenderOptions{Background: opts.Background, MaxPixels: opts.MaxPixels, Context: ctx})
+	if err != nil {
+		return err
+	}
+
+	// Transparency is only used if the frames need it, since RGB is smaller than RGBA
+	alpha := false
+	for _, frame := range frames {
+		if !frame.Opaque() {
+			alpha = true
+			break
+		}
+	}
+
+	apngFrames, err := diffFrames(ctx, frames, delays, alpha)
+	if err != nil {
+		return err
+	}
+
+	e := &apngEncoder{w: w}
+	e.write([]byte("\x89PNG\r\n\x1a\n"))
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(doc.Width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(doc.Height))
+	ihdr[8] = 8 // bits per sample
+	ihdr[9] = 2 // truecolor
+	if alpha {
+		ihdr[9] = 6 // truecolor with alpha
+	}
+	e.chunk("IHDR", ihdr)
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:], uint32(len(apngFrames)))
+	binary.BigEndian.PutUint32(actl[4:], uint32(opts.Plays))
+	e.chunk("acTL", actl)
+
+	for i, f := range apngFrames {
+		if cancelled(ctx) {
+			return ctx.Err()
+		}
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], e.next())
+		binary.BigEndian.PutUint32(fctl[4:], uint32(f.rect.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(f.rect.Dy()))
+		binary.BigEndian.PutUint32(fctl[12:], uint32(f.rect.Min.X))
+		binary.BigEndian.PutUint32(fctl[16:], uint32(f.rect.Min.Y))
+		binary.BigEndian.PutUint16(fctl[20:], uint16(f.delay))
+		binary.BigEndian.PutUint16(fctl[22:], 100)
+		fctl[24] = f.dispose
+		fctl[25] = f.blend
+		e.chunk("fcTL", fctl)
+
+		data, err := imageData(f.pixels, alpha)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			e.chunk("IDAT", data)
+			continue
+		}
+		fdat := make([]byte, 4, 4+len(data))
+		binary.BigEndian.PutUint32(fdat, e.next())
+		e.chunk("fdAT", append(fdat, data...))
+	}
+	e.chunk("IEND", nil)
+	return e.err
+}
+
+// SaveAPNG renders the animations of the document as an Animated PNG and saves it
+func SaveAPNG(doc *Document, filename string, opts APNGOptions) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := EncodeAPNG(file, doc, opts); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// diffFrames crops each frame to the part that changes from the previous frame, and chooses
+// the blend and dispose operations. Transparent pixels are only used if alpha is true.
+// The error of the context is returned if it is cancelled.
+func diffFrames(ctx context.Context, frames []*image.RGBA, delays []int, alpha bool) ([]apngFrame, error) {
+	bounds := frames[0].Bounds()
+	result := []apngFrame{{rect: bounds, pixels: crop(frames[0], bounds, nil), delay: delays[0], blend: apngBlendSource}}
+
+	canvas := image.NewRGBA(bounds)
+	copy(canvas.Pix, frames[0].Pix)
+	for i := 1; i < len(frames); i++ {
+		if cancelled(ctx) {
+			return nil, ctx.Err()
+		}
+		next := frames[i]
+		rect := changedRect(canvas, next)
+		if alpha {
+			// Clearing the previous frame to transparent black may leave less to draw
+			prev := &result[len(result)-1]
+			cleared := image.NewRGBA(bounds)
+			copy(cleared.Pix, canvas.Pix)
+			clearRect(cleared, prev.rect)
+			if r := changedRect(cleared, next); area(r) < area(rect) {
+				prev.dispose = apngDisposeBackground
+				canvas, rect = cleared, r
+			}
+		}
+		if rect.Empty() {
+			// Only the dispose operation changed the canvas, so draw a single unchanged pixel
+			rect = image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Min.X+1, bounds.Min.Y+1)
+		}
+
+		f := apngFrame{rect: rect, delay: delays[i], blend: apngBlendSource}
+		if alpha && opaqueChanges(canvas, next, rect) {
+			// Pixels that do not change are left transparent, which compresses better
+			f.blend = apngBlendOver
+			f.pixels = crop(next, rect, canvas)
+		} else {
+			f.pixels = crop(next, rect, nil)
+		}
+		result = append(result, f)
+		copy(canvas.Pix, next.Pix)
+	}
+	return result, nil
+}
+
+// area returns the number of pixels in a rectangle
+func area(r image.Rectangle) int {
+	return r.Dx() * r.Dy()
+}
+
+// changedRect returns the smallest rectangle that contains all pixels that differ between a and b
+func changedRect(a, b *image.RGBA) image.Rectangle {
+	bounds := a.Bounds()
+	changed := image.Rectangle{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := a.PixOffset(x, y)
+			if a.Pix[i] != b.Pix[i] || a.Pix[i+1] != b.Pix[i+1] || a.Pix[i+2] != b.Pix[i+2] || a.Pix[i+3] != b.Pix[i+3] {
+				changed = changed.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return changed
+}
+
+// clearRect sets the pixels in the rectangle to transparent black
+func clearRect(img *image.RGBA, r image.Rectangle) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, color.RGBA{})
+		}
+	}
+}
+
+// opaqueChanges checks if all pixels in the rectangle that differ from the canvas are opaque in the next frame,
+// which means that they can be drawn over the canvas
+func opaqueChanges(canvas, next *image.RGBA, r image.Rectangle) bool {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if c := next.RGBAAt(x, y); c != canvas.RGBAAt(x, y) && c.A != 255 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// crop returns the part of the image within the rectangle as a non-premultiplied image that starts at (0, 0).
+// If unchanged is not nil, pixels that are the same as in unchanged are made transparent.
+func crop(img *image.RGBA, r image.Rectangle, unchanged *image.RGBA) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			if unchanged != nil && unchanged.RGBAAt(x, y) == c {
+				continue
+			}
+			dst.SetNRGBA(x-r.Min.X, y-r.Min.Y, color.NRGBAModel.Convert(c).(color.NRGBA))
+		}
+	}
+	return dst
+}
+
+// apngEncoder writes the chunks of an Animated PNG, and keeps the first error
+type apngEncoder struct {
+	w        io.Writer
+	sequence uint32
+	err      error
+}
+
+// next returns the next sequence number, for fcTL and fdAT chunks
+func (e *apngEncoder) next() uint32 {
+	e.sequence++
+	return e.sequence - 1
+}
+
+func (e *apngEncoder) write(b []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+// chunk writes a PNG chunk, with its length and checksum
+func (e *apngEncoder) chunk(name string, data []byte) {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], name)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+	e.write(header)
+	e.write(data)
+	e.write(footer)
+}
+
+// imageData encodes the pixels of a frame with image/png, and returns the image data of its IDAT chunks.
+// The pixels are encoded with transparency if alpha is true, so that all frames have the same color type.
+func imageData(img *image.NRGBA, alpha bool) ([]byte, error) {
+	var m image.Image = img
+	if alpha && img.Opaque() {
+		m = translucent{img}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, m); err != nil {
+		return nil, err
+	}
+	// Skip the signature, and collect the data of the IDAT chunks
+	var data []byte
+	for b := buf.Bytes()[8:]; len(b) >= 12; {
+		length := binary.BigEndian.Uint32(b)
+		if string(b[4:8]) == "IDAT" {
+			data = append(data, b[8:8+length]...)
+		}
+		b = b[12+length:]
+	}
+	return data, nil
+}
+
+// translucent is an image that image/png encodes with an alpha channel, even if all its pixels are opaque
+type translucent struct {
+	*image.NRGBA
+}
+
+// Opaque returns false, so that image/png does not leave out the alpha channel
+func (translucent) Opaque() bool {
+	return false
+}
@@ -0,0 +1,142 @@
+package surrender
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// decodeAPNG decodes the frames of an Animated PNG and composes them, by turning each frame into a PNG image
+func decodeAPNG(t *testing.T, data []byte) []*image.NRGBA {
+	var ihdr []byte
+	var canvas *image.NRGBA
+	var frames []*image.NRGBA
+	var fctl []byte
+	var sequence uint32
+	compose := func(imageData []byte) {
+		header := append([]byte{}, ihdr...)
+		binary.BigEndian.PutUint32(header[0:], binary.BigEndian.Uint32(fctl[4:]))
+		binary.BigEndian.PutUint32(header[4:], binary.BigEndian.Uint32(fctl[8:]))
+		var buf bytes.Buffer
+		buf.WriteString("\x89PNG\r\n\x1a\n")
+		for _, c := range []struct {
+			name string
+			data []byte
+		}{{"IHDR", header}, {"IDAT", imageData}, {"IEND", nil}} {
+			binary.Write(&buf, binary.BigEndian, uint32(len(c.data)))
+			buf.WriteString(c.name)
+			buf.Write(c.data)
+			binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(append([]byte(c.name), c.data...)))
+		}
+		img, err := png.Decode(&buf)
+		assert.NoError(t, err)
+		x, y := int(binary.BigEndian.Uint32(fctl[12:])), int(binary.BigEndian.Uint32(fctl[16:]))
+		r := img.Bounds().Add(image.Pt(x, y))
+		op := draw.Src
+		if fctl[25] == apngBlendOver {
+			op = draw.Over
+		}
+		draw.Draw(canvas, r, img, image.Point{}, op)
+		frame := image.NewNRGBA(canvas.Bounds())
+		copy(frame.Pix, canvas.Pix)
+		frames = append(frames, frame)
+		if fctl[24] == apngDisposeBackground {
+			draw.Draw(canvas, r, image.Transparent, image.Point{}, draw.Src)
+		}
+	}
+	for pos := 8; pos < len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		name := string(data[pos+4 : pos+8])
+		chunk := data[pos+8 : pos+8+length]
+		pos += 12 + length
+		switch name {
+		case "IHDR":
+			ihdr = chunk
+			canvas = image.NewNRGBA(image.Rect(0, 0, int(binary.BigEndian.Uint32(chunk)), int(binary.BigEndian.Uint32(chunk[4:]))))
+		case "fcTL":
+			assert.Equal(t, sequence, binary.BigEndian.Uint32(chunk))
+			sequence++
+			fctl = chunk
+		case "IDAT":
+			compose(chunk)
+		case "fdAT":
+			assert.Equal(t, sequence, binary.BigEndian.Uint32(chunk))
+			sequence++
+			compose(chunk[4:])
+		}
+	}
+	return frames
+}
+
+func TestEncodeAPNG(t *testing.T) {
+	doc, err := ParseFile("testdata/animation.svg")
+	assert.NoError(t, err)
+
+	for _, bg := range []color.Color{color.White, nil} {
+		var buf bytes.Buffer
+		assert.NoError(t, EncodeAPNG(&buf, doc, APNGOptions{FPS: 4, End: 2 * time.Second, Background: bg}))
+
+		// Decoders without APNG support show the first frame
+		first, err := png.Decode(bytes.NewReader(buf.Bytes()))
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 100, 100), first.Bounds())
+
+		expected, _, err := renderFrames(doc, 0, 2*time.Second, 4, RenderOptions{Background: bg})
+		assert.NoError(t, err)
+		frames := decodeAPNG(t, buf.Bytes())
+		assert.Equal(t, len(expected), len(frames))
+		for i, frame := range frames {
+			want := image.NewNRGBA(frame.Bounds())
+			draw.Draw(want, want.Bounds(), expected[i], image.Point{}, draw.Src)
+			assert.Equal(t, want.Pix, frame.Pix, "frame %d", i)
+		}
+	}
+}
+
+func TestDiffFrames(t *testing.T) {
+	a := NewColoredImage(10, 10, color.White)
+	b := NewColoredImage(10, 10, color.White)
+	b.Set(3, 4, color.Black)
+	b.Set(5, 6, color.Black)
+	frames, err := diffFrames(nil, []*image.RGBA{a, b}, []int{10, 10}, false)
+	assert.NoError(t, err)
+	assert.Len(t, frames, 2)
+	assert.Equal(t, a.Bounds(), frames[0].rect)
+	assert.Equal(t, image.Rect(3, 4, 6, 7), frames[1].rect)
+	assert.Equal(t, byte(apngBlendSource), frames[1].blend)
+
+	// With transparency, unchanged pixels are left out and the frame is drawn over the canvas
+	frames, err = diffFrames(nil, []*image.RGBA{a, b}, []int{10, 10}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(apngBlendOver), frames[1].blend)
+	assert.Equal(t, uint8(0), frames[1].pixels.NRGBAAt(1, 0).A)
+}
+
+func TestImageData(t *testing.T) {
+	// Opaque frames are encoded with an alpha channel if the animation has one
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	for _, alpha := range []bool{false, true} {
+		data, err := imageData(img, alpha)
+		assert.NoError(t, err)
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		assert.NoError(t, err)
+		pixels, err := io.ReadAll(zr)
+		assert.NoError(t, err)
+		bpp := 3
+		if alpha {
+			bpp = 4
+		}
+		assert.Len(t, pixels, 2*(1+3*bpp))
+	}
+}
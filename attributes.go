@@ -0,0 +1,535 @@
+package surrender
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// errUnknownAttribute is returned when setting an attribute that the element does not have
+var errUnknownAttribute = errors.New("unknown attribute")
+
+// animatable is implemented by the elements whose attributes can be read and changed by name
+type animatable interface {
+	SvgElement
+	common() Common
+	attribute(name string) (string, bool)
+	withAttribute(name, value string) (SvgElement, error) // returns a changed copy of the element
+}
+
+func (c Common) common() Common {
+	return c
+}
+
+// propertyValues are the values of the inherited properties that are kept in Common
+var propertyValues = map[string][]string{
+	"shape-rendering": {"auto", "optimizeSpeed", "crispEdges", "geometricPrecision"},
+	"text-rendering":  {"auto", "optimizeSpeed", "optimizeLegibility", "geometricPrecision"},
+	"image-rendering": {"auto", "optimizeSpeed", "optimizeQuality", "pixelated", "crisp-edges"},
+	"fill-rule":       {"nonzero", "evenodd"},
+	"visibility":      {"visible", "hidden", "collapse"},
+	"pointer-events":  {"visiblePainted", "visibleFill", "visibleStroke", "visible", "painted", "fill", "stroke", "all", "none"},
+}
+
+// propertySet is a set of the inherited properties that are kept in Common
+type propertySet uint8
+
+// propertyBits are the bits of the inherited properties in a propertySet
+var propertyBits = map[string]propertySet{
+	"shape-rendering": 1 << 0,
+	"text-rendering":  1 << 1,
+	"image-rendering": 1 << 2,
+	"fill-rule":       1 << 3,
+	"visibility":      1 << 4,
+	"pointer-events":  1 << 5,
+}
+
+// validProperty checks if the value is one of the values of an inherited property
+func validProperty(name, value string) bool {
+	for _, v := range propertyValues[name] {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// property returns the field of an inherited property, or nil if there is no such property
+func (c *Common) property(name string) *string {
+	switch name {
+	case "shape-rendering":
+		return &c.ShapeRendering
+	case "text-rendering":
+		return &c.TextRendering
+	case "image-rendering":
+		return &c.ImageRendering
+	case "fill-rule":
+		return &c.FillRule
+	case "visibility":
+		return &c.Visibility
+	case "pointer-events":
+		return &c.PointerEvents
+	}
+	return nil
+}
+
+// attribute returns the value of one of the common attributes, and false if it is not set
+func (c Common) attribute(name string) (string, bool) {
+	switch name {
+	case "id":
+		return c.ID, c.ID != ""
+	case "transform":
+		if c.Transform == nil {
+			return "", false
+		}
+		return formatMatrix(*c.Transform), true
+	case "display":
+		if c.Display == "none" {
+			return "none", true
+		}
+		return "inline", true
+	}
+	if field := c.property(name); field != nil {
+		if *field == "" {
+			return propertyValues[name][0], true
+		}
+		return *field, true
+	}
+	return "", false
+}
+
+// setAttribute sets one of the common attributes
+func (c *Common) setAttribute(name, value string) error {
+	switch name {
+	case "id":
+		c.ID = value
+	case "transform":
+		if strings.TrimSpace(value) == "" {
+			c.Transform = nil
+			return nil
+		}
+		m, err := ParseTransform(value)
+		if err != nil {
+			return err
+		}
+		c.Transform = &m
+	case "display":
+		c.Display = displayValue(value)
+	default:
+		field := c.property(name)
+		if field == nil {
+			return fmt.Errorf("%w: %s", errUnknownAttribute, name)
+		}
+		if value = strings.TrimSpace(value); !validProperty(name, value) {
+			return fmt.Errorf("invalid %s: %q", name, value)
+		}
+		*field = value
+		c.specified |= propertyBits[name]
+	}
+	return nil
+}
+
+// parseLength parses a coordinate or length attribute value, rounded to the nearest integer
+func parseLength(value string) (int, error) {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "px"), 64)
+	if err != nil {
+		return 0, err
+	}
+	return roundCoordinate(f), nil
+}
+
+// parseStrokeWidth parses a stroke-width attribute, which must be a number that is not negative
+func parseStrokeWidth(value string) (float64, error) {
+	width, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "px"), 64)
+	if err != nil || math.IsNaN(width) || math.IsInf(width, 0) {
+		return 0, fmt.Errorf("%q is not a number", value)
+	}
+	if width < 0 {
+		return 0, fmt.Errorf("%q is negative", value)
+	}
+	return width, nil
+}
+
+// parseFill parses a fill or stroke color, where nil means that the color is inherited
+func parseFill(value string) color.Color {
+	value = strings.TrimSpace(value)
+	if value == "inherit" {
+		return nil
+	}
+	return GetColor(value)
+}
+
+// formatNumber formats a number with as few digits as needed
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// formatColor formats a color as "#rrggbb", or "none" if it is fully transparent, and returns false for nil
+func formatColor(c color.Color) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	if transparent(c) {
+		return "none", true
+	}
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B), true
+}
+
+// formatMatrix formats a matrix as a transform attribute value
+func formatMatrix(m Matrix) string {
+	return fmt.Sprintf("matrix(%s %s %s %s %s %s)", formatNumber(m.A), formatNumber(m.B), formatNumber(m.C), formatNumber(m.D), formatNumber(m.E), formatNumber(m.F))
+}
+
+// pathData formats path commands as path data, keeping the commands as they are
+func pathData(commands []PathCommand) string {
+	var sb strings.Builder
+	for _, command := range commands {
+		sb.WriteString(command.Type)
+		for i, p := range command.Points {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			switch command.Type {
+			case "H", "h":
+				sb.WriteString(strconv.Itoa(p.X))
+			case "V", "v":
+				sb.WriteString(strconv.Itoa(p.Y))
+			default:
+				sb.WriteString(strconv.Itoa(p.X) + " " + strconv.Itoa(p.Y))
+			}
+		}
+	}
+	return sb.String()
+}
+
+func (c SvgCircle) attribute(name string) (string, bool) {
+	switch name {
+	case "cx":
+		return strconv.Itoa(c.Cx), true
+	case "cy":
+		return strconv.Itoa(c.Cy), true
+	case "r":
+		return strconv.Itoa(c.R), true
+	case "fill":
+		return formatColor(c.Fill)
+	case "stroke":
+		return formatColor(c.Stroke)
+	case "stroke-width":
+		return formatNumber(c.StrokeWidth), c.Stroke != nil
+	}
+	return c.Common.attribute(name)
+}
+
+func (c SvgCircle) withAttribute(name, value string) (SvgElement, error) {
+	var err error
+	switch name {
+	case "cx":
+		c.Cx, err = parseLength(value)
+	case "cy":
+		c.Cy, err = parseLength(value)
+	case "r":
+		c.R, err = parseLength(value)
+	case "fill":
+		c.Fill = parseFill(value)
+	case "stroke":
+		c.Stroke = parseFill(value)
+		if c.StrokeWidth == 0 {
+			c.StrokeWidth = 1
+		}
+	case "stroke-width":
+		c.StrokeWidth, err = parseStrokeWidth(value)
+	default:
+		err = c.Common.setAttribute(name, value)
+	}
+	return &c, err
+}
+
+func (r SvgRectangle) attribute(name string) (string, bool) {
+	switch name {
+	case "x":
+		return strconv.Itoa(r.X), true
+	case "y":
+		return strconv.Itoa(r.Y), true
+	case "width":
+		return strconv.Itoa(r.Width), true
+	case "height":
+		return strconv.Itoa(r.Height), true
+	case "fill":
+		return formatColor(r.Fill)
+	case "stroke":
+		return formatColor(r.Stroke)
+	case "stroke-width":
+		return formatNumber(r.StrokeWidth), r.Stroke != nil
+	}
+	return r.Common.attribute(name)
+}
+
+func (r SvgRectangle) withAttribute(name, value string) (SvgElement, error) {
+	var err error
+	switch name {
+	case "x":
+		r.X, err = parseLength(value)
+	case "y":
+		r.Y, err = parseLength(value)
+	case "width":
+		r.Width, err = parseLength(value)
+	case "height":
+		r.Height, err = parseLength(value)
+	case "fill":
+		r.Fill = parseFill(value)
+	case "stroke":
+		r.Stroke = parseFill(value)
+		if r.StrokeWidth == 0 {
+			r.StrokeWidth = 1
+		}
+	case "stroke-width":
+		r.StrokeWidth, err = parseStrokeWidth(value)
+	default:
+		err = r.Common.setAttribute(name, value)
+	}
+	return &r, err
+}
+
+func (p SvgPath) attribute(name string) (string, bool) {
+	switch name {
+	case "d":
+		return pathData(p.Commands), true
+	case "fill":
+		return formatColor(p.Fill)
+	case "stroke":
+		return formatColor(p.Stroke)
+	case "stroke-width":
+		return formatNumber(p.StrokeWidth), p.Stroke != nil
+	}
+	return p.Common.attribute(name)
+}
+
+func (p SvgPath) withAttribute(name, value string) (SvgElement, error) {
+	var err error
+	switch name {
+	case "d":
+		var path SvgPath
+		if path, err = ParsePath(value); err == nil {
+			p.Commands = path.Commands
+		}
+	case "fill":
+		p.Fill = parseFill(value)
+	case "stroke":
+		p.Stroke = parseFill(value)
+		if p.StrokeWidth == 0 {
+			p.StrokeWidth = 1
+		}
+	case "stroke-width":
+		p.StrokeWidth, err = parseStrokeWidth(value)
+	default:
+		err = p.Common.setAttribute(name, value)
+	}
+	return &p, err
+}
+
+func (g SvgGroup) attribute(name string) (string, bool) {
+	if name == "fill" {
+		return formatColor(g.Fill)
+	}
+	return g.Common.attribute(name)
+}
+
+func (g SvgGroup) withAttribute(name, value string) (SvgElement, error) {
+	var err error
+	if name == "fill" {
+		g.Fill = parseFill(value)
+	} else {
+		err = g.Common.setAttribute(name, value)
+	}
+	return &g, err
+}
+
+func (l SvgLine) attribute(name string) (string, bool) {
+	switch name {
+	case "x1":
+		return strconv.Itoa(l.X1), true
+	case "y1":
+		return strconv.Itoa(l.Y1), true
+	case "x2":
+		return strconv.Itoa(l.X2), true
+	case "y2":
+		return strconv.Itoa(l.Y2), true
+	case "stroke":
+		return formatColor(l.Stroke)
+	}
+	return l.Common.attribute(name)
+}
+
+func (l SvgLine) withAttribute(name, value string) (SvgElement, error) {
+	var err error
+	switch name {
+	case "x1":
+		l.X1, err = parseLength(value)
+	case "y1":
+		l.Y1, err = parseLength(value)
+	case "x2":
+		l.X2, err = parseLength(value)
+	case "y2":
+		l.Y2, err = parseLength(value)
+	case "stroke":
+		l.Stroke = parseFill(value)
+	default:
+		err = l.Common.setAttribute(name, value)
+	}
+	return &l, err
+}
+
+func (t SvgText) attribute(name string) (string, bool) {
+	switch name {
+	case "x":
+		return strconv.Itoa(t.X), true
+	case "y":
+		return strconv.Itoa(t.Y), true
+	case "font-size":
+		return strconv.Itoa(t.FontSize), true
+	case "text-anchor":
+		return t.Anchor, true
+	case "fill":
+		return formatColor(t.Fill)
+	}
+	return t.Common.attribute(name)
+}
+
+func (t SvgText) withAttribute(name, value string) (SvgElement, error) {
+	var err error
+	switch name {
+	case "x":
+		t.X, err = parseLength(value)
+	case "y":
+		t.Y, err = parseLength(value)
+	case "font-size":
+		t.FontSize, err = parseLength(value)
+	case "text-anchor":
+		t.Anchor = value
+	case "fill":
+		t.Fill = parseFill(value)
+	default:
+		err = t.Common.setAttribute(name, value)
+	}
+	return &t, err
+}
+
+// formatAutoSize formats a textArea width or height
+func formatAutoSize(size int) string {
+	if size == AutoSize {
+		return "auto"
+	}
+	return strconv.Itoa(size)
+}
+
+// parseAutoSize parses a textArea width or height, which can be "auto"
+func parseAutoSize(value string) (int, error) {
+	if strings.TrimSpace(value) == "auto" {
+		return AutoSize, nil
+	}
+	return parseLength(value)
+}
+
+func (t SvgTextArea) attribute(name string) (string, bool) {
+	switch name {
+	case "x":
+		return strconv.Itoa(t.X), true
+	case "y":
+		return strconv.Itoa(t.Y), true
+	case "width":
+		return formatAutoSize(t.Width), true
+	case "height":
+		return formatAutoSize(t.Height), true
+	case "font-size":
+		return strconv.Itoa(t.FontSize), true
+	case "line-increment":
+		if t.LineIncrement == 0 {
+			return "auto", true
+		}
+		return strconv.Itoa(t.LineIncrement), true
+	case "display-align":
+		return t.DisplayAlign, true
+	case "text-align":
+		return t.TextAlign, true
+	case "fill":
+		return formatColor(t.Fill)
+	}
+	return t.Common.attribute(name)
+}
+
+func (t SvgTextArea) withAttribute(name, value string) (SvgElement, error) {
+	var err error
+	switch name {
+	case "x":
+		t.X, err = parseLength(value)
+	case "y":
+		t.Y, err = parseLength(value)
+	case "width":
+		t.Width, err = parseAutoSize(value)
+	case "height":
+		t.Height, err = parseAutoSize(value)
+	case "font-size":
+		t.FontSize, err = parseLength(value)
+	case "line-increment":
+		if strings.TrimSpace(value) == "auto" {
+			t.LineIncrement = 0
+		} else {
+			t.LineIncrement, err = parseLength(value)
+		}
+	case "display-align":
+		t.DisplayAlign = value
+	case "text-align":
+		t.TextAlign = value
+	case "fill":
+		t.Fill = parseFill(value)
+	default:
+		err = t.Common.setAttribute(name, value)
+	}
+	return &t, err
+}
+
+func (i SvgImage) attribute(name string) (string, bool) {
+	switch name {
+	case "x":
+		return strconv.Itoa(i.X), true
+	case "y":
+		return strconv.Itoa(i.Y), true
+	case "width":
+		return strconv.Itoa(i.Width), true
+	case "height":
+		return strconv.Itoa(i.Height), true
+	case "opacity":
+		return formatNumber(i.Opacity), true
+	case "preserveAspectRatio":
+		return i.PreserveAspectRatio, true
+	}
+	return i.Common.attribute(name)
+}
+
+func (i SvgImage) withAttribute(name, value string) (SvgElement, error) {
+	var err error
+	switch name {
+	case "x":
+		i.X, err = parseLength(value)
+	case "y":
+		i.Y, err = parseLength(value)
+	case "width":
+		i.Width, err = parseLength(value)
+	case "height":
+		i.Height, err = parseLength(value)
+	case "opacity":
+		var opacity float64
+		if opacity, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			i.Opacity = math.Max(0, math.Min(1, opacity))
+		}
+	case "preserveAspectRatio":
+		i.PreserveAspectRatio = value
+	default:
+		err = i.Common.setAttribute(name, value)
+	}
+	return &i, err
+}
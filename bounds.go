@@ -0,0 +1,321 @@
+package surrender
+
+import (
+	"image/color"
+	"math"
+)
+
+// Rect is an axis-aligned rectangle, from (X0, Y0) to (X1, Y1)
+type Rect struct {
+	X0, Y0, X1, Y1 float64
+}
+
+// Width returns the width of the rectangle
+func (r Rect) Width() float64 {
+	return r.X1 - r.X0
+}
+
+// Height returns the height of the rectangle
+func (r Rect) Height() float64 {
+	return r.Y1 - r.Y0
+}
+
+// Empty checks if the rectangle has no area
+func (r Rect) Empty() bool {
+	return !(r.X0 < r.X1 && r.Y0 < r.Y1)
+}
+
+// Union returns the smallest rectangle that contains both rectangles
+func (r Rect) Union(s Rect) Rect {
+	return Rect{math.Min(r.X0, s.X0), math.Min(r.Y0, s.Y0), math.Max(r.X1, s.X1), math.Max(r.Y1, s.Y1)}
+}
+
+// bbox collects points into a bounding box
+type bbox struct {
+	rect Rect
+	ok   bool // false until the first point is added
+}
+
+// add extends the bounding box with a point
+func (b *bbox) add(p fpoint) {
+	if !b.ok {
+		b.rect, b.ok = Rect{p.X, p.Y, p.X, p.Y}, true
+		return
+	}
+	b.rect = b.rect.Union(Rect{p.X, p.Y, p.X, p.Y})
+}
+
+// addRect extends the bounding box with the corners of a rectangle, transformed by m
+func (b *bbox) addRect(m Matrix, x0, y0, x1, y1 float64) {
+	for _, p := range []fpoint{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}} {
+		b.add(m.applyPoint(p))
+	}
+}
+
+// merge extends the bounding box with another one
+func (b *bbox) merge(o bbox) {
+	if !o.ok {
+		return
+	}
+	b.add(fpoint{o.rect.X0, o.rect.Y0})
+	b.add(fpoint{o.rect.X1, o.rect.Y1})
+}
+
+// addSegment extends the bounding box with a path segment transformed by m, including the extrema of curves
+func (b *bbox) addSegment(seg segment, m Matrix) {
+	var pts [4]fpoint
+	for i := 0; i <= seg.degree; i++ {
+		pts[i] = m.applyPoint(seg.points[i])
+	}
+	b.add(pts[0])
+	b.add(pts[seg.degree])
+	for _, t := range curveExtrema(pts, seg.degree) {
+		b.add(bezierAt(pts, seg.degree, t))
+	}
+}
+
+// curveExtrema returns the parameters between 0 and 1 where a quadratic or cubic Bézier curve
+// has a horizontal or vertical tangent
+func curveExtrema(pts [4]fpoint, degree int) []float64 {
+	var ts []float64
+	for _, axis := range [2][4]float64{{pts[0].X, pts[1].X, pts[2].X, pts[3].X}, {pts[0].Y, pts[1].Y, pts[2].Y, pts[3].Y}} {
+		p0, p1, p2, p3 := axis[0], axis[1], axis[2], axis[3]
+		switch degree {
+		case 2:
+			// The derivative is linear
+			if d := p0 - 2*p1 + p2; d != 0 {
+				ts = append(ts, (p0-p1)/d)
+			}
+		case 3:
+			// The derivative is the quadratic a*t^2 + b*t + c
+			a := -p0 + 3*p1 - 3*p2 + p3
+			b := 2 * (p0 - 2*p1 + p2)
+			c := p1 - p0
+			if a == 0 {
+				if b != 0 {
+					ts = append(ts, -c/b)
+				}
+				continue
+			}
+			if disc := b*b - 4*a*c; disc >= 0 {
+				sq := math.Sqrt(disc)
+				ts = append(ts, (-b+sq)/(2*a), (-b-sq)/(2*a))
+			}
+		}
+	}
+	inside := ts[:0]
+	for _, t := range ts {
+		if t > 0 && t < 1 {
+			inside = append(inside, t)
+		}
+	}
+	return inside
+}
+
+// bezierAt returns the point at parameter t of a line, or a quadratic or cubic Bézier curve
+func bezierAt(pts [4]fpoint, degree int, t float64) fpoint {
+	mt := 1 - t
+	switch degree {
+	case 2:
+		a, b, c := mt*mt, 2*mt*t, t*t
+		return fpoint{a*pts[0].X + b*pts[1].X + c*pts[2].X, a*pts[0].Y + b*pts[1].Y + c*pts[2].Y}
+	case 3:
+		a, b, c, d := mt*mt*mt, 3*mt*mt*t, 3*mt*t*t, t*t*t
+		return fpoint{a*pts[0].X + b*pts[1].X + c*pts[2].X + d*pts[3].X, a*pts[0].Y + b*pts[1].Y + c*pts[2].Y + d*pts[3].Y}
+	}
+	return fpoint{mt*pts[0].X + t*pts[1].X, mt*pts[0].Y + t*pts[1].Y}
+}
+
+// transformedBounds returns the bounding box of an element with its own transform, transformed by m.
+// If stroke is true, the area that is covered by the stroke is included.
+func transformedBounds(el SvgElement, m Matrix, stroke bool) bbox {
+	if a, ok := el.(animatable); ok {
+		m = transform(m, a.common().Transform)
+	}
+	return geometryBounds(el, m, stroke)
+}
+
+// geometryBounds returns the bounding box of an element, without its own transform, transformed by m.
+// Curves are included exactly, and not by their control points.
+func geometryBounds(el SvgElement, m Matrix, stroke bool) bbox {
+	var b bbox
+	switch e := el.(type) {
+	case *SvgCircle:
+		if e.R <= 0 {
+			break
+		}
+		// A transformed circle is an ellipse, with the half width r*|(a, c)| and the half height r*|(b, d)|
+		r := float64(e.R)
+		if stroke {
+			r += halfStroke(e.Stroke, e.StrokeWidth)
+		}
+		cx, cy := m.Apply(float64(e.Cx), float64(e.Cy))
+		dx, dy := r*math.Hypot(m.A, m.C), r*math.Hypot(m.B, m.D)
+		b.add(fpoint{cx - dx, cy - dy})
+		b.add(fpoint{cx + dx, cy + dy})
+	case *SvgRectangle:
+		if e.Width <= 0 || e.Height <= 0 {
+			break
+		}
+		w := 0.0
+		if stroke {
+			w = halfStroke(e.Stroke, e.StrokeWidth)
+		}
+		b.addRect(m, float64(e.X)-w, float64(e.Y)-w, float64(e.X+e.Width)+w, float64(e.Y+e.Height)+w)
+	case *SvgLine:
+		x1, y1, x2, y2 := float64(e.X1), float64(e.Y1), float64(e.X2), float64(e.Y2)
+		if stroke {
+			// The line is drawn one pixel wide, and covers the pixels at both ends
+			b.addRect(m, x1-0.5, y1-0.5, x1+0.5, y1+0.5)
+			b.addRect(m, x2-0.5, y2-0.5, x2+0.5, y2+0.5)
+			break
+		}
+		b.add(m.applyPoint(fpoint{x1, y1}))
+		b.add(m.applyPoint(fpoint{x2, y2}))
+	case *SvgPath:
+		if w := halfStroke(e.Stroke, e.StrokeWidth); stroke && w > 0 {
+			// The stroke is half its width outside the path in user coordinates, so the widened box is transformed
+			if fill := geometryBounds(e, Identity, false); fill.ok {
+				r := fill.rect
+				b.addRect(m, r.X0-w, r.Y0-w, r.X1+w, r.Y1+w)
+			}
+			break
+		}
+		for _, subpath := range e.segments() {
+			for _, seg := range subpath {
+				b.addSegment(seg, m)
+			}
+		}
+	case *SvgGroup:
+		for _, child := range e.Elements {
+			b.merge(transformedBounds(child, m, stroke))
+		}
+	case *SvgText:
+		face := newTextFace(e.FontSize)
+		if x := e.left(face); e.Text != "" {
+			top := float64(e.Y) - face.ascent()
+			b.addRect(m, x, top, x+face.measure(e.Text), top+face.height())
+		}
+	case *SvgTextArea:
+		lines, face, clip := e.layout()
+		for _, line := range lines {
+			top := line.y - face.ascent()
+			x0, y0 := math.Max(line.x, clip.x0), math.Max(top, clip.y0)
+			x1, y1 := math.Min(line.x+face.measure(line.text), clip.x1), math.Min(top+face.height(), clip.y1)
+			if x0 < x1 && y0 < y1 {
+				b.addRect(m, x0, y0, x1, y1)
+			}
+		}
+	case *SvgImage:
+		if e.Image == nil || e.Image.Bounds().Empty() || e.Width <= 0 || e.Height <= 0 {
+			break
+		}
+		_, visible := e.viewportTransform()
+		if visible.x0 < visible.x1 && visible.y0 < visible.y1 {
+			b.addRect(m, visible.x0, visible.y0, visible.x1, visible.y1)
+		}
+	}
+	return b
+}
+
+// halfStroke returns how far the stroke of a shape reaches outside of it, which is half the stroke width,
+// or 0 if the shape has no stroke
+func halfStroke(stroke color.Color, width float64) float64 {
+	if stroke == nil || transparent(stroke) {
+		return 0
+	}
+	return width / 2
+}
+
+// Bounds returns the bounding box of the circle, in its user coordinates without its transform
+func (c SvgCircle) Bounds() Rect {
+	return geometryBounds(&c, Identity, false).rect
+}
+
+// StrokeBounds returns the bounding box of the circle with its stroke, which reaches half the stroke width outside of it
+func (c SvgCircle) StrokeBounds() Rect {
+	return geometryBounds(&c, Identity, true).rect
+}
+
+// Bounds returns the bounding box of the rectangle, in its user coordinates without its transform
+func (r SvgRectangle) Bounds() Rect {
+	return geometryBounds(&r, Identity, false).rect
+}
+
+// StrokeBounds returns the bounding box of the rectangle with its stroke, which reaches half the stroke width outside of it
+func (r SvgRectangle) StrokeBounds() Rect {
+	return geometryBounds(&r, Identity, true).rect
+}
+
+// Bounds returns the bounding box of the line between its end points, in its user coordinates without its transform
+func (l SvgLine) Bounds() Rect {
+	return geometryBounds(&l, Identity, false).rect
+}
+
+// StrokeBounds returns the bounding box of the pixels that the line is drawn with, which includes half a pixel
+// around the end points
+func (l SvgLine) StrokeBounds() Rect {
+	return geometryBounds(&l, Identity, true).rect
+}
+
+// Bounds returns the bounding box of the path, in its user coordinates without its transform.
+// Curves are included exactly, and not by their control points.
+func (p SvgPath) Bounds() Rect {
+	return geometryBounds(&p, Identity, false).rect
+}
+
+// StrokeBounds returns the bounding box of the path with its stroke, which reaches half the stroke width outside of it
+func (p SvgPath) StrokeBounds() Rect {
+	return geometryBounds(&p, Identity, true).rect
+}
+
+// Bounds returns the union of the bounding boxes of the elements in the group, with their transforms,
+// in the user coordinates of the group without its own transform
+func (g SvgGroup) Bounds() Rect {
+	return geometryBounds(&g, Identity, false).rect
+}
+
+// StrokeBounds returns the union of the stroke bounding boxes of the elements in the group
+func (g SvgGroup) StrokeBounds() Rect {
+	return geometryBounds(&g, Identity, true).rect
+}
+
+// Bounds returns the bounding box of the glyphs of the text, in its user coordinates without its transform
+func (t SvgText) Bounds() Rect {
+	return geometryBounds(&t, Identity, false).rect
+}
+
+// StrokeBounds returns the bounding box of the text, which has no stroke
+func (t SvgText) StrokeBounds() Rect {
+	return t.Bounds()
+}
+
+// Bounds returns the bounding box of the visible lines in the text area, in its user coordinates without its transform
+func (t SvgTextArea) Bounds() Rect {
+	return geometryBounds(&t, Identity, false).rect
+}
+
+// StrokeBounds returns the bounding box of the text area, which has no stroke
+func (t SvgTextArea) StrokeBounds() Rect {
+	return t.Bounds()
+}
+
+// Bounds returns the bounding box of the visible part of the image, in its user coordinates without its transform
+func (i SvgImage) Bounds() Rect {
+	return geometryBounds(&i, Identity, false).rect
+}
+
+// StrokeBounds returns the bounding box of the image, which has no stroke
+func (i SvgImage) StrokeBounds() Rect {
+	return i.Bounds()
+}
+
+// Bounds returns the bounding box of all the elements in the document, including strokes,
+// in the user coordinates of the document. This is the zero Rect if nothing is drawn.
+func (d *Document) Bounds() Rect {
+	var b bbox
+	for _, el := range d.Elements {
+		b.merge(transformedBounds(el, Identity, true))
+	}
+	return b.rect
+}
@@ -0,0 +1,100 @@
+package surrender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assertRect(t *testing.T, expected, actual Rect) {
+	t.Helper()
+	assert.InDelta(t, expected.X0, actual.X0, 1e-9, "X0 of %v", actual)
+	assert.InDelta(t, expected.Y0, actual.Y0, 1e-9, "Y0 of %v", actual)
+	assert.InDelta(t, expected.X1, actual.X1, 1e-9, "X1 of %v", actual)
+	assert.InDelta(t, expected.Y1, actual.Y1, 1e-9, "Y1 of %v", actual)
+}
+
+func TestBounds(t *testing.T) {
+	m := Rotate(90)
+	assertRect(t, Rect{5, 10, 15, 20}, SvgCircle{Cx: 10, Cy: 15, R: 5}.Bounds())
+	assertRect(t, Rect{1, 2, 4, 6}, SvgRectangle{X: 1, Y: 2, Width: 3, Height: 4}.Bounds())
+	// The own transform is not included
+	assertRect(t, Rect{1, 2, 4, 6}, SvgRectangle{Common: Common{Transform: &m}, X: 1, Y: 2, Width: 3, Height: 4}.Bounds())
+	assertRect(t, Rect{0, 0, 0, 0}, SvgRectangle{}.Bounds())
+
+	line := SvgLine{X1: 2, Y1: 8, X2: 6, Y2: 8}
+	assertRect(t, Rect{2, 8, 6, 8}, line.Bounds())
+	assertRect(t, Rect{1.5, 7.5, 6.5, 8.5}, line.StrokeBounds())
+}
+
+func TestStrokeBounds(t *testing.T) {
+	// Stroked shapes reach half the stroke width outside of their fill
+	doc, err := ParseBytes([]byte(`<svg xmlns="http://www.w3.org/2000/svg">` +
+		`<rect x="10" y="10" width="20" height="10" stroke="blue" stroke-width="4"/>` +
+		`<circle cx="50" cy="50" r="5" stroke="red"/>` +
+		`<path d="M0 0 H10 V10 Z" stroke="red" stroke-width="2" transform="scale(2)"/>` +
+		`<rect width="5" height="5" stroke="none" stroke-width="4"/></svg>`))
+	assert.NoError(t, err)
+	rect := doc.Elements[0].(*SvgRectangle)
+	assertRect(t, Rect{10, 10, 30, 20}, rect.Bounds())
+	assertRect(t, Rect{8, 8, 32, 22}, rect.StrokeBounds())
+	assertRect(t, Rect{44.5, 44.5, 55.5, 55.5}, doc.Elements[1].(*SvgCircle).StrokeBounds())
+	assertRect(t, Rect{-1, -1, 11, 11}, doc.Elements[2].(*SvgPath).StrokeBounds())
+	assertRect(t, Rect{0, 0, 5, 5}, doc.Elements[3].(*SvgRectangle).StrokeBounds())
+
+	// The transform of a stroked element scales its stroke too, within a group
+	group := SvgGroup{Elements: doc.Elements[2:3]}
+	assertRect(t, Rect{-2, -2, 22, 22}, group.StrokeBounds())
+}
+
+func TestPathBounds(t *testing.T) {
+	// The control points are far outside of the curve, which only reaches y = 25
+	path, err := ParsePath("M0 100 C0 0 100 0 100 100")
+	assert.NoError(t, err)
+	assertRect(t, Rect{0, 25, 100, 100}, path.Bounds())
+
+	path, err = ParsePath("M0 0 Q50 100 100 0 Z")
+	assert.NoError(t, err)
+	assertRect(t, Rect{0, 0, 100, 50}, path.Bounds())
+
+	// Relative and smooth curves, and lines
+	path, err = ParsePath("M10 10 h20 v20 l-20 0 z")
+	assert.NoError(t, err)
+	assertRect(t, Rect{10, 10, 30, 30}, path.Bounds())
+}
+
+func TestGroupBounds(t *testing.T) {
+	rotate := Rotate(90)
+	translate := Translate(100, 0)
+	scale := Scale(2, 2)
+	group := SvgGroup{
+		Common: Common{Transform: &scale},
+		Elements: []SvgElement{
+			&SvgRectangle{X: 0, Y: 0, Width: 10, Height: 20},
+			// Rotated around the origin, the circle ends up at (-5, 5)
+			&SvgCircle{Common: Common{Transform: &rotate}, Cx: 5, Cy: 5, R: 2},
+			&SvgGroup{Common: Common{Transform: &translate}, Elements: []SvgElement{
+				&SvgLine{X1: 0, Y1: 0, X2: 5, Y2: 0},
+			}},
+			// Elements without area do not pull the bounds towards the origin
+			&SvgGroup{},
+		},
+	}
+	assertRect(t, Rect{-7, 0, 105, 20}, group.Bounds())
+	assertRect(t, Rect{-7, -0.5, 105.5, 20}, group.StrokeBounds())
+
+	// The bounds of the rotated circle are tight, also when the rotation is not a multiple of 90 degrees
+	tilted := Rotate(30)
+	circle := &SvgCircle{Common: Common{Transform: &tilted}, R: 10}
+	assertRect(t, Rect{-10, -10, 10, 10}, SvgGroup{Elements: []SvgElement{circle}}.Bounds())
+}
+
+func TestDocumentBounds(t *testing.T) {
+	doc, err := ParseFile("testdata/sprites.svg")
+	assert.NoError(t, err)
+	assertRect(t, Rect{0, 0, 40, 20}, doc.Bounds())
+
+	doc, err = ParseFile("testdata/empty.svg")
+	assert.NoError(t, err)
+	assert.Equal(t, Rect{}, doc.Bounds())
+}
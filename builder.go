@@ -0,0 +1,184 @@
+package surrender
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Builder builds a Document in Go code, as an alternative to parsing one. The methods that add elements
+// return the builder, so that calls can be chained, and the methods like ID, Fill and Attr change the
+// element that was added last. The first error is kept and returned by Build.
+type Builder struct {
+	doc      *Document
+	elements *[]SvgElement // where new elements are added
+	last     SvgElement    // the element that was added last, or nil
+	err      *error        // shared with the builders of groups
+}
+
+// NewDocument returns a builder for a document with the given size in pixels
+func NewDocument(width, height int) *Builder {
+	doc := &Document{
+		Width:               width,
+		Height:              height,
+		PreserveAspectRatio: "xMidYMid meet",
+		Version:             "1.2",
+		BaseProfile:         "tiny",
+	}
+	return &Builder{doc: doc, elements: &doc.Elements, err: new(error)}
+}
+
+// Build returns the document, or the first error that occurred while building it
+func (b *Builder) Build() (*Document, error) {
+	if *b.err != nil {
+		return nil, *b.err
+	}
+	b.doc.index()
+	return b.doc, nil
+}
+
+// fail keeps the first error
+func (b *Builder) fail(err error) {
+	if *b.err == nil {
+		*b.err = err
+	}
+}
+
+// ViewBox sets the view box of the document
+func (b *Builder) ViewBox(x, y, width, height float64) *Builder {
+	b.doc.ViewBox = &ViewBox{x, y, width, height}
+	return b
+}
+
+// Title sets the title of the document
+func (b *Builder) Title(title string) *Builder {
+	b.doc.Title = title
+	return b
+}
+
+// Description sets the description of the document
+func (b *Builder) Description(description string) *Builder {
+	b.doc.Description = description
+	return b
+}
+
+// Element adds any element, like an *SvgAnimation that refers to another element by its id
+func (b *Builder) Element(el SvgElement) *Builder {
+	*b.elements = append(*b.elements, el)
+	b.last = el
+	return b
+}
+
+// Rect adds a rectangle, where a nil fill color is inherited from the parent group
+func (b *Builder) Rect(x, y, width, height int, fill color.Color) *Builder {
+	return b.Element(&SvgRectangle{X: x, Y: y, Width: width, Height: height, Fill: fill})
+}
+
+// Circle adds a circle, where a nil fill color is inherited from the parent group
+func (b *Builder) Circle(cx, cy, r int, fill color.Color) *Builder {
+	return b.Element(&SvgCircle{Cx: cx, Cy: cy, R: r, Fill: fill})
+}
+
+// Line adds a line with the given stroke color
+func (b *Builder) Line(x1, y1, x2, y2 int, stroke color.Color) *Builder {
+	return b.Element(&SvgLine{X1: x1, Y1: y1, X2: x2, Y2: y2, Stroke: stroke})
+}
+
+// Path adds a path with the given path data, where a nil fill color is inherited from the parent group
+func (b *Builder) Path(d string, fill color.Color) *Builder {
+	path, err := ParsePath(d)
+	if err != nil {
+		b.fail(err)
+	}
+	path.Fill = fill
+	return b.Element(&path)
+}
+
+// Text adds a line of text, with the default font size. Use Attr to set "font-size" or "text-anchor".
+func (b *Builder) Text(x, y int, text string, fill color.Color) *Builder {
+	return b.Element(&SvgText{X: x, Y: y, FontSize: defaultFontSize, Anchor: "start", Text: text, Fill: fill})
+}
+
+// TextArea adds a text area, where the width and height can be AutoSize and "\n" starts a new line
+func (b *Builder) TextArea(x, y, width, height int, text string, fill color.Color) *Builder {
+	return b.Element(&SvgTextArea{
+		X:            x,
+		Y:            y,
+		Width:        width,
+		Height:       height,
+		FontSize:     defaultFontSize,
+		DisplayAlign: "auto",
+		TextAlign:    "start",
+		Text:         text,
+		Fill:         fill,
+	})
+}
+
+// Image adds an image, which is kept as a PNG data URI when the document is written
+func (b *Builder) Image(x, y, width, height int, img image.Image) *Builder {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		b.fail(err)
+	}
+	href := "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+	return b.Element(&SvgImage{X: x, Y: y, Width: width, Height: height, PreserveAspectRatio: "xMidYMid meet", Opacity: 1, Href: href, Image: img})
+}
+
+// Group adds a group, and calls f with a builder that adds elements to the group
+func (b *Builder) Group(f func(g *Builder)) *Builder {
+	group := &SvgGroup{}
+	f(&Builder{doc: b.doc, elements: &group.Elements, err: b.err})
+	return b.Element(group)
+}
+
+// Defs calls f with a builder that adds elements to the defs of the document, which are only rendered
+// when referred to
+func (b *Builder) Defs(f func(d *Builder)) *Builder {
+	f(&Builder{doc: b.doc, elements: &b.doc.Defs, err: b.err})
+	return b
+}
+
+// Attr sets an attribute or property of the element that was added last, like "font-size", "visibility" or
+// "shape-rendering", in the same way as SetTrait. Properties that are set on a group are also set on its children.
+func (b *Builder) Attr(name, value string) *Builder {
+	if b.last == nil {
+		b.fail(fmt.Errorf("no element to set %s on", name))
+		return b
+	}
+	if err := b.doc.SetTrait(b.last, name, value); err != nil {
+		b.fail(err)
+	}
+	return b
+}
+
+// ID sets the id of the element that was added last
+func (b *Builder) ID(id string) *Builder {
+	return b.Attr("id", id)
+}
+
+// Fill sets the fill color of the element that was added last, where nil is inherited from the parent group
+func (b *Builder) Fill(c color.Color) *Builder {
+	if !hasFill(b.last) {
+		b.fail(fmt.Errorf("%w: fill on %T", ErrNotSupported, b.last))
+		return b
+	}
+	replaceElement(b.last, changed(b.last, func(_ *Common, fill *color.Color) {
+		*fill = c
+	}))
+	return b
+}
+
+// Transform sets the transform of the element that was added last
+func (b *Builder) Transform(m Matrix) *Builder {
+	if _, ok := b.last.(animatable); !ok {
+		b.fail(fmt.Errorf("%w: transform on %T", ErrNotSupported, b.last))
+		return b
+	}
+	replaceElement(b.last, changed(b.last, func(c *Common, _ *color.Color) {
+		c.Transform = &m
+	}))
+	return b
+}
@@ -0,0 +1,85 @@
+package surrender
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder(t *testing.T) {
+	built, err := NewDocument(100, 20).
+		Title("Badge").
+		Rect(0, 0, 100, 20, GetColor("#555")).ID("background").
+		Group(func(g *Builder) {
+			g.Rect(60, 0, 40, 20, nil).
+				Text(80, 14, "ok", GetColor("white")).Attr("text-anchor", "middle")
+		}).Fill(GetColor("#4c1")).Attr("visibility", "hidden").
+		Line(0, 19, 100, 19, GetColor("black")).
+		Path("M0 0 L10 10 Z", nil).Transform(Translate(5, 5)).
+		Build()
+	assert.NoError(t, err)
+
+	// The same document as the parser returns
+	parsed, err := Parse(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" version="1.2" baseProfile="tiny" width="100" height="20">
+  <title>Badge</title>
+  <rect id="background" x="0" y="0" width="100" height="20" fill="#555"/>
+  <g fill="#4c1" visibility="hidden">
+    <rect x="60" y="0" width="40" height="20"/>
+    <text x="80" y="14" fill="white" text-anchor="middle">ok</text>
+  </g>
+  <line x1="0" y1="19" x2="100" y2="19" stroke="black"/>
+  <path d="M0 0 L10 10 Z" transform="translate(5 5)"/>
+</svg>`))
+	assert.NoError(t, err)
+	// Only parsed documents know where their elements are in the source
+	parsed.source = nil
+	assert.Equal(t, parsed, built)
+	assert.NotNil(t, built.ElementByID("background"))
+
+	// It can be written and parsed again
+	var buf bytes.Buffer
+	_, err = built.WriteTo(&buf)
+	assert.NoError(t, err)
+	written, err := Parse(&buf)
+	assert.NoError(t, err)
+	written.source = nil
+	assert.Equal(t, built, written)
+}
+
+func TestBuilderImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, red)
+	doc, err := NewDocument(4, 4).Image(0, 0, 4, 4, img).Attr("image-rendering", "pixelated").Build()
+	assert.NoError(t, err)
+	rendered, err := RenderDocument(doc, RenderOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, red, rendered.RGBAAt(1, 1))
+	assert.Equal(t, color.RGBA{}, rendered.RGBAAt(3, 3))
+
+	// The image is written as a data URI
+	var buf bytes.Buffer
+	_, err = doc.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `xlink:href="data:image/png;base64,`)
+}
+
+func TestBuilderErrors(t *testing.T) {
+	_, err := NewDocument(10, 10).Path("M0 0 L", nil).Build()
+	assert.Error(t, err)
+	_, err = NewDocument(10, 10).Rect(0, 0, 1, 1, nil).Attr("r", "1").Build()
+	assert.True(t, errors.Is(err, ErrNotSupported))
+	_, err = NewDocument(10, 10).Line(0, 0, 1, 1, nil).Fill(red).Build()
+	assert.True(t, errors.Is(err, ErrNotSupported))
+	_, err = NewDocument(10, 10).ID("nothing").Build()
+	assert.Error(t, err)
+	// The first error is kept
+	_, err = NewDocument(10, 10).Group(func(g *Builder) {
+		g.Circle(1, 1, 1, nil).Attr("visibility", "maybe")
+	}).Attr("cx", "1").Build()
+	assert.True(t, errors.Is(err, ErrTypeMismatch))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/xyproto/surrender"
+)
+
+func main() {
+	maxSize := flag.Int64("max-decompressed", surrender.DefaultMaxDecompressedSize, "the largest number of bytes that compressed .svgz input may decompress to")
+	indent := flag.String("indent", "", "the indentation for each level of nested elements (default: no line breaks)")
+	precision := flag.Int("precision", 0, "the largest number of decimals for numbers that are not whole (default: exact)")
+	flag.Usage = func() {
+		fmt.Println("Usage: ./optimize [options] input.svg|- output.svg|-")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		flag.Usage()
+		return
+	}
+
+	inputFile := flag.Arg(0)
+	outputFile := flag.Arg(1)
+
+	// Read and parse the SVG or SVGZ file, or standard input if the filename is "-"
+	options := surrender.ParseOptions{MaxDecompressedSize: *maxSize}
+	var doc *surrender.Document
+	var err error
+	if inputFile == "-" {
+		doc, err = surrender.ParseWithOptions(os.Stdin, options)
+	} else {
+		doc, err = surrender.ParseFileWithOptions(inputFile, options)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading or parsing file: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The optimized document renders to the same pixels as the original one
+	var buf bytes.Buffer
+	if _, err := surrender.Optimize(doc).WriteWithOptions(&buf, surrender.WriteOptions{
+		Indent:       *indent,
+		Precision:    *precision,
+		MinimalPaths: true,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing SVG: %v\n", err)
+		os.Exit(1)
+	}
+	if outputFile == "-" {
+		if _, err := buf.WriteTo(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing SVG: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := os.WriteFile(outputFile, buf.Bytes(), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving SVG: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Successfully created SVG file: %s (%d bytes)\n", outputFile, buf.Len())
+}
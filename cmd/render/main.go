@@ -1,38 +1,162 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"image/color"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/xyproto/surrender"
 )
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: ./render input.svg output.png")
+	fps := flag.Float64("fps", 10, "frames per second, when rendering an animated GIF or APNG")
+	start := flag.Duration("start", 0, "when to start the animation, when rendering an animated GIF or APNG")
+	end := flag.Duration("end", 0, "when to end the animation, when rendering an animated GIF or APNG (default: the end of the animations)")
+	loop := flag.Int("loop", 0, "0 loops forever, -1 plays once and n plays n+1 times, when rendering an animated GIF")
+	apng := flag.Bool("apng", false, "render an Animated PNG, which is also used for the .apng extension")
+	plays := flag.Int("plays", 0, "how many times to play an Animated PNG, where 0 means forever")
+	shared := flag.Bool("shared-palette", false, "use one palette for all frames, when rendering an animated GIF")
+	maxSize := flag.Int64("max-decompressed", surrender.DefaultMaxDecompressedSize, "the largest number of bytes that the input may have, or that compressed .svgz input may decompress to")
+	at := flag.Duration("t", 0, "the time in the animations to render, when rendering a PNG")
+	bg := flag.String("bg", "black", "the background color, like \"white\" or \"#336699\", or \"transparent\"")
+	width := flag.Int("width", 0, "the width of the PNG, in pixels (default: the width of the document)")
+	height := flag.Int("height", 0, "the height of the PNG, in pixels (default: the height of the document)")
+	scale := flag.Float64("scale", 1, "how much to scale the document, when rendering a PNG without -width and -height")
+	dpi := flag.Float64("dpi", 96, "the resolution of the PNG, when rendering a PNG without -width and -height")
+	fit := flag.String("fit", "contain", "how the document fits a -width and -height with another aspect ratio: contain, cover or stretch")
+	antialias := flag.Bool("antialias", false, "antialias the edges of filled shapes, when rendering a PNG")
+	tolerance := flag.Float64("tolerance", 0.25, "the largest distance between a curve and its line segments, in pixels, when rendering a PNG")
+	strict := flag.Bool("strict", false, "fail on the first error in the document, instead of rendering the elements that can be parsed")
+	maxPixels := flag.Int("max-pixels", surrender.DefaultMaxPixels, "the largest number of pixels the PNG, or each frame of a GIF or APNG, may have")
+	timeout := flag.Duration("timeout", 0, "the longest time rendering a PNG, GIF or APNG may take (default: no limit)")
+	flag.Usage = func() {
+		fmt.Println("Usage: ./render [options] input.svg|- output.png|output.gif|output.apng|-")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		flag.Usage()
 		return
 	}
 
-	inputFile := os.Args[1]
-	outputFile := os.Args[2]
+	inputFile := flag.Arg(0)
+	outputFile := flag.Arg(1)
 
-	// Read and parse the SVG file
-	elements, err := surrender.ParseFile(inputFile)
+	// Read and parse the SVG or SVGZ file, or standard input if the filename is "-"
+	options := surrender.ParseOptions{MaxDecompressedSize: *maxSize}
+	if *strict {
+		options.Mode = surrender.ParseStrict
+	}
+	var doc *surrender.Document
+	var err error
+	if inputFile == "-" {
+		doc, err = surrender.ParseWithOptions(os.Stdin, options)
+	} else {
+		doc, err = surrender.ParseFileWithOptions(inputFile, options)
+	}
 	if err != nil {
 		fmt.Printf("Error reading or parsing file: %v\n", err)
+		os.Exit(1)
+	}
+	for _, d := range doc.Diagnostics {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", inputFile, d)
+	}
+
+	// Use a black background by default, and a transparent one if asked for
+	var bgColor color.Color
+	if *bg != "transparent" && *bg != "none" {
+		bgColor = surrender.GetColor(*bg)
+	}
+
+	fitModes := map[string]surrender.FitMode{
+		"contain": surrender.FitContain,
+		"cover":   surrender.FitCover,
+		"stretch": surrender.FitStretch,
+	}
+	fitMode, ok := fitModes[*fit]
+	if !ok {
+		fmt.Printf("Unknown fit mode: %s\n", *fit)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	ext := strings.ToLower(filepath.Ext(outputFile))
+	if *apng || ext == ".apng" {
+		opts := surrender.APNGOptions{
+			FPS:        *fps,
+			Start:      *start,
+			End:        *end,
+			Plays:      *plays,
+			Background: bgColor,
+			MaxPixels:  *maxPixels,
+			Context:    ctx,
+		}
+		if err := surrender.SaveAPNG(doc, outputFile, opts); err != nil {
+			fmt.Printf("Error rendering and saving APNG: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Successfully created APNG file:", outputFile)
 		return
 	}
 
-	// Use a black background and a red circle
-	bgColor := color.RGBA{0, 0, 0, 255}
+	if ext == ".gif" {
+		opts := surrender.GIFOptions{
+			FPS:           *fps,
+			Start:         *start,
+			End:           *end,
+			LoopCount:     *loop,
+			SharedPalette: *shared,
+			Background:    bgColor,
+			MaxPixels:     *maxPixels,
+			Context:       ctx,
+		}
+		if err := surrender.SaveGIF(doc, outputFile, opts); err != nil {
+			fmt.Printf("Error rendering and saving GIF: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Successfully created GIF file:", outputFile)
+		return
+	}
 
-	// Render and save the SVG elements to a PNG file
-	err = surrender.RenderAndSaveSVG(elements, outputFile, bgColor)
+	// Render and save the SVG elements to a PNG file, or write it to standard output if the filename is "-"
+	img, err := surrender.RenderDocument(doc.At(*at), surrender.RenderOptions{
+		Width:      *width,
+		Height:     *height,
+		Scale:      *scale,
+		DPI:        *dpi,
+		Background: bgColor,
+		Fit:        fitMode,
+		Tolerance:  *tolerance,
+		Antialias:  *antialias,
+		MaxPixels:  *maxPixels,
+		Context:    ctx,
+	})
 	if err != nil {
-		fmt.Printf("Error rendering and saving SVG: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error rendering SVG: %v\n", err)
+		os.Exit(1)
+	}
+	if outputFile == "-" {
+		if err := surrender.WritePNG(os.Stdout, img); err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering and writing SVG: %v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
+	if err := surrender.SavePNG(img, outputFile); err != nil {
+		fmt.Printf("Error rendering and saving SVG: %v\n", err)
+		os.Exit(1)
+	}
 
 	fmt.Println("Successfully created PNG file:", outputFile)
 }
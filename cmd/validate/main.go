@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/xyproto/surrender"
+)
+
+func main() {
+	quiet := flag.Bool("quiet", false, "only report errors, and not warnings")
+	flag.Usage = func() {
+		fmt.Println("Usage: ./validate [options] input.svg|- [input.svg...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		return
+	}
+
+	// Check each SVG or SVGZ file, or standard input if the filename is "-"
+	invalid := false
+	for _, inputFile := range flag.Args() {
+		var diagnostics []surrender.Diagnostic
+		var err error
+		if inputFile == "-" {
+			diagnostics, err = surrender.ValidateReader(os.Stdin)
+		} else {
+			diagnostics, err = surrender.ValidateFile(inputFile)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading or parsing file: %s: %v\n", inputFile, err)
+			invalid = true
+			continue
+		}
+		for _, d := range diagnostics {
+			if d.Severity == surrender.SeverityError {
+				invalid = true
+			} else if *quiet {
+				continue
+			}
+			fmt.Printf("%s:%s\n", inputFile, d)
+		}
+	}
+	if invalid {
+		os.Exit(1)
+	}
+}
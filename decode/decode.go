@@ -0,0 +1,55 @@
+// Package decode registers TinySVG 1.2 as a format for image.Decode and image.DecodeConfig.
+// Import it for its side effects:
+//
+//	import _ "github.com/xyproto/surrender/decode"
+//
+// Documents are recognized by starting with "<svg" or "<?xml", and are rendered at their
+// intrinsic size, on a transparent background. Documents that are larger than
+// surrender.DefaultMaxPixels give an error that wraps surrender.ErrTooManyPixels, both when
+// decoding and when decoding the config, so that untrusted images can not use up the memory.
+package decode
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/xyproto/surrender"
+)
+
+func init() {
+	image.RegisterFormat("svg", "<svg", Decode, DecodeConfig)
+	image.RegisterFormat("svg", "<?xml", Decode, DecodeConfig)
+}
+
+// Decode parses a TinySVG 1.2 document and renders it at its intrinsic size
+func Decode(r io.Reader) (image.Image, error) {
+	doc, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return surrender.RenderDocument(doc, surrender.RenderOptions{})
+}
+
+// DecodeConfig parses a TinySVG 1.2 document and returns its intrinsic size
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	doc, err := parse(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.RGBAModel, Width: doc.Width, Height: doc.Height}, nil
+}
+
+// parse parses a TinySVG 1.2 document, and checks that its intrinsic size is within the pixel
+// limit that RenderDocument uses by default
+func parse(r io.Reader) (*surrender.Document, error) {
+	doc, err := surrender.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	if float64(doc.Width)*float64(doc.Height) > surrender.DefaultMaxPixels {
+		return nil, fmt.Errorf("%w: %d x %d is more than %d", surrender.ErrTooManyPixels, doc.Width, doc.Height, surrender.DefaultMaxPixels)
+	}
+	return doc, nil
+}
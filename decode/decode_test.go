@@ -0,0 +1,44 @@
+package decode
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/surrender"
+)
+
+func TestDecode(t *testing.T) {
+	f, err := os.Open("../testdata/circle.svg")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	img, format, err := image.Decode(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "svg", format)
+	assert.Equal(t, image.Rect(0, 0, 100, 100), img.Bounds())
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, img.At(50, 50))
+	assert.Equal(t, color.RGBA{}, img.At(0, 0))
+}
+
+func TestDecodeConfig(t *testing.T) {
+	svg := `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 20 10" width="40"/>`
+	config, format, err := image.DecodeConfig(strings.NewReader(svg))
+	assert.NoError(t, err)
+	assert.Equal(t, "svg", format)
+	assert.Equal(t, 40, config.Width)
+	assert.Equal(t, 20, config.Height)
+	assert.Equal(t, color.RGBAModel, config.ColorModel)
+}
+
+func TestDecodeLimits(t *testing.T) {
+	// The size is checked before rendering, and before the config is returned
+	huge := `<svg xmlns="http://www.w3.org/2000/svg" width="100000" height="100000"/>`
+	_, _, err := image.DecodeConfig(strings.NewReader(huge))
+	assert.ErrorIs(t, err, surrender.ErrTooManyPixels)
+	_, _, err = image.Decode(strings.NewReader(huge))
+	assert.ErrorIs(t, err, surrender.ErrTooManyPixels)
+}
@@ -0,0 +1,173 @@
+package surrender
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// Severity tells how serious a diagnostic is
+type Severity int
+
+const (
+	// SeverityWarning is used for problems that the document can still be used with
+	SeverityWarning Severity = iota
+	// SeverityError is used for problems that make the document invalid
+	SeverityError
+)
+
+// String returns "warning" or "error"
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Diagnostic is a problem that was found in a document, at a position in the source
+type Diagnostic struct {
+	Severity     Severity
+	Path         string // the path of the element, like "/svg/g[2]/rect[1]"
+	Element      string // the name of the element, like "rect"
+	Attribute    string // the attribute that has the problem, or ""
+	Value        string // the value of the attribute, or ""
+	Line, Column int    // the position of the element in the source, starting at 1, or 0 if it is not known
+	Message      string
+}
+
+// String formats the diagnostic as "line:column: severity: path: message"
+func (d Diagnostic) String() string {
+	path := d.Path
+	if d.Attribute != "" {
+		path += "@" + d.Attribute
+	}
+	return fmt.Sprintf("%d:%d: %s: %s: %s", d.Line, d.Column, d.Severity, path, d.Message)
+}
+
+// position is a line and column in the source of a document, starting at 1
+type position struct {
+	line, column int
+}
+
+// location is where a parsed element is in the source, with its path, like "/svg/g[2]/rect[1]"
+type location struct {
+	path string
+	position
+}
+
+// source is what a parsed document was parsed from, which Validate checks
+type source struct {
+	tree      *etree.Document
+	positions map[*etree.Element]position
+	locations map[SvgElement]location // where the parsed elements are in the source
+}
+
+// elementPositions finds where each element of the parsed document starts in the source it was parsed from.
+// The source is read again with encoding/xml, which reports positions, and the start elements are matched
+// with the elements of the document in the order they appear.
+func elementPositions(doc *etree.Document, data []byte) map[*etree.Element]position {
+	var starts []position
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		line, column := dec.InputPos()
+		token, err := dec.RawToken()
+		if err != nil {
+			break
+		}
+		if _, ok := token.(xml.StartElement); ok {
+			starts = append(starts, position{line, column})
+		}
+	}
+	positions := make(map[*etree.Element]position)
+	var walk func(el *etree.Element)
+	walk = func(el *etree.Element) {
+		if len(starts) == 0 {
+			return
+		}
+		positions[el], starts = starts[0], starts[1:]
+		for _, child := range el.ChildElements() {
+			walk(child)
+		}
+	}
+	for _, el := range doc.ChildElements() {
+		walk(el)
+	}
+	return positions
+}
+
+// elementPath returns the path of an element from the root, like "/svg/g[2]/rect[1]", where the numbers
+// count the elements with the same name, starting at 1
+func elementPath(el *etree.Element) string {
+	var parts []string
+	for ; el != nil; el = el.Parent() {
+		parent := el.Parent()
+		if parent == nil || parent.Tag == "" && parent.Parent() == nil {
+			parts = append(parts, el.FullTag())
+			break
+		}
+		n := 0
+		for _, sibling := range parent.ChildElements() {
+			if sibling.FullTag() == el.FullTag() {
+				n++
+			}
+			if sibling == el {
+				break
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%s[%d]", el.FullTag(), n))
+	}
+	var sb strings.Builder
+	for i := len(parts) - 1; i >= 0; i-- {
+		sb.WriteString("/" + parts[i])
+	}
+	return sb.String()
+}
+
+// ParseMode tells what the parser does when it finds an error
+type ParseMode int
+
+const (
+	// ParseLenient skips the elements that can not be used, uses the default value for invalid attributes,
+	// and keeps the problems in Document.Diagnostics. This is the default.
+	ParseLenient ParseMode = iota
+	// ParseStrict stops parsing at the first error, and returns it as a *ParseError
+	ParseStrict
+)
+
+// ParseError is an error that was found while parsing, at a position in the source
+type ParseError struct {
+	Diagnostic
+	Err error // the underlying error
+}
+
+func (e *ParseError) Error() string {
+	return e.Diagnostic.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// report records a problem with an element, or with the named attribute of it if name is not "".
+// In strict mode, the first error is kept as a *ParseError, which stops parsing.
+func (p *parser) report(severity Severity, el *etree.Element, name string, err error) {
+	pos := p.positions[el]
+	d := Diagnostic{
+		Severity: severity,
+		Path:     p.elementPath(el),
+		Element:  el.FullTag(),
+		Line:     pos.line,
+		Column:   pos.column,
+		Message:  err.Error(),
+	}
+	if name != "" {
+		d.Attribute, d.Value = name, el.SelectAttrValue(name, "")
+	}
+	p.diagnostics = append(p.diagnostics, d)
+	if severity == SeverityError && p.options.Mode == ParseStrict && p.err == nil {
+		p.err = &ParseError{Diagnostic: d, Err: err}
+	}
+}
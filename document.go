@@ -0,0 +1,288 @@
+package surrender
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/draw"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// defaultSize is the width and height that is used when the document does not declare its size
+const defaultSize = 512
+
+// ViewBox is the area of the user coordinate system that is stretched to fit the viewport
+type ViewBox struct {
+	X, Y, Width, Height float64
+}
+
+// Document is a parsed TinySVG 1.2 document, with the attributes of the root svg element
+type Document struct {
+	Width, Height       int      // the size of the viewport, in pixels
+	ViewBox             *ViewBox // nil if the document has no viewBox attribute
+	PreserveAspectRatio string   // how the view box is fitted into the viewport, "xMidYMid meet" by default
+	Version             string   // for example "1.2"
+	BaseProfile         string   // for example "tiny"
+	Title               string   // the text of the title element, if any
+	Description         string   // the text of the desc element, if any
+	Metadata            string   // the contents of the metadata element, as XML, if any
+	Elements            []SvgElement
+	Defs                []SvgElement // the elements in defs elements, which are only rendered when referred to
+	Diagnostics         []Diagnostic // the problems that were found while parsing, which are only warnings in strict mode
+
+	ids    map[string]SvgElement
+	source *source // what the document was parsed from, or nil for documents that were not parsed
+}
+
+// ErrNoSVGElement is returned, in a *DocumentError, when the root element of a document is not svg
+var ErrNoSVGElement = errors.New("the root element is not svg")
+
+// DocumentError is an error that the whole document is in, like XML that is not well-formed, a root element
+// that is not svg or an invalid viewBox on the root svg element. Nothing of such a document is rendered.
+type DocumentError struct {
+	Err error
+}
+
+func (e *DocumentError) Error() string {
+	return "invalid SVG document: " + e.Err.Error()
+}
+
+func (e *DocumentError) Unwrap() error {
+	return e.Err
+}
+
+// ParseFile will try to parse the given TinySVG 1.2 file into a Document
+func ParseFile(filename string) (*Document, error) {
+	return ParseFileWithOptions(filename, ParseOptions{})
+}
+
+// ParseFileWithOptions will try to parse the given TinySVG 1.2 file into a Document, using the given options
+func ParseFileWithOptions(filename string, options ParseOptions) (*Document, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseWithOptions(f, options)
+}
+
+// Parse will try to parse a TinySVG 1.2 document from the given reader
+func Parse(r io.Reader) (*Document, error) {
+	return ParseWithOptions(r, ParseOptions{})
+}
+
+// ParseBytes will try to parse a TinySVG 1.2 document from the given data
+func ParseBytes(data []byte) (*Document, error) {
+	return ParseWithOptions(bytes.NewReader(data), ParseOptions{})
+}
+
+// ParseFS will try to parse the named TinySVG 1.2 file in the given file system, like an embed.FS
+func ParseFS(fsys fs.FS, name string) (*Document, error) {
+	return ParseFSWithOptions(fsys, name, ParseOptions{})
+}
+
+// ParseFSWithOptions will try to parse the named TinySVG 1.2 file in the given file system, using the given options
+func ParseFSWithOptions(fsys fs.FS, name string, options ParseOptions) (*Document, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseWithOptions(f, options)
+}
+
+// ParseWithOptions will try to parse a TinySVG 1.2 document from the given reader, using the given options.
+// All the other parse functions end up here. Gzip compressed input, as in .svgz files, is decompressed first.
+// A *DocumentError is returned if nothing of the document can be used. In strict mode, an element that is in
+// error gives a *ParseError, together with a document that has the elements before it, as they are rendered.
+func ParseWithOptions(r io.Reader, options ParseOptions) (*Document, error) {
+	r, err := decompress(r, options.MaxDecompressedSize)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return nil, &DocumentError{Err: err}
+	}
+	p := &parser{options: options, positions: elementPositions(doc, data)}
+	return p.parseDocument(doc)
+}
+
+// parseDocument parses the root svg element and all the elements in it
+func (p *parser) parseDocument(doc *etree.Document) (*Document, error) {
+	root := doc.Root()
+	if root == nil || root.Tag != "svg" {
+		return nil, &DocumentError{Err: ErrNoSVGElement}
+	}
+	if err := p.checkElements(root); err != nil {
+		return nil, err
+	}
+	if err := p.checkUses(root); err != nil {
+		return nil, err
+	}
+
+	d := &Document{
+		Width:               defaultSize,
+		Height:              defaultSize,
+		PreserveAspectRatio: strings.TrimSpace(root.SelectAttrValue("preserveAspectRatio", "xMidYMid meet")),
+		Version:             root.SelectAttrValue("version", ""),
+		BaseProfile:         root.SelectAttrValue("baseProfile", ""),
+	}
+	if attr := root.SelectAttr("viewBox"); attr != nil {
+		numbers, err := parseNumberList(attr.Value)
+		if err != nil || len(numbers) != 4 || numbers[2] < 0 || numbers[3] < 0 {
+			p.report(SeverityError, root, "viewBox", fmt.Errorf("invalid viewBox: %q", attr.Value))
+			if p.err != nil {
+				return nil, &DocumentError{Err: p.err}
+			}
+		} else {
+			d.ViewBox = &ViewBox{numbers[0], numbers[1], numbers[2], numbers[3]}
+			// Without a width and height, the viewport has the size of the view box
+			d.Width, d.Height = roundCoordinate(numbers[2]), roundCoordinate(numbers[3])
+		}
+	}
+	w, hasWidth := p.rootLength(root, "width")
+	h, hasHeight := p.rootLength(root, "height")
+	if hasWidth {
+		d.Width = w
+	}
+	if hasHeight {
+		d.Height = h
+	}
+	// If only one of width and height is set, the other one follows the aspect ratio of the view box
+	if vb := d.ViewBox; vb != nil && vb.Width > 0 && vb.Height > 0 && hasWidth != hasHeight {
+		if hasWidth {
+			d.Height = roundCoordinate(float64(w) * vb.Height / vb.Width)
+		} else {
+			d.Width = roundCoordinate(float64(h) * vb.Width / vb.Height)
+		}
+	}
+	if el := root.SelectElement("title"); el != nil {
+		d.Title = strings.TrimSpace(el.Text())
+	}
+	if el := root.SelectElement("desc"); el != nil {
+		d.Description = strings.TrimSpace(el.Text())
+	}
+	if el := root.SelectElement("metadata"); el != nil {
+		var sb strings.Builder
+		for _, child := range el.Child {
+			child.WriteTo(&sb, &doc.WriteSettings)
+		}
+		d.Metadata = strings.TrimSpace(sb.String())
+	}
+
+	elements, err := p.parseElements(root.ChildElements(), defaultStyle())
+	if p.limit != nil {
+		return nil, p.limit
+	}
+	d.Elements = elements
+	d.Defs = p.defs
+	d.Diagnostics = p.diagnostics
+	d.source = &source{tree: doc, positions: p.positions, locations: p.locations}
+	d.index()
+	return d, err
+}
+
+// rootLength parses the width or height of the root svg element, in pixels, where units like "mm"
+// are converted at 96 DPI. Percentages and other values that can not be used for the image size are
+// skipped, with a warning.
+func (p *parser) rootLength(root *etree.Element, name string) (int, bool) {
+	attr := root.SelectAttr(name)
+	if attr == nil {
+		return 0, false
+	}
+	f, ok := parseAbsoluteLength(strings.TrimSpace(attr.Value))
+	if !ok || f <= 0 {
+		p.report(SeverityWarning, root, name, fmt.Errorf("the %s %q is not used for the size of the image", name, attr.Value))
+		return 0, false
+	}
+	return roundCoordinate(f), true
+}
+
+// index finds the elements that have an id
+func (d *Document) index() {
+	d.ids = make(map[string]SvgElement)
+	var walk func(elements []SvgElement)
+	walk = func(elements []SvgElement) {
+		for _, el := range elements {
+			if a, ok := el.(animatable); ok {
+				if id := a.common().ID; id != "" {
+					if _, found := d.ids[id]; !found {
+						d.ids[id] = el
+					}
+				}
+			}
+			if g, ok := el.(*SvgGroup); ok {
+				walk(g.Elements)
+			}
+		}
+	}
+	walk(d.Elements)
+	walk(d.Defs)
+}
+
+// ElementByID returns the element with the given id, or nil if there is none
+func (d *Document) ElementByID(id string) SvgElement {
+	return d.ids[id]
+}
+
+// viewTransform returns the matrix that maps the user coordinates into the viewport
+func (d *Document) viewTransform() Matrix {
+	if d.ViewBox == nil || d.ViewBox.Width == 0 || d.ViewBox.Height == 0 {
+		return Identity
+	}
+	return fitViewBox(*d.ViewBox, 0, 0, float64(d.Width), float64(d.Height), d.PreserveAspectRatio)
+}
+
+// Render renders the document onto the image, without animations
+func (d *Document) Render(img draw.Image) {
+	s := drawState{ctm: d.viewTransform(), tolerance: flattenTolerance}
+	for _, el := range d.Elements {
+		drawElement(img, el, s, defaultFill)
+	}
+}
+
+// fitViewBox returns the matrix that maps the view box into the viewport at (x, y) with the size w x h,
+// according to a preserveAspectRatio value like "xMidYMid meet", "xMinYMax slice" or "none"
+func fitViewBox(vb ViewBox, x, y, w, h float64, preserveAspectRatio string) Matrix {
+	sx, sy := w/vb.Width, h/vb.Height
+	fields := strings.Fields(preserveAspectRatio)
+	align, slice := "xMidYMid", false
+	if len(fields) > 0 {
+		align = fields[0]
+	}
+	if len(fields) > 1 && fields[1] == "slice" {
+		slice = true
+	}
+	if align != "none" {
+		s := math.Min(sx, sy)
+		if slice {
+			s = math.Max(sx, sy)
+		}
+		sx, sy = s, s
+	}
+	tx, ty := x, y
+	switch {
+	case strings.HasPrefix(align, "xMid"):
+		tx += (w - vb.Width*sx) / 2
+	case strings.HasPrefix(align, "xMax"):
+		tx += w - vb.Width*sx
+	}
+	switch {
+	case strings.HasSuffix(align, "YMid"):
+		ty += (h - vb.Height*sy) / 2
+	case strings.HasSuffix(align, "YMax"):
+		ty += h - vb.Height*sy
+	}
+	return Translate(tx, ty).Multiply(Scale(sx, sy)).Multiply(Translate(-vb.X, -vb.Y))
+}
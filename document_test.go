@@ -0,0 +1,219 @@
+package surrender
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDocument(t *testing.T) {
+	doc, err := ParseFile("testdata/document.svg")
+	assert.NoError(t, err)
+
+	// The height comes from the view box, scaled by the same amount as the width
+	assert.Equal(t, 100, doc.Width)
+	assert.Equal(t, 50, doc.Height)
+	assert.Equal(t, &ViewBox{0, 0, 50, 25}, doc.ViewBox)
+	assert.Equal(t, "1.2", doc.Version)
+	assert.Equal(t, "tiny", doc.BaseProfile)
+	assert.Equal(t, "Two squares", doc.Title)
+	assert.Equal(t, "A red and a blue square, scaled up by the view box", doc.Description)
+	assert.Equal(t, "<author>xyproto</author>", doc.Metadata)
+	assert.Len(t, doc.Elements, 2)
+	assert.Len(t, doc.Defs, 1)
+
+	track, ok := doc.ElementByID("track").(*SvgPath)
+	assert.True(t, ok)
+	assert.Same(t, doc.Defs[0], SvgElement(track))
+	blue, ok := doc.ElementByID("blue").(*SvgRectangle)
+	assert.True(t, ok)
+	assert.Same(t, doc.Elements[1].(*SvgGroup).Elements[0], SvgElement(blue))
+	assert.Nil(t, doc.ElementByID("missing"))
+
+	_, err = ParseFile("testdata/missing.svg")
+	assert.Error(t, err)
+}
+
+func TestRenderDocumentViewBox(t *testing.T) {
+	doc, err := ParseFile("testdata/document.svg")
+	assert.NoError(t, err)
+
+	// The width is 100 and the view box is 50 wide, so everything is twice as large
+	img := NewColoredImage(doc.Width, doc.Height, color.White)
+	doc.Render(img)
+	red, blue, white := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}, color.RGBA{255, 255, 255, 255}
+	assert.Equal(t, red, img.RGBAAt(19, 19))
+	assert.Equal(t, white, img.RGBAAt(20, 20))
+	assert.Equal(t, blue, img.RGBAAt(40, 0))
+	assert.Equal(t, blue, img.RGBAAt(59, 19))
+
+	// The motion path is in defs, and moves the blue square 20 units to the right
+	img = NewColoredImage(doc.Width, doc.Height, color.White)
+	RenderAt(doc, 10*time.Second, img)
+	assert.Equal(t, white, img.RGBAAt(40, 0))
+	assert.Equal(t, blue, img.RGBAAt(99, 19))
+}
+
+func TestFitViewBox(t *testing.T) {
+	vb := ViewBox{0, 0, 10, 20}
+	m := fitViewBox(vb, 0, 0, 100, 100, "xMidYMid meet")
+	assert.Equal(t, Matrix{5, 0, 0, 5, 25, 0}, m)
+	m = fitViewBox(vb, 0, 0, 100, 100, "xMinYMin slice")
+	assert.Equal(t, Matrix{10, 0, 0, 10, 0, 0}, m)
+	m = fitViewBox(vb, 0, 0, 100, 100, "none")
+	assert.Equal(t, Matrix{10, 0, 0, 5, 0, 0}, m)
+	x, y := fitViewBox(ViewBox{10, 10, 10, 10}, 0, 0, 20, 20, "").Apply(10, 10)
+	assert.Equal(t, image.Point{}, image.Pt(int(x), int(y)))
+}
+
+func TestParseSources(t *testing.T) {
+	data, err := os.ReadFile("testdata/circle.svg")
+	assert.NoError(t, err)
+	checker, err := os.ReadFile("testdata/checker.png")
+	assert.NoError(t, err)
+	withImage, err := os.ReadFile("testdata/image.svg")
+	assert.NoError(t, err)
+	fsys := fstest.MapFS{
+		"icons/circle.svg":  {Data: data},
+		"icons/image.svg":   {Data: withImage},
+		"icons/checker.png": {Data: checker},
+	}
+
+	fromReader, err := Parse(bytes.NewReader(data))
+	assert.NoError(t, err)
+	fromBytes, err := ParseBytes(data)
+	assert.NoError(t, err)
+	fromFS, err := ParseFS(fsys, "icons/circle.svg")
+	assert.NoError(t, err)
+	fromFile, err := ParseFile("testdata/circle.svg")
+	assert.NoError(t, err)
+	for _, doc := range []*Document{fromReader, fromBytes, fromFS} {
+		assert.Equal(t, fromFile.Width, doc.Width)
+		assert.Equal(t, fromFile.Elements, doc.Elements)
+	}
+
+	_, err = ParseFS(fsys, "icons/missing.svg")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = ParseBytes([]byte("<html></html>"))
+	assert.Error(t, err)
+
+	// Images can be loaded from a file system
+	_, err = ParseFSWithOptions(fsys, "icons/image.svg", ParseOptions{Mode: ParseStrict})
+	assert.ErrorIs(t, err, ErrLocalImage)
+	icons, err := fs.Sub(fsys, "icons")
+	assert.NoError(t, err)
+	doc, err := ParseFSWithOptions(icons, "image.svg", ParseOptions{ImageFS: icons})
+	assert.NoError(t, err)
+	assert.NotNil(t, doc.Elements[1].(*SvgImage).Image)
+}
+
+func TestRenderAndWritePNG(t *testing.T) {
+	doc, err := ParseFile("testdata/circle.svg")
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	assert.NoError(t, RenderAndWritePNG(doc, &buf, color.White))
+	img, err := png.Decode(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 100, 100), img.Bounds())
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, color.RGBAModel.Convert(img.At(50, 50)))
+}
+
+func TestParseModes(t *testing.T) {
+	data := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10">
+  <rect x="1.6" y="one" width="4" height="4" fill="#abc"/>
+  <path d="M0 0 A1 1 0 0 1 4 4" fill="blue"/>
+  <circle cx="5" cy="5" r="2" fill="bluish" text-anchor="left"/>
+</svg>`)
+
+	// Strict mode fails on the first error, with its position
+	_, err := ParseWithOptions(bytes.NewReader(data), ParseOptions{Mode: ParseStrict})
+	var parseErr *ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, "/svg/rect[1]", parseErr.Path)
+	assert.Equal(t, "y", parseErr.Attribute)
+	assert.Equal(t, "one", parseErr.Value)
+	assert.Equal(t, 2, parseErr.Line)
+	assert.Equal(t, 3, parseErr.Column)
+
+	// Lenient mode, which is the default, uses the default value for invalid attributes, renders paths
+	// up to the last valid segment and returns all the problems
+	doc, err := ParseBytes(data)
+	assert.NoError(t, err)
+	assert.Len(t, doc.Elements, 3)
+	assert.Equal(t, "M0 0", doc.Elements[1].(*SvgPath).String())
+	assert.Equal(t, &SvgRectangle{X: 2, Width: 4, Height: 4, Fill: color.RGBA{0xaa, 0xbb, 0xcc, 255}}, doc.Elements[0])
+	var messages []string
+	for _, d := range doc.Diagnostics {
+		messages = append(messages, d.String())
+	}
+	assert.Equal(t, []string{
+		`2:3: error: /svg/rect[1]@y: "one" is not a number`,
+		`3:3: error: /svg/path[1]@d: invalid path data at offset 5: invalid character in number: 'A'`,
+		`4:3: warning: /svg/circle[1]@text-anchor: unsupported text-anchor "left" is ignored`,
+		`4:3: warning: /svg/circle[1]@fill: unsupported color "bluish" is black`,
+	}, messages)
+
+	// Warnings do not stop strict mode
+	doc, err = ParseWithOptions(bytes.NewReader([]byte(`<svg xmlns="http://www.w3.org/2000/svg" width="100%"><rect width="1" height="1" fill="nope"/></svg>`)), ParseOptions{Mode: ParseStrict})
+	assert.NoError(t, err)
+	assert.Len(t, doc.Diagnostics, 2)
+	assert.Equal(t, SeverityWarning, doc.Diagnostics[0].Severity)
+
+	// Elements that are not rendered are skipped with a warning
+	doc, err = ParseBytes([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><title>t</title><ellipse rx="1" ry="1"/><defs><filter id="f"/></defs><polygon points="0 0 1 1 1 0"/></svg>`))
+	assert.NoError(t, err)
+	messages = nil
+	for _, d := range doc.Diagnostics {
+		messages = append(messages, d.String())
+	}
+	assert.Equal(t, []string{
+		`1:57: warning: /svg/ellipse[1]: ellipse elements are not rendered`,
+		`1:87: warning: /svg/defs[1]/filter[1]: filter elements are not rendered`,
+		`1:110: warning: /svg/polygon[1]: polygon elements are not rendered`,
+	}, messages)
+}
+
+func TestDocumentInError(t *testing.T) {
+	strict := ParseOptions{Mode: ParseStrict}
+
+	// Nothing can be rendered without a root svg element
+	for _, data := range []string{"<html></html>", "<svg><rect></svg>", `<svg viewBox="0 0 -1 1"></svg>`} {
+		doc, err := ParseWithOptions(strings.NewReader(data), strict)
+		assert.Nil(t, doc, data)
+		var docErr *DocumentError
+		assert.ErrorAs(t, err, &docErr, data)
+	}
+	_, err := ParseBytes([]byte("<html></html>"))
+	assert.ErrorIs(t, err, ErrNoSVGElement)
+
+	// A document in error is rendered up to the first element in error, and a path in error up to
+	// its last valid segment
+	doc, err := ParseWithOptions(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10">
+  <rect width="4" height="4"/>
+  <g fill="red">
+    <circle r="2"/>
+    <path d="M0 0 L5 5 L10"/>
+    <rect width="1" height="1"/>
+  </g>
+  <rect width="2" height="2"/>
+</svg>`), strict)
+	var parseErr *ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	var pathErr *PathError
+	assert.ErrorAs(t, err, &pathErr)
+	assert.Equal(t, 10, pathErr.Offset)
+	assert.Equal(t, "/svg/g[1]/path[1]", parseErr.Path)
+	assert.Len(t, doc.Elements, 2)
+	group := doc.Elements[1].(*SvgGroup)
+	assert.Len(t, group.Elements, 2)
+	assert.Equal(t, "M0 0 5 5", group.Elements[1].(*SvgPath).String())
+}
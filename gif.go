@@ -0,0 +1,332 @@
+package surrender
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// GIFOptions configures how the animations of a document are exported as an animated GIF
+type GIFOptions struct {
+	FPS           float64       // frames per second, 10 if not set
+	Start         time.Duration // when to start sampling the animations
+	End           time.Duration // when to stop sampling, where 0 means the end of the animations
+	LoopCount     int           // 0 loops forever, -1 plays once and n plays n+1 times, like for gif.GIF
+	SharedPalette bool          // use one palette for all frames, instead of one palette per frame
+	Background    color.Color   // the background color, or nil for a transparent background
+	// MaxPixels is the largest number of pixels each frame may have. If it is 0, DefaultMaxPixels is used.
+	MaxPixels int
+	// Context can cancel rendering and encoding or give them a deadline, and its error is returned if it
+	// is done first. If it is nil, they can not be cancelled.
+	Context context.Context
+}
+
+// defaultFPS is the number of frames per second that is used if GIFOptions.FPS is not set
+const defaultFPS = 10
+
+// frameTimes returns the times to sample, from the start and up to, but not including, the end,
+// and the delay after each frame in 100ths of a second
+func frameTimes(start, end time.Duration, fps float64) ([]time.Duration, []int) {
+	if fps <= 0 {
+		fps = defaultFPS
+	}
+	n := int(math.Ceil((end-start).Seconds()*fps - 1e-9))
+	if n < 1 {
+		n = 1
+	}
+	times := make([]time.Duration, n)
+	delays := make([]int, n)
+	for i := range times {
+		times[i] = start + time.Duration(math.Round(float64(i)/fps*float64(time.Second)))
+		// Round the total time instead of each delay, so that the frames do not drift
+		delays[i] = int(math.Round(float64(i+1)*100/fps) - math.Round(float64(i)*100/fps))
+	}
+	return times, delays
+}
+
+// renderFrames renders the animations of the document at the given number of frames per second,
+// and returns the frames and the delay after each frame in 100ths of a second.
+// An end of 0 means the end of the animations. Frames that do not change are merged.
+// The background, the pixel limit and the context are taken from opts.
+func renderFrames(doc *Document, start, end time.Duration, fps float64, opts RenderOptions) ([]*image.RGBA, []int, error) {
+	if doc.Width <= 0 || doc.Height <= 0 {
+		return nil, nil, errors.New("the image must be at least 1x1 pixels")
+	}
+	if err := checkPixels(doc.Width, doc.Height, opts.MaxPixels); err != nil {
+		return nil, nil, err
+	}
+	if end == 0 {
+		end = AnimationDuration(doc.Elements)
+	}
+	if end < start {
+		return nil, nil, errors.New("the end of the range is before the start")
+	}
+	times, delays := frameTimes(start, end, fps)
+
+	var frames []*image.RGBA
+	var frameDelays []int
+	for i, t := range times {
+		img, err := RenderDocument(doc.At(t), opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		if n := len(frames); n > 0 && sameImage(frames[n-1], img) {
+			frameDelays[n-1] += delays[i]
+			continue
+		}
+		frames = append(frames, img)
+		frameDelays = append(frameDelays, delays[i])
+	}
+	return frames, frameDelays, nil
+}
+
+// RenderGIF renders the animations of the document as an animated GIF
+func RenderGIF(doc *Document, opts GIFOptions) (*gif.GIF, error) {
+	ctx := opts.Context
+	frames, frameDelays, err := renderFrames(doc, opts.Start, opts.End, opts.FPS, RenderOptions{Background: opts.Background, MaxPixels: opts.MaxPixels, Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+
+	var shared color.Palette
+	if opts.SharedPalette {
+		hist := make(map[color.RGBA]int)
+		for _, frame := range frames {
+			if cancelled(ctx) {
+				return nil, ctx.Err()
+			}
+			addHistogram(hist, frame)
+		}
+		shared = quantize(ctx, hist)
+	}
+
+	anim := &gif.GIF{LoopCount: opts.LoopCount}
+	for i, frame := range frames {
+		palette := shared
+		if palette == nil {
+			hist := make(map[color.RGBA]int)
+			addHistogram(hist, frame)
+			palette = quantize(ctx, hist)
+		}
+		if cancelled(ctx) {
+			return nil, ctx.Err()
+		}
+		anim.Image = append(anim.Image, toPaletted(frame, palette))
+		anim.Delay = append(anim.Delay, frameDelays[i])
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+	return anim, nil
+}
+
+// EncodeGIF renders the animations of the document as an animated GIF and writes it to w
+func EncodeGIF(w io.Writer, doc *Document, opts GIFOptions) error {
+	anim, err := RenderGIF(doc, opts)
+	if err != nil {
+		return err
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// SaveGIF renders the animations of the document as an animated GIF and saves it
+func SaveGIF(doc *Document, filename string, opts GIFOptions) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := EncodeGIF(file, doc, opts); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// sameImage checks if two images have the same size and pixels
+func sameImage(a, b *image.RGBA) bool {
+	if a.Bounds() != b.Bounds() || len(a.Pix) != len(b.Pix) {
+		return false
+	}
+	for i := range a.Pix {
+		if a.Pix[i] != b.Pix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// opaqueColor returns the non-premultiplied color of a pixel, and false if the pixel is mostly transparent
+func opaqueColor(c color.RGBA) (color.RGBA, bool) {
+	if c.A < 128 {
+		return color.RGBA{}, false
+	}
+	if c.A == 255 {
+		return c, true
+	}
+	unmultiply := func(v uint8) uint8 {
+		return uint8(math.Min(255, math.Round(float64(v)*255/float64(c.A))))
+	}
+	return color.RGBA{unmultiply(c.R), unmultiply(c.G), unmultiply(c.B), 255}, true
+}
+
+// addHistogram counts the colors of the image. Transparent pixels are counted as color.RGBA{}.
+func addHistogram(hist map[color.RGBA]int, img *image.RGBA) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c, _ := opaqueColor(img.RGBAAt(x, y))
+			hist[c]++
+		}
+	}
+}
+
+// colorCount is a color and how many pixels have it
+type colorCount struct {
+	c color.RGBA
+	n int
+}
+
+// quantize makes a palette of at most 256 colors from a color histogram, with median cut.
+// If there are transparent pixels, the first color in the palette is transparent.
+// If the context is cancelled, it stops splitting early, and the caller checks the context.
+func quantize(ctx context.Context, hist map[color.RGBA]int) color.Palette {
+	var palette color.Palette
+	var colors []colorCount
+	for c, n := range hist {
+		if c.A == 0 {
+			palette = append(palette, color.RGBA{})
+			continue
+		}
+		colors = append(colors, colorCount{c, n})
+	}
+	// Sort the colors, so that the palette does not depend on the map order
+	sort.Slice(colors, func(i, j int) bool {
+		a, b := colors[i].c, colors[j].c
+		return uint32(a.R)<<16|uint32(a.G)<<8|uint32(a.B) < uint32(b.R)<<16|uint32(b.G)<<8|uint32(b.B)
+	})
+	max := 256 - len(palette)
+	if len(colors) <= max {
+		for _, cc := range colors {
+			palette = append(palette, cc.c)
+		}
+		return palette
+	}
+
+	boxes := [][]colorCount{colors}
+	for len(boxes) < max && !cancelled(ctx) {
+		// Split the box with the widest range of a color channel
+		best, bestChannel, bestRange := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for channel := 0; channel < 3; channel++ {
+				lo, hi := 255, 0
+				for _, cc := range box {
+					v := channelValue(cc.c, channel)
+					if v < lo {
+						lo = v
+					}
+					if v > hi {
+						hi = v
+					}
+				}
+				if hi-lo > bestRange {
+					best, bestChannel, bestRange = i, channel, hi-lo
+				}
+			}
+		}
+		if best < 0 {
+			break
+		}
+		box := boxes[best]
+		sort.SliceStable(box, func(i, j int) bool {
+			return channelValue(box[i].c, bestChannel) < channelValue(box[j].c, bestChannel)
+		})
+		// Split at the median pixel, but keep at least one color on each side
+		total := 0
+		for _, cc := range box {
+			total += cc.n
+		}
+		split, count := 1, box[0].n
+		for split < len(box)-1 && count*2 < total {
+			count += box[split].n
+			split++
+		}
+		boxes[best] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+	for _, box := range boxes {
+		var r, g, b, n int
+		for _, cc := range box {
+			r += int(cc.c.R) * cc.n
+			g += int(cc.c.G) * cc.n
+			b += int(cc.c.B) * cc.n
+			n += cc.n
+		}
+		palette = append(palette, color.RGBA{uint8((r + n/2) / n), uint8((g + n/2) / n), uint8((b + n/2) / n), 255})
+	}
+	return palette
+}
+
+// channelValue returns the red, green or blue value of a color, for channel 0, 1 or 2
+func channelValue(c color.RGBA, channel int) int {
+	switch channel {
+	case 0:
+		return int(c.R)
+	case 1:
+		return int(c.G)
+	}
+	return int(c.B)
+}
+
+// toPaletted converts an image to a paletted image, using the closest color in the palette for each pixel
+func toPaletted(img *image.RGBA, palette color.Palette) *image.Paletted {
+	b := img.Bounds()
+	dst := image.NewPaletted(b, palette)
+	transparent := -1
+	for i, c := range palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			transparent = i
+			break
+		}
+	}
+	cache := make(map[color.RGBA]uint8)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c, ok := opaqueColor(img.RGBAAt(x, y))
+			if !ok && transparent >= 0 {
+				dst.SetColorIndex(x, y, uint8(transparent))
+				continue
+			}
+			index, found := cache[c]
+			if !found {
+				index = uint8(closestOpaque(palette, c))
+				cache[c] = index
+			}
+			dst.SetColorIndex(x, y, index)
+		}
+	}
+	return dst
+}
+
+// closestOpaque returns the index of the opaque palette color that is closest to c
+func closestOpaque(palette color.Palette, c color.RGBA) int {
+	best, bestDistance := 0, math.MaxInt
+	for i, p := range palette {
+		pc := p.(color.RGBA)
+		if pc.A == 0 {
+			continue
+		}
+		dr, dg, db := int(pc.R)-int(c.R), int(pc.G)-int(c.G), int(pc.B)-int(c.B)
+		if d := dr*dr + dg*dg + db*db; d < bestDistance {
+			best, bestDistance = i, d
+		}
+	}
+	return best
+}
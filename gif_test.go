@@ -0,0 +1,78 @@
+package surrender
+
+import (
+	"bytes"
+	"image/color"
+	"image/gif"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameTimes(t *testing.T) {
+	times, delays := frameTimes(0, time.Second, 3)
+	assert.Equal(t, []time.Duration{0, 333333333, 666666667}, times)
+	// The delays add up to the full second
+	assert.Equal(t, []int{33, 34, 33}, delays)
+
+	times, _ = frameTimes(0, 0, 10)
+	assert.Len(t, times, 1)
+}
+
+func TestAnimationDuration(t *testing.T) {
+	doc, err := ParseFile("testdata/animation.svg")
+	assert.NoError(t, err)
+	assert.Equal(t, 4*time.Second, AnimationDuration(doc.Elements))
+
+	doc, err = ParseFile("testdata/circle.svg")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), AnimationDuration(doc.Elements))
+
+	// The animations last until the interval that begins last has ended, whatever the order of the begin values
+	for _, begin := range []string{"2s; 0s", "0s; 2s"} {
+		for _, repeat := range []string{"", ` repeatCount="indefinite"`} {
+			doc, err = ParseBytes([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1">` +
+				`<animate attributeName="x" from="0" to="5" begin="` + begin + `" dur="1s"` + repeat + `/></rect></svg>`))
+			assert.NoError(t, err)
+			assert.Equal(t, 3*time.Second, AnimationDuration(doc.Elements), begin+repeat)
+		}
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	hist := map[color.RGBA]int{{}: 1}
+	for i := 0; i < 1000; i++ {
+		hist[color.RGBA{uint8(i), uint8(i / 4), uint8(i / 8), 255}]++
+	}
+	palette := quantize(nil, hist)
+	assert.Len(t, palette, 256)
+	assert.Equal(t, color.RGBA{}, palette[0])
+
+	// Few colors are kept as they are
+	palette = quantize(nil, map[color.RGBA]int{{255, 0, 0, 255}: 3, {0, 0, 255, 255}: 1})
+	assert.Equal(t, []color.Color{color.RGBA{0, 0, 255, 255}, color.RGBA{255, 0, 0, 255}}, []color.Color(palette))
+}
+
+func TestEncodeGIF(t *testing.T) {
+	doc, err := ParseFile("testdata/animation.svg")
+	assert.NoError(t, err)
+
+	for _, shared := range []bool{false, true} {
+		var buf bytes.Buffer
+		opts := GIFOptions{FPS: 4, End: 2 * time.Second, LoopCount: -1, SharedPalette: shared, Background: color.White}
+		assert.NoError(t, EncodeGIF(&buf, doc, opts))
+
+		anim, err := gif.DecodeAll(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, 8, len(anim.Image))
+		assert.Equal(t, -1, anim.LoopCount)
+		total := 0
+		for _, delay := range anim.Delay {
+			total += delay
+		}
+		assert.Equal(t, 200, total)
+		// The box is blue between 1s and 2s, and at x=38 at 1.25s
+		assert.Equal(t, color.RGBA{0, 0, 255, 255}, color.RGBAModel.Convert(anim.Image[5].At(40, 5)))
+	}
+}
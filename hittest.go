@@ -0,0 +1,143 @@
+package surrender
+
+import (
+	"image/color"
+	"math"
+)
+
+// HitTest returns the topmost element whose fill or stroke covers the point (x, y), in the pixel coordinates
+// of the viewport, together with the groups that the element is in, from the outermost one. The pointer-events,
+// visibility, display and fill-rule properties are taken into account, as in TinySVG 1.2. If no element is hit,
+// the returned element is nil.
+func (d *Document) HitTest(x, y float64) (SvgElement, []*SvgGroup) {
+	return hitElements(d.Elements, nil, d.viewTransform(), defaultFill, fpoint{x, y})
+}
+
+// hitElements finds the topmost of the elements that is hit by the point, where m and inherited are the
+// transformation and the fill color from the parents
+func hitElements(elements []SvgElement, ancestors []*SvgGroup, m Matrix, inherited color.Color, p fpoint) (SvgElement, []*SvgGroup) {
+	for i := len(elements) - 1; i >= 0; i-- {
+		el := elements[i]
+		a, ok := el.(animatable)
+		if !ok {
+			continue
+		}
+		c := a.common()
+		if c.Display == "none" {
+			continue
+		}
+		clr := el.Color()
+		if clr == nil {
+			clr = inherited
+		}
+		ctm := transform(m, c.Transform)
+		if g, ok := el.(*SvgGroup); ok {
+			path := append(ancestors[:len(ancestors):len(ancestors)], g)
+			if hit, groups := hitElements(g.Elements, path, ctm, clr, p); hit != nil {
+				return hit, groups
+			}
+			continue
+		}
+		if hits(el, c, clr, ctm, p) {
+			return el, ancestors
+		}
+	}
+	return nil, nil
+}
+
+// hits checks if the point, in device coordinates, is on the fill or stroke of an element that is drawn
+// with the transformation m, according to its pointer-events property
+func hits(el SvgElement, c Common, clr color.Color, m Matrix, p fpoint) bool {
+	// Which areas can be hit, and if they must be visible or painted
+	fill, stroke, visible, painted := true, true, true, true
+	switch c.PointerEvents {
+	case "none":
+		return false
+	case "visibleFill":
+		stroke, painted = false, false
+	case "visibleStroke":
+		fill, painted = false, false
+	case "visible":
+		painted = false
+	case "painted":
+		visible = false
+	case "fill":
+		stroke, visible, painted = false, false, false
+	case "stroke":
+		fill, visible, painted = false, false, false
+	case "all":
+		visible, painted = false, false
+	}
+	if visible && c.hidden() {
+		return false
+	}
+	if painted && transparent(clr) {
+		if _, ok := el.(*SvgImage); !ok {
+			return false
+		}
+	}
+
+	if line, ok := el.(*SvgLine); ok {
+		// Lines only have a stroke, which is one pixel wide
+		if !stroke {
+			return false
+		}
+		a := m.applyPoint(fpoint{float64(line.X1), float64(line.Y1)})
+		b := m.applyPoint(fpoint{float64(line.X2), float64(line.Y2)})
+		return segmentDistance(p, a, b) <= 0.5
+	}
+	if !fill {
+		return false
+	}
+
+	// The other elements only have a fill area, which is tested in their user coordinates
+	inverse, ok := m.Invert()
+	if !ok {
+		return false
+	}
+	u := inverse.applyPoint(p)
+	switch e := el.(type) {
+	case *SvgCircle:
+		dx, dy := u.X-float64(e.Cx), u.Y-float64(e.Cy)
+		return dx*dx+dy*dy <= float64(e.R*e.R)
+	case *SvgRectangle:
+		return u.X >= float64(e.X) && u.X < float64(e.X+e.Width) && u.Y >= float64(e.Y) && u.Y < float64(e.Y+e.Height)
+	case *SvgPath:
+		return inside(windingNumber(e.outline(drawState{ctm: m, tolerance: flattenTolerance}.userTolerance()), u), c.FillRule)
+	}
+	// Text and images are hit anywhere within their bounding box
+	b := geometryBounds(el, Identity, false)
+	return b.ok && u.X >= b.rect.X0 && u.X < b.rect.X1 && u.Y >= b.rect.Y0 && u.Y < b.rect.Y1
+}
+
+// windingNumber returns how many times the closed polygons wind around the point
+func windingNumber(polygons [][]fpoint, p fpoint) int {
+	winding := 0
+	for _, poly := range polygons {
+		for i := range poly {
+			a, b := poly[i], poly[(i+1)%len(poly)]
+			// Count the edges that cross the horizontal line through the point, to the right of it
+			if (a.Y <= p.Y) == (b.Y <= p.Y) {
+				continue
+			}
+			if x := a.X + (p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y); x > p.X {
+				if a.Y < b.Y {
+					winding++
+				} else {
+					winding--
+				}
+			}
+		}
+	}
+	return winding
+}
+
+// segmentDistance returns the distance from the point p to the line segment from a to b
+func segmentDistance(p, a, b fpoint) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := 0.0
+	if lengthSquared := dx*dx + dy*dy; lengthSquared > 0 {
+		t = math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/lengthSquared))
+	}
+	return math.Hypot(p.X-(a.X+t*dx), p.Y-(a.Y+t*dy))
+}
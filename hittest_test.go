@@ -0,0 +1,67 @@
+package surrender
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHitTest(t *testing.T) {
+	doc, err := ParseFile("testdata/hittest.svg")
+	assert.NoError(t, err)
+
+	// hitID returns the id of the element at the given user coordinates, which are scaled by 2 in the viewport
+	hitID := func(x, y float64) string {
+		el, _ := doc.HitTest(x*2, y*2)
+		if el == nil {
+			return ""
+		}
+		return el.(animatable).common().ID
+	}
+
+	// The topmost element is hit, under the transforms of its groups
+	assert.Equal(t, "square", hitID(15, 15))
+	assert.Equal(t, "dot", hitID(30, 30))
+	assert.Equal(t, "background", hitID(5, 5))
+
+	el, groups := doc.HitTest(30, 30)
+	assert.Equal(t, "square", el.(*SvgRectangle).ID)
+	assert.Len(t, groups, 2)
+	assert.Equal(t, "outer", groups[0].ID)
+	assert.Equal(t, "inner", groups[1].ID)
+
+	// The hole in the even-odd path is not part of it, and the path without pointer events is skipped
+	assert.Equal(t, "ring", hitID(42, 2))
+	assert.Equal(t, "background", hitID(55, 15))
+
+	// Hidden elements, elements that are not displayed and unpainted fills are not hit by default
+	assert.Equal(t, "background", hitID(80, 5))
+	assert.Equal(t, "background", hitID(80, 25))
+	assert.Equal(t, "background", hitID(80, 40))
+	assert.Equal(t, "catcher", hitID(90, 40))
+
+	// The line is hit within half a pixel
+	assert.Equal(t, "line", hitID(25, 45.2))
+	assert.Equal(t, "background", hitID(25, 46))
+
+	// Outside of everything
+	el, groups = doc.HitTest(500, 500)
+	assert.Nil(t, el)
+	assert.Nil(t, groups)
+}
+
+func TestRenderVisibility(t *testing.T) {
+	doc, err := ParseFile("testdata/hittest.svg")
+	assert.NoError(t, err)
+	img, err := RenderDocument(doc, RenderOptions{Scale: 0.5})
+	assert.NoError(t, err)
+
+	white := color.RGBA{255, 255, 255, 255}
+	// The hole of the even-odd path, and the hidden, undisplayed and unfilled rectangles are not drawn
+	assert.Equal(t, white, img.RGBAAt(55, 15))
+	assert.Equal(t, color.RGBA{0, 128, 0, 255}, img.RGBAAt(42, 2))
+	assert.Equal(t, white, img.RGBAAt(80, 5))
+	assert.Equal(t, white, img.RGBAAt(80, 25))
+	assert.Equal(t, white, img.RGBAAt(80, 40))
+}
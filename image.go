@@ -0,0 +1,365 @@
+package surrender
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // register the JPEG decoder, for image elements
+	"io/fs"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+var (
+	// ErrRemoteImage is returned when an image element refers to a URL, since only local images can be rendered
+	ErrRemoteImage = errors.New("remote images are not supported")
+	// ErrLocalImage is returned when an image element refers to a local file, but neither ParseOptions.ImageDir nor ParseOptions.ImageFS is set
+	ErrLocalImage = errors.New("local images are not enabled")
+)
+
+// SvgImage struct, for embedded PNG and JPEG images
+type SvgImage struct {
+	Common
+	X, Y, Width, Height int
+	PreserveAspectRatio string  // for example "xMidYMid meet", which is the default, or "none"
+	Opacity             float64 // from 0 to 1
+	Href                string  // the data URI or relative path that the image was loaded from
+	Image               image.Image
+}
+
+// Color returns nil, since images have no fill color
+func (i SvgImage) Color() color.Color {
+	return nil
+}
+
+// parseImage parses an image element and loads the image it refers to. It returns false if the image
+// can not be loaded.
+func (p *parser) parseImage(el *etree.Element, common Common) (SvgImage, bool) {
+	x, y := p.length(el, "x", 0), p.length(el, "y", 0)
+	w, h := p.size(el, "width"), p.size(el, "height")
+	opacity, err := strconv.ParseFloat(strings.TrimSpace(el.SelectAttrValue("opacity", "1")), 64)
+	if err != nil {
+		p.report(SeverityError, el, "opacity", fmt.Errorf("%q is not a number", el.SelectAttrValue("opacity", "")))
+		opacity = 1
+	}
+	name := "xlink:href"
+	if el.SelectAttr(name) == nil {
+		name = "href"
+	}
+	href := el.SelectAttrValue(name, "")
+	imageFS := p.options.ImageFS
+	if imageFS == nil && p.options.ImageDir != "" {
+		imageFS = os.DirFS(p.options.ImageDir)
+	}
+	img, err := loadImage(href, imageFS, p.options.MaxPixels)
+	if err != nil {
+		p.report(SeverityError, el, name, err)
+		return SvgImage{}, false
+	}
+	return SvgImage{
+		Common:              common,
+		X:                   x,
+		Y:                   y,
+		Width:               w,
+		Height:              h,
+		PreserveAspectRatio: strings.TrimSpace(el.SelectAttrValue("preserveAspectRatio", "xMidYMid meet")),
+		Opacity:             math.Max(0, math.Min(1, opacity)),
+		Href:                href,
+		Image:               img,
+	}, true
+}
+
+// loadImage decodes the image that the given reference points to, which can be a data URI
+// or a path within imageFS. Local files are only loaded if imageFS is not nil. Images with more than
+// maxPixels pixels, where 0 means DefaultMaxPixels, are rejected before they are decoded.
+func loadImage(href string, imageFS fs.FS, maxPixels int) (image.Image, error) {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return nil, errors.New("image element without a reference")
+	}
+	if len(href) > 5 && strings.EqualFold(href[:5], "data:") {
+		return decodeDataURI(href, maxPixels)
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image reference %q: %w", href, err)
+	}
+	if u.Scheme != "" || u.Host != "" {
+		return nil, fmt.Errorf("%w: %s", ErrRemoteImage, href)
+	}
+	if imageFS == nil {
+		return nil, fmt.Errorf("%w: %s", ErrLocalImage, href)
+	}
+	// Only allow paths that stay within the image file system
+	name := strings.TrimPrefix(u.Path, "./")
+	if !fs.ValidPath(name) {
+		return nil, fmt.Errorf("invalid image path: %s", href)
+	}
+	data, err := fs.ReadFile(imageFS, name)
+	if err != nil {
+		return nil, err
+	}
+	img, err := decodeImage(data, maxPixels)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", href, err)
+	}
+	return img, nil
+}
+
+// decodeImage decodes an image, after checking that its size is within the limit
+func decodeImage(data []byte, maxPixels int) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPixels(config.Width, config.Height, maxPixels); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// decodeDataURI decodes a PNG or JPEG image from a "data:" URI, with at most maxPixels pixels
+func decodeDataURI(uri string, maxPixels int) (image.Image, error) {
+	comma := strings.IndexByte(uri, ',')
+	if comma < 0 {
+		return nil, errors.New("invalid data URI")
+	}
+	header, payload := uri[len("data:"):comma], uri[comma+1:]
+	isBase64 := false
+	params := strings.Split(header, ";")
+	for _, param := range params[1:] {
+		if strings.EqualFold(strings.TrimSpace(param), "base64") {
+			isBase64 = true
+		}
+	}
+	switch mediaType := strings.ToLower(strings.TrimSpace(params[0])); mediaType {
+	case "", "image/png", "image/jpeg", "image/jpg":
+	default:
+		return nil, fmt.Errorf("unsupported image type in data URI: %s", mediaType)
+	}
+
+	var data []byte
+	if isBase64 {
+		// Base64 data in SVG files is often split over several lines
+		payload = strings.Join(strings.Fields(payload), "")
+		decoded, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			// Also accept data without padding
+			if decoded, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "=")); err != nil {
+				return nil, fmt.Errorf("invalid base64 data in data URI: %w", err)
+			}
+		}
+		data = decoded
+	} else {
+		unescaped, err := url.PathUnescape(payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data URI: %w", err)
+		}
+		data = []byte(unescaped)
+	}
+
+	img, err := decodeImage(data, maxPixels)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode image in data URI: %w", err)
+	}
+	return img, nil
+}
+
+// viewportTransform returns the matrix that maps the image pixels into the x, y, width and height
+// viewport according to preserveAspectRatio, and the part of the viewport that the image is visible in
+func (i SvgImage) viewportTransform() (Matrix, clipRect) {
+	bounds := i.Image.Bounds()
+	x, y, w, h := float64(i.X), float64(i.Y), float64(i.Width), float64(i.Height)
+	m := fitViewBox(ViewBox{float64(bounds.Min.X), float64(bounds.Min.Y), float64(bounds.Dx()), float64(bounds.Dy())}, x, y, w, h, i.PreserveAspectRatio)
+
+	// The image is only visible where it overlaps the viewport
+	x0, y0 := m.Apply(float64(bounds.Min.X), float64(bounds.Min.Y))
+	x1, y1 := m.Apply(float64(bounds.Max.X), float64(bounds.Max.Y))
+	visible := clipRect{math.Max(x, x0), math.Max(y, y0), math.Min(x+w, x1), math.Min(y+h, y1)}
+	return m, visible
+}
+
+// Draw method for SvgImage
+func (i SvgImage) Draw(img draw.Image, clr color.Color) {
+	i.drawWith(img, clr, defaultState)
+}
+
+func (i SvgImage) drawWith(img draw.Image, _ color.Color, s drawState) {
+	if i.Image == nil || i.Width <= 0 || i.Height <= 0 || i.Opacity <= 0 || i.Image.Bounds().Empty() {
+		return
+	}
+	toUser, visible := i.viewportTransform()
+	userToDevice := s.with(i.Transform).ctm
+	deviceToUser, ok := userToDevice.Invert()
+	if !ok {
+		return
+	}
+	userToImage, ok := toUser.Invert()
+	if !ok {
+		return
+	}
+
+	src := toRGBA(i.Image)
+	// Pixelated images are sampled without interpolation, which keeps the edges between the pixels sharp
+	sample := bilinear
+	pixelated := i.pixelated()
+	if pixelated {
+		sample = nearest
+	}
+	// When shrinking the image a lot, average blocks of pixels first, so that no pixels are skipped
+	imageToDevice := userToDevice.Multiply(toUser)
+	scale := math.Sqrt(math.Abs(imageToDevice.A*imageToDevice.D - imageToDevice.B*imageToDevice.C))
+	shrink := 1
+	if scale < 0.5 && !pixelated {
+		shrink = shrinkFactor(scale, src.Bounds().Size())
+		src = boxShrink(src, shrink)
+	}
+
+	opacity := uint32(math.Round(i.Opacity * 0xffff))
+	pixels := deviceBounds(userToDevice, visible.x0, visible.y0, visible.x1, visible.y1).Intersect(img.Bounds())
+	for py := pixels.Min.Y; py < pixels.Max.Y; py++ {
+		if (py-pixels.Min.Y)%cancelRows == 0 && cancelled(s.ctx) {
+			return
+		}
+		for px := pixels.Min.X; px < pixels.Max.X; px++ {
+			ux, uy := deviceToUser.Apply(float64(px)+0.5, float64(py)+0.5)
+			if !visible.contains(ux, uy) {
+				continue
+			}
+			ix, iy := userToImage.Apply(ux, uy)
+			c := sample(src, (ix-float64(i.Image.Bounds().Min.X))/float64(shrink)-0.5, (iy-float64(i.Image.Bounds().Min.Y))/float64(shrink)-0.5)
+			blendPixel(img, px, py, c, opacity)
+		}
+	}
+}
+
+// toRGBA converts an image to a premultiplied RGBA image that starts at (0, 0)
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	if rgba, ok := src.(*image.RGBA); ok && b.Min == (image.Point{}) {
+		return rgba
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	return rgba
+}
+
+// shrinkFactor returns how many pixels of an image of the given size are averaged in each direction, when it
+// is drawn at the given scale. A tiny or zero scale would overflow an int, so the factor is at most the size
+// of the image, which shrinks it to a single pixel.
+func shrinkFactor(scale float64, size image.Point) int {
+	return int(math.Max(1, math.Min(1/scale, float64(maxInt(size.X, size.Y)))))
+}
+
+// boxShrink makes the image smaller by the given factor, by averaging blocks of factor x factor pixels
+func boxShrink(src *image.RGBA, factor int) *image.RGBA {
+	b := src.Bounds()
+	w, h := (b.Dx()+factor-1)/factor, (b.Dy()+factor-1)/factor
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum [4]int
+			n := 0
+			for sy := y * factor; sy < (y+1)*factor && sy < b.Dy(); sy++ {
+				for sx := x * factor; sx < (x+1)*factor && sx < b.Dx(); sx++ {
+					off := src.PixOffset(sx, sy)
+					for k := 0; k < 4; k++ {
+						sum[k] += int(src.Pix[off+k])
+					}
+					n++
+				}
+			}
+			off := dst.PixOffset(x, y)
+			for k := 0; k < 4; k++ {
+				dst.Pix[off+k] = uint8((sum[k] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
+
+// pixelated checks if the image-rendering property asks for speed or sharp pixels, rather than smooth scaling
+func (i SvgImage) pixelated() bool {
+	switch i.ImageRendering {
+	case "optimizeSpeed", "pixelated", "crisp-edges":
+		return true
+	}
+	return false
+}
+
+// nearest samples the pixel of the image that is nearest the given position, where (0, 0) is the center of the top left pixel
+func nearest(src *image.RGBA, x, y float64) [4]float64 {
+	b := src.Bounds()
+	ix := int(math.Max(0, math.Min(float64(b.Dx()-1), math.Floor(x+0.5))))
+	iy := int(math.Max(0, math.Min(float64(b.Dy()-1), math.Floor(y+0.5))))
+	off := src.PixOffset(ix, iy)
+	return [4]float64{float64(src.Pix[off]), float64(src.Pix[off+1]), float64(src.Pix[off+2]), float64(src.Pix[off+3])}
+}
+
+// bilinear samples the image at the given position, where (0, 0) is the center of the top left pixel
+func bilinear(src *image.RGBA, x, y float64) [4]float64 {
+	b := src.Bounds()
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	clampX := func(v int) int {
+		if v < 0 {
+			return 0
+		}
+		if v >= b.Dx() {
+			return b.Dx() - 1
+		}
+		return v
+	}
+	clampY := func(v int) int {
+		if v < 0 {
+			return 0
+		}
+		if v >= b.Dy() {
+			return b.Dy() - 1
+		}
+		return v
+	}
+	ix0, ix1 := clampX(int(x0)), clampX(int(x0)+1)
+	iy0, iy1 := clampY(int(y0)), clampY(int(y0)+1)
+	var c [4]float64
+	for k := 0; k < 4; k++ {
+		top := float64(src.Pix[src.PixOffset(ix0, iy0)+k])*(1-fx) + float64(src.Pix[src.PixOffset(ix1, iy0)+k])*fx
+		bottom := float64(src.Pix[src.PixOffset(ix0, iy1)+k])*(1-fx) + float64(src.Pix[src.PixOffset(ix1, iy1)+k])*fx
+		c[k] = top*(1-fy) + bottom*fy
+	}
+	return c
+}
+
+// blendPixel draws a premultiplied color, with components from 0 to 255, over the pixel at (x, y),
+// with an opacity from 0 to 0xffff
+func blendPixel(img draw.Image, x, y int, c [4]float64, opacity uint32) {
+	o := float64(opacity) / 0xffff
+	a := c[3] * o / 255
+	clamp := func(v float64) uint8 {
+		return uint8(math.Max(0, math.Min(255, math.Round(v))))
+	}
+	if rgba, ok := img.(*image.RGBA); ok {
+		off := rgba.PixOffset(x, y)
+		for k := 0; k < 4; k++ {
+			rgba.Pix[off+k] = clamp(c[k]*o + float64(rgba.Pix[off+k])*(1-a))
+		}
+		return
+	}
+	r, g, b, da := img.At(x, y).RGBA()
+	dst := [4]uint32{r, g, b, da}
+	var blended [4]uint8
+	for k := 0; k < 4; k++ {
+		blended[k] = clamp(c[k]*o + float64(dst[k])/0x101*(1-a))
+	}
+	img.Set(x, y, color.RGBA{blended[0], blended[1], blended[2], blended[3]})
+}
@@ -0,0 +1,100 @@
+package surrender
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseImage(t *testing.T) {
+	t.Run("test that local images are not loaded by default", func(t *testing.T) {
+		_, err := ParseFileWithOptions("testdata/image.svg", ParseOptions{Mode: ParseStrict})
+		assert.True(t, errors.Is(err, ErrLocalImage))
+	})
+
+	t.Run("test that remote images are rejected", func(t *testing.T) {
+		_, err := ParseFileWithOptions("testdata/remote.svg", ParseOptions{ImageDir: "testdata", Mode: ParseStrict})
+		assert.True(t, errors.Is(err, ErrRemoteImage))
+	})
+
+	t.Run("test loading data URIs and local images", func(t *testing.T) {
+		doc, err := ParseFileWithOptions("testdata/image.svg", ParseOptions{ImageDir: "testdata"})
+		assert.NoError(t, err)
+		elements := doc.Elements
+		assert.Len(t, elements, 3)
+
+		img, ok := elements[0].(*SvgImage)
+		assert.True(t, ok)
+		assert.Equal(t, 20, img.Width)
+		assert.Equal(t, 1.0, img.Opacity)
+		assert.Equal(t, "xMidYMid meet", img.PreserveAspectRatio)
+		assert.Equal(t, image.Rect(0, 0, 2, 2), img.Image.Bounds())
+
+		// The base64 data is split over several lines
+		img, ok = elements[1].(*SvgImage)
+		assert.True(t, ok)
+		assert.Equal(t, 0.5, img.Opacity)
+		assert.Equal(t, image.Rect(0, 0, 2, 2), img.Image.Bounds())
+
+		group, ok := elements[2].(*SvgGroup)
+		assert.True(t, ok)
+		img, ok = group.Elements[0].(*SvgImage)
+		assert.True(t, ok)
+		assert.Equal(t, "none", img.PreserveAspectRatio)
+		assert.Equal(t, &Matrix{2, 0, 0, 2, 0, 0}, img.Transform)
+	})
+
+	t.Run("test that paths outside of the image directory are rejected", func(t *testing.T) {
+		_, err := loadImage("../testdata/checker.png", os.DirFS("testdata"), 0)
+		assert.Error(t, err)
+		_, err = loadImage("/etc/passwd", os.DirFS("testdata"), 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("test that images larger than the limit are not decoded", func(t *testing.T) {
+		_, err := ParseFileWithOptions("testdata/image.svg", ParseOptions{ImageDir: "testdata", MaxPixels: 3, Mode: ParseStrict})
+		assert.True(t, errors.Is(err, ErrTooManyPixels))
+		_, err = loadImage("checker.png", os.DirFS("testdata"), 3)
+		assert.True(t, errors.Is(err, ErrTooManyPixels))
+		_, err = loadImage("checker.png", os.DirFS("testdata"), 0)
+		assert.NoError(t, err)
+	})
+}
+
+func TestRenderImage(t *testing.T) {
+	doc, err := ParseFileWithOptions("testdata/image.svg", ParseOptions{ImageDir: "testdata"})
+	assert.NoError(t, err)
+
+	img := NewColoredImage(100, 100, color.Black)
+	doc.Render(img)
+
+	// The 2x2 image is scaled up to 20x20
+	red := color.RGBA{255, 0, 0, 255}
+	assert.Equal(t, red, img.RGBAAt(2, 2))
+	assert.Equal(t, color.RGBA{0, 255, 0, 255}, img.RGBAAt(17, 2))
+	assert.Equal(t, color.RGBA{0, 0, 255, 255}, img.RGBAAt(2, 17))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, img.RGBAAt(17, 17))
+
+	// The second image keeps its aspect ratio, so it is centered in the 40x20 viewport, at half opacity
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, img.RGBAAt(45, 2))
+	assert.Equal(t, color.RGBA{128, 0, 0, 255}, img.RGBAAt(52, 2))
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, img.RGBAAt(75, 2))
+
+	// The third image is translated by its parent group and scaled to 40x40
+	assert.Equal(t, red, img.RGBAAt(5, 55))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, img.RGBAAt(35, 85))
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, img.RGBAAt(45, 55))
+}
+
+func TestShrinkFactor(t *testing.T) {
+	size := image.Pt(40, 30)
+	assert.Equal(t, 4, shrinkFactor(0.25, size))
+	// Tiny and zero scales shrink the image to a single pixel, instead of overflowing
+	assert.Equal(t, 40, shrinkFactor(1e-150, size))
+	assert.Equal(t, 40, shrinkFactor(0, size))
+	assert.Equal(t, 1, shrinkFactor(0, image.Point{}))
+}
@@ -0,0 +1,175 @@
+package surrender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// The limits that are used when the corresponding options are not set. They are large enough for any
+// reasonable document, while keeping untrusted documents from using up memory or time.
+const (
+	// DefaultMaxElements is the largest number of elements a document may have, if ParseOptions.MaxElements is not set
+	DefaultMaxElements = 1 << 20
+	// DefaultMaxDepth is how deeply elements may be nested, if ParseOptions.MaxDepth is not set
+	DefaultMaxDepth = 256
+	// DefaultMaxPathSegments is the largest number of path segments in a document, if ParseOptions.MaxPathSegments is not set
+	DefaultMaxPathSegments = 1 << 22
+	// DefaultMaxPixels is the largest number of pixels an output image may have, if RenderOptions.MaxPixels is not set
+	DefaultMaxPixels = 1 << 26 // 8192 x 8192
+	// DefaultMaxUseExpansion is the largest number of elements that use elements may expand to, if
+	// ParseOptions.MaxUseExpansion is not set
+	DefaultMaxUseExpansion = 1 << 20
+)
+
+var (
+	// ErrTooManyElements is returned when a document has more elements than the allowed number
+	ErrTooManyElements = errors.New("too many elements")
+	// ErrTooDeep is returned when elements are nested more deeply than allowed
+	ErrTooDeep = errors.New("elements are nested too deeply")
+	// ErrTooManyPathSegments is returned when the paths of a document have more segments than the allowed number
+	ErrTooManyPathSegments = errors.New("too many path segments")
+	// ErrTooManyPixels is returned when the output image would have more pixels than the allowed number
+	ErrTooManyPixels = errors.New("too many pixels")
+	// ErrUseExpansion is returned when use elements would expand to more elements than the allowed number,
+	// or when a use element refers to itself
+	ErrUseExpansion = errors.New("use elements expand to too many elements")
+)
+
+// orDefault returns the limit, or the default if the limit is not set
+func orDefault(limit, def int) int {
+	if limit <= 0 {
+		return def
+	}
+	return limit
+}
+
+// checkElements checks the number of elements below root, and how deeply they are nested, before they are parsed
+func (p *parser) checkElements(root *etree.Element) error {
+	maxElements := orDefault(p.options.MaxElements, DefaultMaxElements)
+	maxDepth := orDefault(p.options.MaxDepth, DefaultMaxDepth)
+	count := 0
+	var walk func(el *etree.Element, depth int) error
+	walk = func(el *etree.Element, depth int) error {
+		if count++; count > maxElements {
+			return fmt.Errorf("%w: more than %d", ErrTooManyElements, maxElements)
+		}
+		if depth > maxDepth {
+			return fmt.Errorf("%w: more than %d levels", ErrTooDeep, maxDepth)
+		}
+		for _, child := range el.ChildElements() {
+			if err := walk(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(root, 1)
+}
+
+// checkUses counts the elements that the use elements below root would expand to, where the use elements
+// in what they refer to are expanded too, and checks that they are within the limit. The use elements are
+// not rendered, but they are checked anyway, so that documents that expand exponentially, or that refer to
+// themselves, are rejected.
+func (p *parser) checkUses(root *etree.Element) error {
+	maxExpansion := orDefault(p.options.MaxUseExpansion, DefaultMaxUseExpansion)
+	ids := make(map[string]*etree.Element)
+	count := 0
+	var collect func(el *etree.Element)
+	collect = func(el *etree.Element) {
+		count++
+		if id := el.SelectAttrValue("id", ""); id != "" {
+			if _, ok := ids[id]; !ok {
+				ids[id] = el
+			}
+		}
+		for _, child := range el.ChildElements() {
+			collect(child)
+		}
+	}
+	collect(root)
+
+	// The sizes are capped, so that they can not overflow, and -1 is used for the elements that are
+	// being counted, so that references to them are found
+	limit := count + maxExpansion
+	sizes := make(map[*etree.Element]int)
+	var size func(el *etree.Element) (int, error)
+	size = func(el *etree.Element) (int, error) {
+		if n, ok := sizes[el]; ok {
+			if n < 0 {
+				return 0, fmt.Errorf("%w: %s refers to itself", ErrUseExpansion, elementPath(el))
+			}
+			return n, nil
+		}
+		sizes[el] = -1
+		n := 1
+		children := el.ChildElements()
+		if ref := useReference(el); el.Tag == "use" && strings.HasPrefix(ref, "#") {
+			if target := ids[ref[1:]]; target != nil {
+				children = append([]*etree.Element{target}, children...)
+			}
+		}
+		for _, child := range children {
+			m, err := size(child)
+			if err != nil {
+				return 0, err
+			}
+			if n += m; n > limit {
+				n = limit + 1
+			}
+		}
+		sizes[el] = n
+		return n, nil
+	}
+	n, err := size(root)
+	if err != nil {
+		return err
+	}
+	if n-count > maxExpansion {
+		return fmt.Errorf("%w: more than %d", ErrUseExpansion, maxExpansion)
+	}
+	return nil
+}
+
+// useReference returns the reference of a use element, like "#id"
+func useReference(el *etree.Element) string {
+	if attr := el.SelectAttr("xlink:href"); attr != nil {
+		return strings.TrimSpace(attr.Value)
+	}
+	return strings.TrimSpace(el.SelectAttrValue("href", ""))
+}
+
+// countSegments adds the segments of the path to the number of path segments in the document, and checks
+// that they are within the limit
+func (p *parser) countSegments(path SvgPath) error {
+	for _, command := range path.Commands {
+		p.segments += len(command.Points)
+		if len(command.Points) == 0 {
+			p.segments++ // closepath
+		}
+	}
+	if maxSegments := orDefault(p.options.MaxPathSegments, DefaultMaxPathSegments); p.segments > maxSegments {
+		return fmt.Errorf("%w: more than %d", ErrTooManyPathSegments, maxSegments)
+	}
+	return nil
+}
+
+// checkPixels checks that an image with the given size is within the limit, where 0 means DefaultMaxPixels
+func checkPixels(w, h, maxPixels int) error {
+	maxPixels = orDefault(maxPixels, DefaultMaxPixels)
+	if float64(w)*float64(h) > float64(maxPixels) {
+		return fmt.Errorf("%w: %d x %d is more than %d", ErrTooManyPixels, w, h, maxPixels)
+	}
+	return nil
+}
+
+// cancelled checks if the context, which may be nil, is cancelled or past its deadline
+func cancelled(ctx context.Context) bool {
+	return ctx != nil && ctx.Err() != nil
+}
+
+// cancelRows is how many rows are filled between the checks for cancellation
+const cancelRows = 64
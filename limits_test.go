@@ -0,0 +1,125 @@
+package surrender
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimits(t *testing.T) {
+	rects := `<svg xmlns="http://www.w3.org/2000/svg">` + strings.Repeat(`<rect width="1" height="1"/>`, 10) + `</svg>`
+	_, err := ParseWithOptions(strings.NewReader(rects), ParseOptions{MaxElements: 10})
+	assert.ErrorIs(t, err, ErrTooManyElements)
+	_, err = ParseWithOptions(strings.NewReader(rects), ParseOptions{MaxElements: 11})
+	assert.NoError(t, err)
+
+	nested := `<svg xmlns="http://www.w3.org/2000/svg">` + strings.Repeat("<g>", 300) + strings.Repeat("</g>", 300) + `</svg>`
+	_, err = ParseBytes([]byte(nested))
+	assert.ErrorIs(t, err, ErrTooDeep)
+	_, err = ParseWithOptions(strings.NewReader(nested), ParseOptions{MaxDepth: 301})
+	assert.NoError(t, err)
+
+	// The limits stop parsing in lenient mode too
+	paths := `<svg xmlns="http://www.w3.org/2000/svg"><path d="M0 0 L1 1 2 2Z"/><path d="M0 0 h5 v5"/></svg>`
+	doc, err := ParseWithOptions(strings.NewReader(paths), ParseOptions{MaxPathSegments: 6, Mode: ParseLenient})
+	assert.ErrorIs(t, err, ErrTooManyPathSegments)
+	assert.Nil(t, doc)
+	_, err = ParseWithOptions(strings.NewReader(paths), ParseOptions{MaxPathSegments: 7})
+	assert.NoError(t, err)
+}
+
+func TestUseExpansionLimit(t *testing.T) {
+	// Each level refers to the level before it twice, so 40 levels would expand to 2^40 elements
+	var sb strings.Builder
+	sb.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><defs><rect id="l0" width="1" height="1"/>`)
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&sb, `<g id="l%d"><use xlink:href="#l%d"/><use xlink:href="#l%d"/></g>`, i, i-1, i-1)
+	}
+	sb.WriteString(`</defs><use xlink:href="#l40"/></svg>`)
+	start := time.Now()
+	_, err := ParseBytes([]byte(sb.String()))
+	assert.ErrorIs(t, err, ErrUseExpansion)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// A use element may not refer to an element it is in
+	_, err = ParseBytes([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><g id="g"><use href="#g"/></g></svg>`))
+	assert.ErrorIs(t, err, ErrUseExpansion)
+
+	// use elements within the limit are parsed, with a warning, since they are not rendered
+	uses := `<svg xmlns="http://www.w3.org/2000/svg"><rect id="r" width="1" height="1"/><use href="#r"/><use href="#r"/><use href="#missing"/></svg>`
+	_, err = ParseWithOptions(strings.NewReader(uses), ParseOptions{MaxUseExpansion: 1})
+	assert.ErrorIs(t, err, ErrUseExpansion)
+	doc, err := ParseWithOptions(strings.NewReader(uses), ParseOptions{MaxUseExpansion: 2})
+	assert.NoError(t, err)
+	assert.Len(t, doc.Diagnostics, 3)
+	assert.Equal(t, "use elements are not rendered", doc.Diagnostics[0].Message)
+}
+
+func TestRenderLimits(t *testing.T) {
+	doc, err := ParseFile("testdata/circle.svg")
+	assert.NoError(t, err)
+
+	_, err = RenderDocument(doc, RenderOptions{Width: 100, Height: 100, MaxPixels: 9999})
+	assert.ErrorIs(t, err, ErrTooManyPixels)
+	_, err = RenderDocument(doc, RenderOptions{Scale: 1e300})
+	assert.ErrorIs(t, err, ErrTooManyPixels)
+	_, err = RenderDocument(doc, RenderOptions{Width: 100, Height: 100, MaxPixels: 10000})
+	assert.NoError(t, err)
+
+	huge := &Document{Width: 1 << 20, Height: 1 << 20}
+	assert.ErrorIs(t, RenderAndWritePNG(huge, &bytes.Buffer{}, nil), ErrTooManyPixels)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = RenderDocument(doc, RenderOptions{Context: ctx})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestAnimationLimits(t *testing.T) {
+	doc, err := ParseFile("testdata/animation.svg")
+	assert.NoError(t, err)
+
+	// The size of the frames is checked before any of them are rendered
+	_, err = RenderGIF(doc, GIFOptions{MaxPixels: 9999})
+	assert.ErrorIs(t, err, ErrTooManyPixels)
+	assert.ErrorIs(t, EncodeAPNG(&bytes.Buffer{}, doc, APNGOptions{MaxPixels: 9999}), ErrTooManyPixels)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = RenderGIF(doc, GIFOptions{Context: ctx, SharedPalette: true})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, EncodeAPNG(&bytes.Buffer{}, doc, APNGOptions{Context: ctx}), context.Canceled)
+}
+
+func TestRenderDeadline(t *testing.T) {
+	// Many large antialiased circles take much longer to render than the deadline
+	b := NewDocument(4000, 4000)
+	for i := 0; i < 200; i++ {
+		b.Circle(2000, 2000, 1900-i, nil).Attr("shape-rendering", "geometricPrecision")
+	}
+	doc, err := b.Build()
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = RenderDocument(doc, RenderOptions{Context: ctx})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestHugeShapesAreClipped(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	start := time.Now()
+	SvgRectangle{X: -1 << 30, Y: -1 << 30, Width: 1 << 31, Height: 1 << 31, Fill: blue}.Draw(img, blue)
+	SvgCircle{Cx: 2, Cy: 2, R: 1 << 30, Fill: red}.Draw(img, red)
+	DrawLine(img, image.Point{X: -1 << 30, Y: 10}, image.Point{X: 1 << 30, Y: 10}, red)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, red, img.At(0, 0))
+	assert.Equal(t, red, img.At(3, 3))
+}
@@ -0,0 +1,549 @@
+package surrender
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Optimize returns a smaller copy of the document that renders to the same pixels. Invisible elements and
+// unused defs are removed, adjacent rectangles with the same fill are merged into paths, redundant groups
+// are collapsed, fill colors that elements share are moved to a parent group and path data is rewritten in
+// its shortest form. Each of these steps is only kept if the document renders to exactly the same pixels,
+// both at its own size and at a scale where edges fall within pixels, with and without antialiasing, and
+// both without its animations and at times within them. Merged rectangles are checked without antialiasing,
+// since the faint seams that antialiasing draws where separate rectangles touch are gone once they are
+// filled as one shape.
+// Elements with an id or animations are kept as they are, since they may be referred to or animated.
+// The given document is not changed.
+func Optimize(doc *Document) *Document {
+	result := *doc
+	passes := []struct {
+		optimize func(elements []SvgElement, fill color.Color) []SvgElement
+		checks   []RenderOptions
+	}{
+		{removeInvisible, renderChecks},
+		{mergeRects, aliasedChecks},
+		{collapseGroups, renderChecks},
+		{hoistFill, renderChecks},
+		{minimalPaths, renderChecks},
+	}
+	var reference []uint8
+	var checked []RenderOptions
+	for _, pass := range passes {
+		// The reference is rendered again when a step has other checks than the one before it
+		if reference == nil || &checked[0] != &pass.checks[0] {
+			reference, checked = renderPixels(&result, pass.checks), pass.checks
+		}
+		optimized := result
+		optimized.Elements = pass.optimize(result.Elements, defaultFill)
+		if bytes.Equal(renderPixels(&optimized, pass.checks), reference) {
+			result = optimized
+		}
+	}
+	result.Defs = removeUnusedDefs(result.Elements, result.Defs)
+	result.index()
+	return &result
+}
+
+// renderChecks are the ways a document is rendered to check that it is not changed by an optimization: at its
+// own size, and at a scale where the edges of shapes fall within pixels, with and without antialiasing
+var renderChecks = []RenderOptions{{}, {Scale: 1.5}, {Scale: 1.5, Antialias: true}}
+
+// aliasedChecks are the renderChecks without antialiasing
+var aliasedChecks = []RenderOptions{{}, {Scale: 1.5}}
+
+// animationSamples is how many times within its animations a document is rendered at, when it is checked
+const animationSamples = 8
+
+// renderPixels renders the document in each of the given ways, without its animations and at times within them,
+// and returns all the pixels
+func renderPixels(d *Document, checks []RenderOptions) []uint8 {
+	documents := []*Document{d}
+	if duration := AnimationDuration(d.Elements); duration > 0 {
+		for i := 0; i <= animationSamples; i++ {
+			documents = append(documents, d.At(duration*time.Duration(i)/animationSamples))
+		}
+	}
+	var pixels []uint8
+	for _, doc := range documents {
+		for _, opts := range checks {
+			// Documents that can not be rendered, like empty ones, fail in the same way before and after
+			if img, err := RenderDocument(doc, opts); err == nil {
+				pixels = append(pixels, img.Pix...)
+			}
+		}
+	}
+	return pixels
+}
+
+// pinned checks if an element must be kept as it is, since it has an id or animations
+func pinned(c Common) bool {
+	return c.ID != "" || len(c.Animations) > 0
+}
+
+// withElements returns a copy of the group with other child elements
+func withElements(g *SvgGroup, elements []SvgElement) *SvgGroup {
+	changed := *g
+	changed.Elements = elements
+	return &changed
+}
+
+// hasFill checks if the element is painted with its fill color
+func hasFill(el SvgElement) bool {
+	switch el.(type) {
+	case *SvgCircle, *SvgRectangle, *SvgPath, *SvgGroup, *SvgText, *SvgTextArea:
+		return true
+	}
+	return false
+}
+
+// changed returns a copy of the element, where f has changed the common attributes or the fill color.
+// Changes to the fill color are ignored for elements without one.
+func changed(el SvgElement, f func(c *Common, fill *color.Color)) SvgElement {
+	var fill color.Color
+	switch e := el.(type) {
+	case *SvgCircle:
+		c := *e
+		f(&c.Common, &c.Fill)
+		return &c
+	case *SvgRectangle:
+		c := *e
+		f(&c.Common, &c.Fill)
+		return &c
+	case *SvgPath:
+		c := *e
+		f(&c.Common, &c.Fill)
+		return &c
+	case *SvgGroup:
+		c := *e
+		f(&c.Common, &c.Fill)
+		return &c
+	case *SvgText:
+		c := *e
+		f(&c.Common, &c.Fill)
+		return &c
+	case *SvgTextArea:
+		c := *e
+		f(&c.Common, &c.Fill)
+		return &c
+	case *SvgLine:
+		c := *e
+		f(&c.Common, &fill)
+		return &c
+	case *SvgImage:
+		c := *e
+		f(&c.Common, &fill)
+		return &c
+	}
+	return el
+}
+
+// sameColor checks if two fill colors are the same, where nil is only the same as nil
+func sameColor(a, b color.Color) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+// removeInvisible removes the elements that are not drawn, and groups that end up empty
+func removeInvisible(elements []SvgElement, fill color.Color) []SvgElement {
+	var result []SvgElement
+	for _, el := range elements {
+		a, ok := el.(animatable)
+		if !ok {
+			result = append(result, el)
+			continue
+		}
+		c := a.common()
+		if !pinned(c) && c.Display == "none" {
+			continue
+		}
+		clr := el.Color()
+		if clr == nil {
+			clr = fill
+		}
+		if g, ok := el.(*SvgGroup); ok {
+			children := removeInvisible(g.Elements, clr)
+			if len(children) > 0 || pinned(c) {
+				result = append(result, withElements(g, children))
+			}
+			continue
+		}
+		if !pinned(c) {
+			_, isImage := el.(*SvgImage)
+			if c.hidden() || (transparent(clr) && !isImage) || !geometryBounds(el, Identity, true).ok {
+				continue
+			}
+		}
+		result = append(result, el)
+	}
+	return result
+}
+
+// mergeable checks if a rectangle can be merged with the previous one into a path
+func mergeable(r, previous *SvgRectangle) bool {
+	if pinned(r.Common) || r.Transform != nil || r.FillRule == "evenodd" || r.Stroke != nil || r.Width <= 0 || r.Height <= 0 {
+		return false
+	}
+	return previous == nil || sameColor(r.Fill, previous.Fill) && reflect.DeepEqual(r.Common, previous.Common)
+}
+
+// maxUnionCells is the largest grid that the outline of a union of rectangles is traced on. Larger unions
+// are merged with one subpath per rectangle, which fills the same pixels with the non-zero rule.
+const maxUnionCells = 1 << 20
+
+// unionOutline returns the path commands that trace the outline of the union of the rectangles, with one
+// subpath for each outline and hole. Outlines go clockwise and holes counterclockwise, so the path is
+// filled the same with the non-zero and the even-odd rule. It returns false if the union is too complex.
+func unionOutline(rects []*SvgRectangle) ([]PathCommand, bool) {
+	// Split the plane into a grid of cells at each edge of a rectangle, and mark the cells that are covered
+	var xs, ys []int
+	for _, r := range rects {
+		xs = append(xs, r.X, r.X+r.Width)
+		ys = append(ys, r.Y, r.Y+r.Height)
+	}
+	xs, ys = uniqueSorted(xs), uniqueSorted(ys)
+	w, h := len(xs)-1, len(ys)-1
+	if w*h > maxUnionCells {
+		return nil, false
+	}
+	covered := make([]bool, w*h)
+	for _, r := range rects {
+		x0, x1 := sort.SearchInts(xs, r.X), sort.SearchInts(xs, r.X+r.Width)
+		y0, y1 := sort.SearchInts(ys, r.Y), sort.SearchInts(ys, r.Y+r.Height)
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				covered[y*w+x] = true
+			}
+		}
+	}
+	inside := func(x, y int) bool {
+		return x >= 0 && x < w && y >= 0 && y < h && covered[y*w+x]
+	}
+
+	// Collect the edges between covered and uncovered cells, between grid points, with the covered cell on
+	// the right. Which edge is taken at a point with two of them does not matter, since it keeps the winding.
+	next := make(map[image.Point][]image.Point)
+	var starts []image.Point
+	edge := func(from, to image.Point) {
+		if len(next[from]) == 0 {
+			starts = append(starts, from)
+		}
+		next[from] = append(next[from], to)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !inside(x, y) {
+				continue
+			}
+			if !inside(x, y-1) {
+				edge(image.Pt(x, y), image.Pt(x+1, y))
+			}
+			if !inside(x+1, y) {
+				edge(image.Pt(x+1, y), image.Pt(x+1, y+1))
+			}
+			if !inside(x, y+1) {
+				edge(image.Pt(x+1, y+1), image.Pt(x, y+1))
+			}
+			if !inside(x-1, y) {
+				edge(image.Pt(x, y+1), image.Pt(x, y))
+			}
+		}
+	}
+
+	// Follow the edges around each outline, and keep only the corners
+	var commands []PathCommand
+	for i := 0; i < len(starts); i++ {
+		start := starts[i]
+		if len(next[start]) == 0 {
+			continue
+		}
+		// Come back to this point after the loop, in case there is another loop through it
+		starts = append(starts, start)
+		var loop []image.Point
+		for p := start; len(next[p]) > 0; {
+			loop = append(loop, p)
+			to := next[p][len(next[p])-1]
+			next[p] = next[p][:len(next[p])-1]
+			p = to
+		}
+		var corners []image.Point
+		for i, p := range loop {
+			prev, after := loop[(i+len(loop)-1)%len(loop)], loop[(i+1)%len(loop)]
+			if (prev.X == p.X) != (p.X == after.X) {
+				corners = append(corners, image.Pt(xs[p.X], ys[p.Y]))
+			}
+		}
+		if len(corners) == 0 {
+			continue
+		}
+		commands = append(commands, PathCommand{Type: "M", Points: []image.Point{corners[0]}})
+		for i := 1; i < len(corners); i++ {
+			if corners[i].Y == corners[i-1].Y {
+				commands = append(commands, PathCommand{Type: "H", Points: []image.Point{{X: corners[i].X}}})
+			} else {
+				commands = append(commands, PathCommand{Type: "V", Points: []image.Point{{Y: corners[i].Y}}})
+			}
+		}
+		commands = append(commands, PathCommand{Type: "Z"})
+	}
+	return commands, true
+}
+
+// uniqueSorted sorts the numbers and removes the duplicates
+func uniqueSorted(numbers []int) []int {
+	sort.Ints(numbers)
+	result := numbers[:0]
+	for i, n := range numbers {
+		if i == 0 || n != numbers[i-1] {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// mergeRects merges adjacent rectangles with the same fill into paths that trace the outline of their union,
+// so that rectangles that touch or overlap are filled as one shape
+func mergeRects(elements []SvgElement, fill color.Color) []SvgElement {
+	var result []SvgElement
+	var run []*SvgRectangle
+	flush := func() {
+		switch len(run) {
+		case 0:
+		case 1:
+			result = append(result, run[0])
+		default:
+			path := &SvgPath{Common: run[0].Common, Fill: run[0].Fill}
+			commands, ok := unionOutline(run)
+			if !ok {
+				for _, r := range run {
+					commands = append(commands,
+						PathCommand{Type: "M", Points: []image.Point{{r.X, r.Y}}},
+						PathCommand{Type: "h", Points: []image.Point{{X: r.Width}}},
+						PathCommand{Type: "v", Points: []image.Point{{Y: r.Height}}},
+						PathCommand{Type: "h", Points: []image.Point{{X: -r.Width}}},
+						PathCommand{Type: "z"})
+				}
+			}
+			path.Commands = commands
+			result = append(result, path)
+		}
+		run = nil
+	}
+	for _, el := range elements {
+		if r, ok := el.(*SvgRectangle); ok && mergeable(r, nil) {
+			if len(run) > 0 && !mergeable(r, run[0]) {
+				flush()
+			}
+			run = append(run, r)
+			continue
+		}
+		flush()
+		if g, ok := el.(*SvgGroup); ok {
+			el = withElements(g, mergeRects(g.Elements, fill))
+		}
+		result = append(result, el)
+	}
+	flush()
+	return result
+}
+
+// collapseGroups moves the elements of groups that have no effect into their parent, and merges a group
+// with a single child into the child
+func collapseGroups(elements []SvgElement, fill color.Color) []SvgElement {
+	var result []SvgElement
+	for _, el := range elements {
+		g, ok := el.(*SvgGroup)
+		if !ok {
+			result = append(result, el)
+			continue
+		}
+		children := collapseGroups(g.Elements, fill)
+		if pinned(g.Common) || g.Display != "" {
+			result = append(result, withElements(g, children))
+			continue
+		}
+		if g.Fill == nil && g.Transform == nil {
+			result = append(result, children...)
+			continue
+		}
+		if len(children) == 1 {
+			child, ok := children[0].(animatable)
+			if ok && !pinned(child.common()) && (g.Fill == nil || hasFill(child) || child.Color() != nil) {
+				result = append(result, changed(child, func(c *Common, childFill *color.Color) {
+					if *childFill == nil {
+						*childFill = g.Fill
+					}
+					if g.Transform != nil {
+						m := transform(*g.Transform, c.Transform)
+						c.Transform = &m
+					}
+				}))
+				continue
+			}
+		}
+		result = append(result, withElements(g, children))
+	}
+	return result
+}
+
+// sharedFill returns the fill color that all the elements that are painted with a fill have, or nil if
+// they have different ones or if any of them can not be changed
+func sharedFill(elements []SvgElement) color.Color {
+	var shared color.Color
+	for _, el := range elements {
+		switch e := el.(type) {
+		case *SvgImage:
+			continue
+		case *SvgLine:
+			if e.Stroke != nil {
+				continue
+			}
+			return nil
+		}
+		a, ok := el.(animatable)
+		if !ok || pinned(a.common()) || el.Color() == nil || shared != nil && !sameColor(shared, el.Color()) {
+			return nil
+		}
+		shared = el.Color()
+	}
+	return shared
+}
+
+// withoutFill returns copies of the elements that inherit their fill color
+func withoutFill(elements []SvgElement) []SvgElement {
+	result := make([]SvgElement, len(elements))
+	for i, el := range elements {
+		result[i] = changed(el, func(_ *Common, fill *color.Color) {
+			*fill = nil
+		})
+	}
+	return result
+}
+
+// hoistFill moves the fill color that all the children of a group have to the group, and puts runs of
+// adjacent elements with the same fill color into a new group with that fill
+func hoistFill(elements []SvgElement, fill color.Color) []SvgElement {
+	return hoist(elements, false)
+}
+
+// hoist is hoistFill for the elements of the document, or of a group if inGroup is true. All the elements
+// of a group are not put into a new group, since the fill color can be moved to the group itself.
+func hoist(elements []SvgElement, inGroup bool) []SvgElement {
+	var result []SvgElement
+	var run []SvgElement
+	flush := func() {
+		if len(run) < 2 || inGroup && len(run) == len(elements) {
+			result = append(result, run...)
+		} else {
+			c := run[0].(animatable).common()
+			group := &SvgGroup{Fill: run[0].Color(), Elements: withoutFill(run)}
+			group.ShapeRendering, group.TextRendering, group.ImageRendering = c.ShapeRendering, c.TextRendering, c.ImageRendering
+			group.FillRule, group.Visibility, group.PointerEvents = c.FillRule, c.Visibility, c.PointerEvents
+			result = append(result, group)
+		}
+		run = nil
+	}
+	for _, el := range elements {
+		if g, ok := el.(*SvgGroup); ok {
+			children := hoist(g.Elements, true)
+			if shared := sharedFill(children); shared != nil && !pinned(g.Common) {
+				g = withElements(g, withoutFill(children))
+				g.Fill = shared
+			} else {
+				g = withElements(g, children)
+			}
+			el = g
+		}
+		// Only leaf elements are put into new groups, since groups can have the fill color themselves
+		a, ok := el.(animatable)
+		_, isGroup := el.(*SvgGroup)
+		if !ok || isGroup || !hasFill(el) || pinned(a.common()) || el.Color() == nil {
+			flush()
+			result = append(result, el)
+			continue
+		}
+		if len(run) > 0 && !sameColor(run[0].Color(), el.Color()) {
+			flush()
+		}
+		run = append(run, el)
+	}
+	flush()
+	return result
+}
+
+// minimalPaths rewrites the commands of paths in the shortest form of FormatPathData
+func minimalPaths(elements []SvgElement, fill color.Color) []SvgElement {
+	result := make([]SvgElement, len(elements))
+	for i, el := range elements {
+		switch e := el.(type) {
+		case *SvgGroup:
+			el = withElements(e, minimalPaths(e.Elements, fill))
+		case *SvgPath:
+			if pinned(e.Common) {
+				break
+			}
+			if path, err := ParsePath(FormatPathData(e.Commands, PathMinimal)); err == nil {
+				p := *e
+				p.Commands = path.Commands
+				el = &p
+			}
+		}
+		result[i] = el
+	}
+	return result
+}
+
+// removeUnusedDefs removes the elements in defs that no animation refers to
+func removeUnusedDefs(elements, defs []SvgElement) []SvgElement {
+	used := make(map[string]bool)
+	var collect func(elements []SvgElement)
+	collect = func(elements []SvgElement) {
+		for _, el := range elements {
+			var animations []SvgAnimation
+			switch e := el.(type) {
+			case *SvgAnimation:
+				animations = append(animations, *e)
+			case animatable:
+				animations = e.common().Animations
+			}
+			for _, a := range animations {
+				used[a.Target] = true
+				used[a.MPath] = true
+			}
+			if g, ok := el.(*SvgGroup); ok {
+				collect(g.Elements)
+			}
+		}
+	}
+	collect(elements)
+	collect(defs)
+	var referred func(el SvgElement) bool
+	referred = func(el SvgElement) bool {
+		if a, ok := el.(animatable); ok && a.common().ID != "" && used[a.common().ID] {
+			return true
+		}
+		if g, ok := el.(*SvgGroup); ok {
+			for _, child := range g.Elements {
+				if referred(child) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	var result []SvgElement
+	for _, el := range defs {
+		if referred(el) {
+			result = append(result, el)
+		}
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package surrender
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimize(t *testing.T) {
+	doc, err := ParseFile("testdata/optimize.svg")
+	assert.NoError(t, err)
+	optimized := Optimize(doc)
+	assert.Equal(t, renderPixels(doc, aliasedChecks), renderPixels(optimized, aliasedChecks))
+
+	var buf bytes.Buffer
+	_, err = optimized.WriteWithOptions(&buf, WriteOptions{MinimalPaths: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" version="1.2" baseProfile="tiny" width="20" height="20">`+
+		`<defs><path id="track" d="M0 0H10"/></defs>`+
+		`<path d="M0 0H10V2H4V4H0Z" fill="#f00"/>`+
+		`<g fill="#00f"><rect x="10" y="10" width="1" height="1"/><circle cx="15" cy="5" r="2"/></g>`+
+		`<path d="M0 15H4v2Z" fill="#ff0" transform="translate(2 2)"/>`+
+		`<circle id="mover" cx="0" cy="15" r="2" fill="#00f"><animateMotion dur="2s"><mpath xlink:href="#track"/></animateMotion></circle>`+
+		"</svg>\n", buf.String())
+	assert.Same(t, optimized.Defs[0], optimized.ElementByID("track"))
+
+	// The original document is not changed
+	original, err := ParseFile("testdata/optimize.svg")
+	assert.NoError(t, err)
+	original.source, doc.source = nil, nil // each parse has its own source
+	assert.Equal(t, original, doc)
+}
+
+func TestOptimizeRainforest(t *testing.T) {
+	doc, err := ParseFile("testdata/rainforest_8c_opt.svg")
+	assert.NoError(t, err)
+	optimized := Optimize(doc)
+	assert.Equal(t, renderPixels(doc, aliasedChecks), renderPixels(optimized, aliasedChecks))
+
+	// The fixture is already optimized, so only check that it does not get any larger
+	var before, after bytes.Buffer
+	_, err = doc.WriteWithOptions(&before, WriteOptions{})
+	assert.NoError(t, err)
+	_, err = optimized.WriteWithOptions(&after, WriteOptions{MinimalPaths: true})
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, after.Len(), before.Len())
+	t.Log(before.Len(), after.Len())
+}
+
+func TestMergeRects(t *testing.T) {
+	// Four touching and overlapping rectangles around a hole, and one that is apart from them
+	doc, err := ParseBytes([]byte(`<svg xmlns="http://www.w3.org/2000/svg" width="20" height="20">` +
+		`<rect x="2" y="2" width="9" height="3" fill="red"/><rect x="8" y="2" width="3" height="9" fill="red"/>` +
+		`<rect x="2" y="8" width="9" height="3" fill="red"/><rect x="2" y="5" width="3" height="3" fill="red"/>` +
+		`<rect x="14" y="14" width="2" height="2" fill="red"/></svg>`))
+	assert.NoError(t, err)
+	optimized := Optimize(doc)
+	assert.Len(t, optimized.Elements, 1)
+	assert.Equal(t, renderPixels(doc, aliasedChecks), renderPixels(optimized, aliasedChecks))
+
+	var buf bytes.Buffer
+	_, err = optimized.WriteWithOptions(&buf, WriteOptions{MinimalPaths: true})
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `<path d="M2 2h9v9H2ZM8 5H5V8H8Zm6 9h2v2H14Z" fill="#f00"/>`)
+
+	// Filled as one shape, the rectangles have no seam where they touch when antialiased
+	opts := RenderOptions{Scale: 1.5, Antialias: true}
+	img, err := RenderDocument(doc, opts)
+	assert.NoError(t, err)
+	assert.Less(t, img.RGBAAt(4, 7).A, uint8(255))
+	img, err = RenderDocument(optimized, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(255), img.RGBAAt(4, 7).A)
+}
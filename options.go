@@ -0,0 +1,176 @@
+package surrender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+)
+
+// FitMode decides how the document is scaled when the output size has a different aspect ratio
+type FitMode int
+
+const (
+	// FitContain scales the document uniformly, so that all of it fits within the output, centered
+	FitContain FitMode = iota
+	// FitCover scales the document uniformly, so that it covers all of the output, centered and cropped
+	FitCover
+	// FitStretch scales the width and the height of the document independently, to fill the output
+	FitStretch
+)
+
+// cssDPI is the resolution that pixel sizes in SVG documents are specified in
+const cssDPI = 96
+
+// RenderOptions configures how RenderDocument renders a document
+type RenderOptions struct {
+	// Width and Height are the size of the output, in pixels. If only one of them is set,
+	// the other one follows the aspect ratio of the document. If neither is set, the size of
+	// the document is used, multiplied by Scale and DPI.
+	Width, Height int
+	// Scale multiplies the size of the document, when Width and Height are not set. 0 means 1.
+	Scale float64
+	// DPI is the resolution of the output, where 96 or 0 means one pixel per CSS pixel
+	DPI float64
+	// Background is the color the output is filled with before rendering, or nil for transparent
+	Background color.Color
+	// Fit decides how the document is scaled when Width and Height give a different aspect ratio
+	Fit FitMode
+	// Tolerance is the largest distance, in pixels, between a curve and the line segments that
+	// approximate it. 0 means the default of 0.25. Larger values render faster, but less smooth.
+	Tolerance float64
+	// Antialias draws the edges of filled shapes with the part of each pixel that they cover as the
+	// opacity. Without it, pixels are either filled or not, which keeps pixel art sharp.
+	Antialias bool
+	// MaxPixels is the largest number of pixels the output may have. If it is 0, DefaultMaxPixels is used.
+	MaxPixels int
+	// Context can cancel rendering or give it a deadline, and its error is returned if it is done
+	// before rendering is. If it is nil, rendering can not be cancelled.
+	Context context.Context
+}
+
+// outputSize returns the size of the output image, given the options and the size of what is rendered
+func (o RenderOptions) outputSize(w, h float64) (int, int, error) {
+	if !(w > 0 && h > 0) {
+		return 0, 0, errors.New("nothing to render, since the size is zero")
+	}
+	if o.Scale < 0 || o.DPI < 0 || o.Width < 0 || o.Height < 0 || o.Tolerance < 0 {
+		return 0, 0, errors.New("negative render option")
+	}
+	var outW, outH float64
+	switch {
+	case o.Width > 0 && o.Height > 0:
+		outW, outH = float64(o.Width), float64(o.Height)
+	case o.Width > 0:
+		outW, outH = float64(o.Width), float64(o.Width)*h/w
+	case o.Height > 0:
+		outW, outH = float64(o.Height)*w/h, float64(o.Height)
+	default:
+		scale := 1.0
+		if o.Scale > 0 {
+			scale = o.Scale
+		}
+		if o.DPI > 0 {
+			scale *= o.DPI / cssDPI
+		}
+		outW, outH = w*scale, h*scale
+	}
+	// The size is checked before it is rounded, so that huge sizes can not overflow
+	if maxPixels := float64(orDefault(o.MaxPixels, DefaultMaxPixels)); outW*outH > maxPixels || outW > maxPixels || outH > maxPixels {
+		return 0, 0, fmt.Errorf("%w: %.0f x %.0f is more than %.0f", ErrTooManyPixels, outW, outH, maxPixels)
+	}
+	return max1(roundCoordinate(outW)), max1(roundCoordinate(outH)), nil
+}
+
+// max1 returns the given size, but at least 1
+func max1(size int) int {
+	if size < 1 {
+		return 1
+	}
+	return size
+}
+
+// fitTransform returns the matrix that maps the view box into the output, according to the fit mode
+func (o RenderOptions) fitTransform(vb ViewBox, width, height int) Matrix {
+	preserveAspectRatio := "xMidYMid meet"
+	switch o.Fit {
+	case FitCover:
+		preserveAspectRatio = "xMidYMid slice"
+	case FitStretch:
+		preserveAspectRatio = "none"
+	}
+	return fitViewBox(vb, 0, 0, float64(width), float64(height), preserveAspectRatio)
+}
+
+// RenderDocument renders the document to a new image, with the size, scale, background and quality
+// given by the options. Use doc.At to render the document at a given time in its animations.
+func RenderDocument(doc *Document, opts RenderOptions) (*image.RGBA, error) {
+	viewport := ViewBox{0, 0, float64(doc.Width), float64(doc.Height)}
+	return renderView(doc.Elements, defaultFill, doc.viewTransform(), viewport, viewport.Width, viewport.Height, opts)
+}
+
+// renderView renders the elements to a new image, where the elements are drawn with the fill color and
+// the transformation m from their parents, and the view box vb is fitted into the output. The size of the
+// output is given by the options, where width x height is the size at a scale of 1.
+func renderView(elements []SvgElement, inherited color.Color, m Matrix, vb ViewBox, width, height float64, opts RenderOptions) (*image.RGBA, error) {
+	w, h, err := opts.outputSize(width, height)
+	if err != nil {
+		return nil, err
+	}
+	if cancelled(opts.Context) {
+		return nil, opts.Context.Err()
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	if opts.Background != nil {
+		draw.Draw(img, img.Bounds(), &image.Uniform{opts.Background}, image.Point{}, draw.Src)
+	}
+	s := opts.state()
+	s.ctm = opts.fitTransform(vb, w, h).Multiply(m)
+	for _, el := range elements {
+		drawElement(img, el, s, inherited)
+	}
+	if cancelled(s.ctx) {
+		return nil, s.ctx.Err()
+	}
+	return img, nil
+}
+
+// state returns the draw state for the quality settings of the options
+func (o RenderOptions) state() drawState {
+	s := defaultState
+	s.antialias = o.Antialias
+	s.ctx = o.Context
+	if o.Tolerance > 0 {
+		s.tolerance = o.Tolerance
+	}
+	return s
+}
+
+// lengthUnits is the number of CSS pixels per unit, for the units that can be used for the document size
+var lengthUnits = map[string]float64{
+	"px": 1,
+	"in": cssDPI,
+	"cm": cssDPI / 2.54,
+	"mm": cssDPI / 25.4,
+	"pt": cssDPI / 72.0,
+	"pc": cssDPI / 6.0,
+}
+
+// parseAbsoluteLength parses a length with an optional absolute unit, and returns it in CSS pixels
+func parseAbsoluteLength(value string) (float64, bool) {
+	scale := 1.0
+	if len(value) > 2 {
+		if unit, ok := lengthUnits[value[len(value)-2:]]; ok {
+			value, scale = value[:len(value)-2], unit
+		}
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	return f * scale, !math.IsNaN(f) && !math.IsInf(f, 0)
+}
@@ -0,0 +1,210 @@
+package surrender
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const optionsSVG = `<svg xmlns="http://www.w3.org/2000/svg" width="20" height="10">
+  <rect x="0" y="0" width="20" height="10" fill="red"/>
+</svg>`
+
+func TestRenderOptionsOutputSize(t *testing.T) {
+	for _, tc := range []struct {
+		opts          RenderOptions
+		width, height int
+	}{
+		{RenderOptions{}, 20, 10},
+		{RenderOptions{Scale: 2}, 40, 20},
+		{RenderOptions{DPI: 192}, 40, 20},
+		{RenderOptions{Scale: 2, DPI: 48}, 20, 10},
+		{RenderOptions{Width: 100}, 100, 50},
+		{RenderOptions{Height: 30}, 60, 30},
+		{RenderOptions{Width: 7, Height: 9, Scale: 3}, 7, 9},
+	} {
+		w, h, err := tc.opts.outputSize(20, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.width, w, "%+v", tc.opts)
+		assert.Equal(t, tc.height, h, "%+v", tc.opts)
+	}
+
+	_, _, err := RenderOptions{Scale: -1}.outputSize(20, 10)
+	assert.Error(t, err)
+	_, _, err = RenderOptions{}.outputSize(0, 10)
+	assert.Error(t, err)
+}
+
+func TestRenderDocumentFit(t *testing.T) {
+	doc, err := ParseBytes([]byte(optionsSVG))
+	assert.NoError(t, err)
+	red := color.RGBA{255, 0, 0, 255}
+
+	// The document is centered, with transparent bands above and below
+	img, err := RenderDocument(doc, RenderOptions{Width: 20, Height: 20})
+	assert.NoError(t, err)
+	assert.Equal(t, 20, img.Bounds().Dx())
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(10, 2))
+	assert.Equal(t, red, img.RGBAAt(10, 10))
+
+	// Covering the output crops the sides instead
+	img, err = RenderDocument(doc, RenderOptions{Width: 20, Height: 20, Fit: FitCover})
+	assert.NoError(t, err)
+	assert.Equal(t, red, img.RGBAAt(10, 2))
+	assert.Equal(t, red, img.RGBAAt(0, 19))
+
+	img, err = RenderDocument(doc, RenderOptions{Width: 20, Height: 20, Fit: FitStretch})
+	assert.NoError(t, err)
+	assert.Equal(t, red, img.RGBAAt(0, 0))
+	assert.Equal(t, red, img.RGBAAt(19, 19))
+}
+
+func TestRenderDocumentBackground(t *testing.T) {
+	doc, err := ParseBytes([]byte(optionsSVG))
+	assert.NoError(t, err)
+	white := color.RGBA{255, 255, 255, 255}
+
+	img, err := RenderDocument(doc, RenderOptions{Width: 20, Height: 20, Background: white})
+	assert.NoError(t, err)
+	assert.Equal(t, white, img.RGBAAt(10, 2))
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, img.RGBAAt(10, 10))
+}
+
+func TestRenderDocumentTolerance(t *testing.T) {
+	doc, err := ParseBytes([]byte(`<svg xmlns="http://www.w3.org/2000/svg" width="100" height="100">
+  <path d="M5 50 C5 -10 95 -10 95 50 Z" fill="black"/>
+</svg>`))
+	assert.NoError(t, err)
+
+	count := func(tolerance float64) int {
+		img, err := RenderDocument(doc, RenderOptions{Tolerance: tolerance})
+		assert.NoError(t, err)
+		n := 0
+		for i := 3; i < len(img.Pix); i += 4 {
+			if img.Pix[i] != 0 {
+				n++
+			}
+		}
+		return n
+	}
+	// A coarse tolerance cuts off more of the curve than the default
+	fine, coarse := count(0), count(20)
+	assert.Less(t, coarse, fine-50)
+}
+
+func TestParseAbsoluteLength(t *testing.T) {
+	for value, expected := range map[string]float64{
+		"12":     12,
+		"12px":   12,
+		"1in":    96,
+		"2.54cm": 96,
+		"25.4mm": 96,
+		"72pt":   96,
+		"6pc":    96,
+	} {
+		f, ok := parseAbsoluteLength(value)
+		assert.True(t, ok, value)
+		assert.InDelta(t, expected, f, 1e-9, value)
+	}
+	for _, value := range []string{"", "50%", "1em", "px", "NaN"} {
+		_, ok := parseAbsoluteLength(value)
+		assert.False(t, ok, value)
+	}
+
+	doc, err := ParseBytes([]byte(`<svg xmlns="http://www.w3.org/2000/svg" width="1in" height="10mm"/>`))
+	assert.NoError(t, err)
+	assert.Equal(t, 96, doc.Width)
+	assert.Equal(t, 38, doc.Height)
+}
+
+func TestRenderDocumentAntialias(t *testing.T) {
+	doc, err := ParseBytes([]byte(`<svg xmlns="http://www.w3.org/2000/svg" width="20" height="20">
+  <rect x="2" y="2" width="10" height="10" fill="red" transform="translate(0.5 0)"/>
+</svg>`))
+	assert.NoError(t, err)
+
+	// Without antialiasing, pixels are either covered or not
+	img, err := RenderDocument(doc, RenderOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(255), img.RGBAAt(2, 5).A)
+	assert.Equal(t, uint8(0), img.RGBAAt(12, 5).A)
+
+	// With antialiasing, the pixels that the left and right edges cut in half are half transparent
+	img, err = RenderDocument(doc, RenderOptions{Antialias: true})
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, img.RGBAAt(5, 5))
+	assert.InDelta(t, 128, img.RGBAAt(2, 5).A, 1)
+	assert.InDelta(t, 128, img.RGBAAt(12, 5).A, 1)
+	assert.Equal(t, uint8(0), img.RGBAAt(1, 5).A)
+	assert.Equal(t, uint8(0), img.RGBAAt(13, 5).A)
+	assert.Equal(t, uint8(0), img.RGBAAt(5, 12).A)
+}
+
+func TestAntialiasCoverage(t *testing.T) {
+	doc, err := ParseBytes([]byte(`<svg xmlns="http://www.w3.org/2000/svg" width="40" height="40">
+  <rect x="15" y="15" width="10" height="10" fill="white" transform="rotate(30 20 20)"/>
+  <rect x="0" y="0" width="4" height="4" fill="white" transform="rotate(90 2 2)"/>
+</svg>`))
+	assert.NoError(t, err)
+	img, err := RenderDocument(doc, RenderOptions{Antialias: true})
+	assert.NoError(t, err)
+
+	// The total opacity adds up to the area of the shapes
+	total := 0.0
+	for i := 3; i < len(img.Pix); i += 4 {
+		total += float64(img.Pix[i]) / 255
+	}
+	assert.InDelta(t, 100+16, total, 1)
+
+	// Pixel-aligned edges are not blurred
+	assert.Equal(t, uint8(255), img.RGBAAt(0, 3).A)
+	assert.Equal(t, uint8(0), img.RGBAAt(4, 3).A)
+}
+
+func TestParseRenderingProperties(t *testing.T) {
+	doc, err := ParseFileWithOptions("testdata/rendering.svg", ParseOptions{ImageDir: "testdata"})
+	assert.NoError(t, err)
+
+	// The properties are inherited from the group, unless the element sets them
+	crisp := doc.ElementByID("crisp").(*SvgRectangle)
+	assert.Equal(t, "crispEdges", crisp.ShapeRendering)
+	assert.Equal(t, "optimizeSpeed", crisp.ImageRendering)
+	assert.Equal(t, "geometricPrecision", doc.ElementByID("smooth").(*SvgRectangle).ShapeRendering)
+	assert.Equal(t, "optimizeSpeed", doc.ElementByID("pixelated").(*SvgImage).ImageRendering)
+	assert.Equal(t, "", doc.ElementByID("interpolated").(*SvgImage).ImageRendering)
+	assert.Equal(t, "geometricPrecision", doc.ElementByID("label").(*SvgText).TextRendering)
+}
+
+func TestRenderingProperties(t *testing.T) {
+	doc, err := ParseFileWithOptions("testdata/rendering.svg", ParseOptions{ImageDir: "testdata"})
+	assert.NoError(t, err)
+
+	partial := func(img *image.RGBA, r image.Rectangle) bool {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if a := img.RGBAAt(x, y).A; a != 0 && a != 255 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for _, antialias := range []bool{false, true} {
+		img, err := RenderDocument(doc, RenderOptions{Antialias: antialias})
+		assert.NoError(t, err)
+
+		// The shape-rendering property decides, whatever the render options are
+		assert.False(t, partial(img, image.Rect(0, 0, 20, 15)))
+		assert.True(t, partial(img, image.Rect(0, 18, 20, 33)))
+		// The same goes for text-rendering
+		assert.True(t, partial(img, image.Rect(15, 35, 45, 60)))
+
+		// The pixelated image has no blended pixels between red and green
+		assert.Equal(t, color.RGBA{255, 0, 0, 255}, img.RGBAAt(49, 5))
+		assert.Equal(t, color.RGBA{0, 255, 0, 255}, img.RGBAAt(50, 5))
+		assert.NotEqual(t, color.RGBA{255, 0, 0, 255}, img.RGBAAt(79, 5))
+	}
+}
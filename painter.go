@@ -0,0 +1,153 @@
+package surrender
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// painter draws horizontal spans of a single color onto a draw.Image. The color is converted
+// once, so that *image.RGBA, *image.NRGBA, *image.Gray and *image.Paletted can be written directly.
+type painter struct {
+	img    draw.Image
+	clr    color.Color
+	bounds image.Rectangle
+	opaque bool
+	rgba   color.RGBA
+	nrgba  color.NRGBA
+	gray   color.Gray
+	index  uint8
+
+	// sr, sg, sb, sa and a are used when drawing a translucent color over *image.RGBA pixels
+	sr, sg, sb, sa, a uint32
+}
+
+// newPainter returns a painter for drawing the given color onto the image
+func newPainter(img draw.Image, clr color.Color) painter {
+	p := painter{img: img, clr: clr, bounds: img.Bounds()}
+	sr, sg, sb, sa := clr.RGBA()
+	p.opaque = sa == 0xffff
+	switch dst := img.(type) {
+	case *image.RGBA:
+		p.rgba = color.RGBAModel.Convert(clr).(color.RGBA)
+		p.sr, p.sg, p.sb, p.sa, p.a = sr, sg, sb, sa, (0xffff-sa)*0x101
+	case *image.NRGBA:
+		p.nrgba = color.NRGBAModel.Convert(clr).(color.NRGBA)
+	case *image.Gray:
+		p.gray = color.GrayModel.Convert(clr).(color.Gray)
+	case *image.Paletted:
+		if len(dst.Palette) > 0 {
+			p.index = uint8(dst.Palette.Index(clr))
+		}
+	}
+	return p
+}
+
+// clip returns the part of the span from x0 to x1 on row y that is within the image
+func (p painter) clip(x0, x1, y int) (int, int, bool) {
+	if y < p.bounds.Min.Y || y >= p.bounds.Max.Y {
+		return 0, 0, false
+	}
+	if x0 < p.bounds.Min.X {
+		x0 = p.bounds.Min.X
+	}
+	if x1 > p.bounds.Max.X {
+		x1 = p.bounds.Max.X
+	}
+	return x0, x1, x0 < x1
+}
+
+// set replaces the pixel at (x, y) with the color, like img.Set
+func (p painter) set(x, y int) {
+	p.replace(x, x+1, y)
+}
+
+// replace replaces the pixels from x0 to x1 on row y with the color
+func (p painter) replace(x0, x1, y int) {
+	x0, x1, ok := p.clip(x0, x1, y)
+	if !ok {
+		return
+	}
+	switch dst := p.img.(type) {
+	case *image.RGBA:
+		pix := dst.Pix[dst.PixOffset(x0, y):dst.PixOffset(x1, y)]
+		for i := 0; i < len(pix); i += 4 {
+			pix[i], pix[i+1], pix[i+2], pix[i+3] = p.rgba.R, p.rgba.G, p.rgba.B, p.rgba.A
+		}
+	case *image.NRGBA:
+		pix := dst.Pix[dst.PixOffset(x0, y):dst.PixOffset(x1, y)]
+		for i := 0; i < len(pix); i += 4 {
+			pix[i], pix[i+1], pix[i+2], pix[i+3] = p.nrgba.R, p.nrgba.G, p.nrgba.B, p.nrgba.A
+		}
+	case *image.Gray:
+		pix := dst.Pix[dst.PixOffset(x0, y):dst.PixOffset(x1, y)]
+		for i := range pix {
+			pix[i] = p.gray.Y
+		}
+	case *image.Paletted:
+		if len(dst.Palette) == 0 {
+			return
+		}
+		pix := dst.Pix[dst.PixOffset(x0, y):dst.PixOffset(x1, y)]
+		for i := range pix {
+			pix[i] = p.index
+		}
+	default:
+		for x := x0; x < x1; x++ {
+			p.img.Set(x, y, p.clr)
+		}
+	}
+}
+
+// over draws the color over the pixels from x0 to x1 on row y, like draw.Draw with draw.Over
+func (p painter) over(x0, x1, y int) {
+	if p.opaque {
+		p.replace(x0, x1, y)
+		return
+	}
+	x0, x1, ok := p.clip(x0, x1, y)
+	if !ok {
+		return
+	}
+	if dst, ok := p.img.(*image.RGBA); ok {
+		// The same arithmetic as draw.Draw, so that the result does not depend on the image type
+		const m = 0xffff
+		pix := dst.Pix[dst.PixOffset(x0, y):dst.PixOffset(x1, y)]
+		for i := 0; i < len(pix); i += 4 {
+			pix[i] = uint8((uint32(pix[i])*p.a/m + p.sr) >> 8)
+			pix[i+1] = uint8((uint32(pix[i+1])*p.a/m + p.sg) >> 8)
+			pix[i+2] = uint8((uint32(pix[i+2])*p.a/m + p.sb) >> 8)
+			pix[i+3] = uint8((uint32(pix[i+3])*p.a/m + p.sa) >> 8)
+		}
+		return
+	}
+	draw.Draw(p.img, image.Rect(x0, y, x1, y+1), &image.Uniform{p.clr}, image.Point{}, draw.Over)
+}
+
+// blend draws the color over the pixel at (x, y), with its opacity multiplied by the coverage from 0 to 1
+func (p painter) blend(x, y int, coverage float64) {
+	if coverage >= 1 {
+		p.over(x, x+1, y)
+		return
+	}
+	if !image.Pt(x, y).In(p.bounds) {
+		return
+	}
+	sr, sg, sb, sa := p.clr.RGBA()
+	scale := func(v uint32) uint32 {
+		return uint32(float64(v)*coverage + 0.5)
+	}
+	sr, sg, sb, sa = scale(sr), scale(sg), scale(sb), scale(sa)
+	if dst, ok := p.img.(*image.RGBA); ok {
+		const m = 0xffff
+		a := (m - sa) * 0x101
+		pix := dst.Pix[dst.PixOffset(x, y):]
+		pix[0] = uint8((uint32(pix[0])*a/m + sr) >> 8)
+		pix[1] = uint8((uint32(pix[1])*a/m + sg) >> 8)
+		pix[2] = uint8((uint32(pix[2])*a/m + sb) >> 8)
+		pix[3] = uint8((uint32(pix[3])*a/m + sa) >> 8)
+		return
+	}
+	src := color.RGBA64{uint16(sr), uint16(sg), uint16(sb), uint16(sa)}
+	draw.Draw(p.img, image.Rect(x, y, x+1, y+1), &image.Uniform{src}, image.Point{}, draw.Over)
+}
@@ -0,0 +1,70 @@
+package surrender
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const painterSVG = `<svg xmlns="http://www.w3.org/2000/svg" width="40" height="30">
+  <rect x="2" y="2" width="20" height="10" fill="red"/>
+  <circle cx="30" cy="20" r="6" fill="#00ff00"/>
+  <path d="M5 28 L15 14 L25 28 Z" fill="blue" transform="rotate(5 15 20)"/>
+  <text x="2" y="28" font-size="10" fill="white">Hi</text>
+</svg>`
+
+// TestRenderImageTypes checks that rendering into other image types gives the same pixels
+// as rendering into an *image.RGBA and converting the result
+func TestRenderImageTypes(t *testing.T) {
+	doc, err := ParseBytes([]byte(painterSVG))
+	assert.NoError(t, err)
+	bounds := image.Rect(0, 0, doc.Width, doc.Height)
+	reference := image.NewRGBA(bounds)
+	doc.Render(reference)
+
+	for _, img := range []draw.Image{
+		image.NewNRGBA(bounds),
+		image.NewGray(bounds),
+		image.NewPaletted(bounds, palette.WebSafe),
+		image.NewRGBA64(bounds),
+	} {
+		// Start out with the same background as the reference
+		draw.Draw(img, bounds, image.Transparent, image.Point{}, draw.Src)
+		doc.Render(img)
+		expected := img.ColorModel()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				want := expected.Convert(reference.At(x, y))
+				if !assert.Equal(t, want, img.At(x, y), "%T at (%d, %d)", img, x, y) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func TestPainterOver(t *testing.T) {
+	translucent := color.NRGBA{0, 0, 255, 100}
+	for _, bg := range []color.Color{color.White, color.Transparent, color.RGBA{10, 200, 30, 255}} {
+		img := NewColoredImage(4, 1, bg)
+		expected := NewColoredImage(4, 1, bg)
+		newPainter(img, translucent).over(-2, 3, 0)
+		draw.Draw(expected, image.Rect(0, 0, 3, 1), &image.Uniform{translucent}, image.Point{}, draw.Over)
+		assert.Equal(t, expected.Pix, img.Pix)
+	}
+}
+
+func TestRenderSubImage(t *testing.T) {
+	doc, err := ParseBytes([]byte(painterSVG))
+	assert.NoError(t, err)
+
+	// Only the pixels within the sub image are drawn
+	img := image.NewGray(image.Rect(0, 0, 40, 30))
+	doc.Render(img.SubImage(image.Rect(0, 0, 10, 30)).(*image.Gray))
+	assert.NotEqual(t, uint8(0), img.GrayAt(5, 5).Y)
+	assert.Equal(t, uint8(0), img.GrayAt(15, 5).Y)
+}
@@ -1,8 +1,12 @@
 package surrender
 
 import (
+	"errors"
+	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
+	"io/fs"
 	"strconv"
 	"strings"
 
@@ -10,15 +14,41 @@ import (
 	"golang.org/x/image/colornames"
 )
 
+// Common holds the attributes that all elements have
+type Common struct {
+	ID         string
+	Transform  *Matrix        // nil if the element has no transform attribute
+	Animations []SvgAnimation // the animation elements that are children of the element
+
+	// The shape-rendering, text-rendering and image-rendering properties, from the element or its parents.
+	// They are empty if neither sets them, which is the same as "auto".
+	ShapeRendering, TextRendering, ImageRendering string
+
+	FillRule      string // "nonzero" or "evenodd", from the element or its parents, where "" means "nonzero"
+	Visibility    string // "visible", "hidden" or "collapse", from the element or its parents, where "" means "visible"
+	Display       string // "none" if the element and its children are not rendered
+	PointerEvents string // from the element or its parents, where "" means "visiblePainted"
+
+	specified propertySet // the inherited properties that are set on the element itself, instead of its parents
+}
+
+// hidden checks if the visibility property hides the element
+func (c Common) hidden() bool {
+	return c.Visibility == "hidden" || c.Visibility == "collapse"
+}
+
 type SvgElement interface {
-	Draw(img *image.RGBA, color color.Color)
+	Draw(img draw.Image, color color.Color)
 	Color() color.Color
 }
 
 // SvgCircle struct
 type SvgCircle struct {
-	Cx, Cy, R int
-	Fill      color.Color
+	Common
+	Cx, Cy, R   int
+	Fill        color.Color
+	Stroke      color.Color // nil if the circle has no stroke
+	StrokeWidth float64     // the width of the stroke, if it has one
 }
 
 func (c SvgCircle) Color() color.Color {
@@ -27,8 +57,11 @@ func (c SvgCircle) Color() color.Color {
 
 // SvgRectangle struct
 type SvgRectangle struct {
+	Common
 	X, Y, Width, Height int
 	Fill                color.Color
+	Stroke              color.Color // nil if the rectangle has no stroke
+	StrokeWidth         float64     // the width of the stroke, if it has one
 }
 
 func (r SvgRectangle) Color() color.Color {
@@ -37,8 +70,11 @@ func (r SvgRectangle) Color() color.Color {
 
 // SvgPath struct
 type SvgPath struct {
-	Commands []PathCommand
-	Fill     color.Color
+	Common
+	Commands    []PathCommand
+	Fill        color.Color
+	Stroke      color.Color // nil if the path has no stroke
+	StrokeWidth float64     // the width of the stroke, if it has one
 }
 
 func (p SvgPath) Color() color.Color {
@@ -52,6 +88,7 @@ type PathCommand struct {
 
 // New structure for SvgGroup
 type SvgGroup struct {
+	Common
 	Elements []SvgElement
 	Fill     color.Color
 }
@@ -62,6 +99,7 @@ func (g SvgGroup) Color() color.Color {
 
 // SvgLine struct
 type SvgLine struct {
+	Common
 	X1, Y1, X2, Y2 int
 	Stroke         color.Color
 }
@@ -70,92 +108,438 @@ func (l SvgLine) Color() color.Color {
 	return l.Stroke
 }
 
-// ParseFile will try to parse the given TinySVG 1.2 file into a slice of SvgElements
-func ParseFile(filename string) ([]SvgElement, error) {
-	doc := etree.NewDocument()
-	if err := doc.ReadFromFile(filename); err != nil {
-		return nil, err
+// ParseOptions holds the settings that are used when parsing
+type ParseOptions struct {
+	// ImageDir is the directory that image elements with relative paths are loaded from.
+	// Images are only loaded from local files if this or ImageFS is set.
+	ImageDir string
+	// ImageFS is the file system that image elements with relative paths are loaded from.
+	// If it is set, it is used instead of ImageDir.
+	ImageFS fs.FS
+	// MaxDecompressedSize is the largest number of bytes that the input may have, or that gzip compressed
+	// (.svgz) input may decompress to. If it is 0, DefaultMaxDecompressedSize is used.
+	MaxDecompressedSize int64
+	// Mode is ParseLenient by default, which parses what it can and keeps the problems in
+	// Document.Diagnostics, or ParseStrict, which fails on the first error with a *ParseError.
+	Mode ParseMode
+	// MaxElements is the largest number of elements a document may have. If it is 0, DefaultMaxElements is used.
+	MaxElements int
+	// MaxDepth is how deeply elements may be nested. If it is 0, DefaultMaxDepth is used.
+	MaxDepth int
+	// MaxPathSegments is the largest number of segments that all the paths of a document may have together.
+	// If it is 0, DefaultMaxPathSegments is used.
+	MaxPathSegments int
+	// MaxUseExpansion is the largest number of elements that use elements may expand to, together, where
+	// the use elements in what they refer to are expanded too. If it is 0, DefaultMaxUseExpansion is used.
+	// use elements are not rendered, but documents that exceed the limit, or where a use element refers
+	// to itself, fail with ErrUseExpansion.
+	MaxUseExpansion int
+	// MaxPixels is the largest number of pixels an image that an image element refers to may have.
+	// Larger images are not decoded. If it is 0, DefaultMaxPixels is used.
+	MaxPixels int
+}
+
+// parser holds the settings and state that are used while parsing a document
+type parser struct {
+	options     ParseOptions
+	defs        []SvgElement // the elements in defs elements, which are not rendered directly
+	positions   map[*etree.Element]position
+	diagnostics []Diagnostic
+	locations   map[SvgElement]location // where the parsed elements are in the source
+	paths       map[*etree.Element]string
+	err         error // the first error, in strict mode
+	segments    int   // the number of path segments so far
+	limit       error // the limit that was exceeded, which stops parsing in both modes
+}
+
+// style holds the inherited properties that are passed down from parent elements
+type style struct {
+	fontSize      int
+	textAnchor    string
+	textAlign     string
+	displayAlign  string
+	lineIncrement int
+	preserveSpace bool
+
+	shapeRendering, textRendering, imageRendering string
+	fillRule, visibility, pointerEvents           string
+}
+
+// defaultStyle returns the initial values of the inherited properties
+func defaultStyle() style {
+	return style{
+		fontSize:     defaultFontSize,
+		textAnchor:   "start",
+		textAlign:    "start",
+		displayAlign: "auto",
+	}
+}
+
+// inherit returns the style for the given element, using the parent style for the properties it does not set.
+// Values that are not supported are reported as warnings, and the parent style is used for them.
+func (p *parser) inherit(s style, el *etree.Element) style {
+	ignored := func(name string) {
+		p.report(SeverityWarning, el, name, fmt.Errorf("unsupported %s %q is ignored", name, el.SelectAttrValue(name, "")))
+	}
+	if v := el.SelectAttrValue("font-size", ""); v != "" && v != "inherit" {
+		if size, err := parseLength(v); err == nil && size > 0 {
+			s.fontSize = size
+		} else {
+			ignored("font-size")
+		}
+	}
+	switch v := el.SelectAttrValue("text-anchor", ""); v {
+	case "start", "middle", "end":
+		s.textAnchor = v
+	case "", "inherit":
+	default:
+		ignored("text-anchor")
+	}
+	switch v := el.SelectAttrValue("text-align", ""); v {
+	case "start", "center", "end":
+		s.textAlign = v
+	case "", "inherit":
+	default:
+		ignored("text-align")
+	}
+	switch v := el.SelectAttrValue("display-align", ""); v {
+	case "auto", "before", "center", "after":
+		s.displayAlign = v
+	case "", "inherit":
+	default:
+		ignored("display-align")
+	}
+	switch v := el.SelectAttrValue("line-increment", ""); v {
+	case "", "inherit":
+	case "auto":
+		s.lineIncrement = 0
+	default:
+		if inc, err := parseLength(v); err == nil && inc >= 0 {
+			s.lineIncrement = inc
+		} else {
+			ignored("line-increment")
+		}
+	}
+	switch el.SelectAttrValue("xml:space", "") {
+	case "preserve":
+		s.preserveSpace = true
+	case "default":
+		s.preserveSpace = false
+	}
+	for _, property := range []struct {
+		name  string
+		field *string
+	}{
+		{"shape-rendering", &s.shapeRendering},
+		{"text-rendering", &s.textRendering},
+		{"image-rendering", &s.imageRendering},
+		{"fill-rule", &s.fillRule},
+		{"visibility", &s.visibility},
+		{"pointer-events", &s.pointerEvents},
+	} {
+		switch v := el.SelectAttrValue(property.name, ""); {
+		case validProperty(property.name, v):
+			*property.field = v
+		case v != "" && v != "inherit":
+			ignored(property.name)
+		}
+	}
+	return s
+}
+
+// length parses a coordinate or length attribute, rounded to the nearest integer, or returns def if the
+// element does not have it. Values that are not numbers are reported as errors, and def is used for them.
+func (p *parser) length(el *etree.Element, name string, def int) int {
+	attr := el.SelectAttr(name)
+	if attr == nil {
+		return def
+	}
+	n, err := parseLength(attr.Value)
+	if err != nil {
+		p.report(SeverityError, el, name, fmt.Errorf("%q is not a number", attr.Value))
+		return def
 	}
+	return n
+}
 
-	return parseElements(doc.SelectElement("svg").ChildElements(), color.RGBA{0, 0, 0, 255}) // Pass default color
+// size is like length, for widths, heights and radii, where negative values are errors and 0 is used for them
+func (p *parser) size(el *etree.Element, name string) int {
+	n := p.length(el, name, 0)
+	if n < 0 {
+		p.report(SeverityError, el, name, fmt.Errorf("%q is negative", el.SelectAttrValue(name, "")))
+		return 0
+	}
+	return n
 }
 
-func parseElements(elements []*etree.Element, defaultColor color.Color) ([]SvgElement, error) {
+// paint parses a fill or stroke attribute, or def if the element does not have it. Colors that are not
+// recognized are reported as warnings, and are black, as with GetColor.
+func (p *parser) paint(el *etree.Element, name, def string) color.Color {
+	value := strings.TrimSpace(el.SelectAttrValue(name, def))
+	if value == "" || value == "inherit" {
+		return nil
+	}
+	c, ok := parseColor(value)
+	if !ok {
+		p.report(SeverityWarning, el, name, fmt.Errorf("unsupported color %q is black", value))
+		return color.RGBA{0, 0, 0, 255}
+	}
+	return c
+}
+
+// stroke parses the stroke color and width of a shape. The width is only parsed if there is a stroke, and
+// is 1 if it is not set or invalid. Strokes of shapes are not drawn, but they are included in StrokeBounds.
+func (p *parser) stroke(el *etree.Element) (color.Color, float64) {
+	stroke := p.paint(el, "stroke", "")
+	if stroke == nil {
+		return nil, 0
+	}
+	attr := el.SelectAttr("stroke-width")
+	if attr == nil {
+		return stroke, 1
+	}
+	width, err := parseStrokeWidth(attr.Value)
+	if err != nil {
+		p.report(SeverityError, el, "stroke-width", err)
+		return stroke, 1
+	}
+	return stroke, width
+}
+
+// parseCommon parses the attributes that all elements have, and the animation elements that are children
+// of the element. It returns false if the element is in error, and can not be rendered.
+func (p *parser) parseCommon(el *etree.Element, st style) (Common, bool) {
+	var m *Matrix
+	if attr := el.SelectAttr("transform"); attr != nil {
+		transform, err := ParseTransform(attr.Value)
+		if err != nil {
+			p.report(SeverityError, el, "transform", err)
+			return Common{}, false
+		}
+		m = &transform
+	}
+	animations := p.parseAnimations(el.ChildElements())
+	return Common{
+		ID:             el.SelectAttrValue("id", ""),
+		Transform:      m,
+		Animations:     animations,
+		ShapeRendering: st.shapeRendering,
+		TextRendering:  st.textRendering,
+		ImageRendering: st.imageRendering,
+		FillRule:       st.fillRule,
+		Visibility:     st.visibility,
+		Display:        displayValue(el.SelectAttrValue("display", "")),
+		PointerEvents:  st.pointerEvents,
+		specified:      specifiedProperties(el),
+	}, true
+}
+
+// specifiedProperties returns the inherited properties that are set on the element, with valid values
+func specifiedProperties(el *etree.Element) propertySet {
+	var set propertySet
+	for name, bit := range propertyBits {
+		if validProperty(name, el.SelectAttrValue(name, "")) {
+			set |= bit
+		}
+	}
+	return set
+}
+
+// displayValue returns "none" for display="none", and "" for the other values, which all render the element
+func displayValue(value string) string {
+	if strings.TrimSpace(value) == "none" {
+		return "none"
+	}
+	return ""
+}
+
+// parseElements parses the given elements. The fill color is nil for the elements that do not set it,
+// since it is inherited from the parent element when rendering. Elements that are in error are reported.
+// In lenient mode, they are skipped. In strict mode, parsing stops at the first one, and the elements
+// before it are returned with the error, since a document in error is rendered up to that element.
+// A path that is in error is rendered up to its last valid segment in both modes.
+func (p *parser) parseElements(elements []*etree.Element, parentStyle style) ([]SvgElement, error) {
 	var svgElements []SvgElement
 	for _, el := range elements {
-		fillColor := GetColor(el.SelectAttrValue("fill", ""))
-		if fillColor == nil {
-			fillColor = defaultColor
+		if p.err != nil || p.limit != nil {
+			break
+		}
+		if isAnimationTag(el.Tag) {
+			// Animation elements are only kept as elements of their own at the top level,
+			// where they must refer to the element they animate
+			if animation, ok := p.parseAnimation(el); ok {
+				svgElements = append(svgElements, p.locate(&animation, el))
+			}
+			continue
 		}
 
+		st := p.inherit(parentStyle, el)
+		fillColor := p.paint(el, "fill", "")
+		common, ok := p.parseCommon(el, st)
+		if !ok {
+			continue
+		}
+
+		var element SvgElement
+		partial := false // if the element is in error, but is still rendered
 		switch el.Tag {
 		case "circle":
-			x, _ := strconv.Atoi(el.SelectAttrValue("cx", "0"))
-			y, _ := strconv.Atoi(el.SelectAttrValue("cy", "0"))
-			r, _ := strconv.Atoi(el.SelectAttrValue("r", "0"))
-			svgElements = append(svgElements, SvgCircle{x, y, r, fillColor})
+			x, y, r := p.length(el, "cx", 0), p.length(el, "cy", 0), p.size(el, "r")
+			stroke, width := p.stroke(el)
+			element = &SvgCircle{Common: common, Cx: x, Cy: y, R: r, Fill: fillColor, Stroke: stroke, StrokeWidth: width}
 
 		case "rect":
-			x, _ := strconv.Atoi(el.SelectAttrValue("x", "0"))
-			y, _ := strconv.Atoi(el.SelectAttrValue("y", "0"))
-			w, _ := strconv.Atoi(el.SelectAttrValue("width", "0"))
-			h, _ := strconv.Atoi(el.SelectAttrValue("height", "0"))
-			svgElements = append(svgElements, SvgRectangle{x, y, w, h, fillColor})
+			x, y := p.length(el, "x", 0), p.length(el, "y", 0)
+			w, h := p.size(el, "width"), p.size(el, "height")
+			stroke, width := p.stroke(el)
+			element = &SvgRectangle{Common: common, X: x, Y: y, Width: w, Height: h, Fill: fillColor, Stroke: stroke, StrokeWidth: width}
 
 		case "line":
-			x1, _ := strconv.Atoi(el.SelectAttrValue("x1", "0"))
-			y1, _ := strconv.Atoi(el.SelectAttrValue("y1", "0"))
-			x2, _ := strconv.Atoi(el.SelectAttrValue("x2", "0"))
-			y2, _ := strconv.Atoi(el.SelectAttrValue("y2", "0"))
-			strokeColor := GetColor(el.SelectAttrValue("stroke", "black"))
-			svgElements = append(svgElements, SvgLine{x1, y1, x2, y2, strokeColor})
+			x1, y1 := p.length(el, "x1", 0), p.length(el, "y1", 0)
+			x2, y2 := p.length(el, "x2", 0), p.length(el, "y2", 0)
+			strokeColor := p.paint(el, "stroke", "black")
+			element = &SvgLine{Common: common, X1: x1, Y1: y1, X2: x2, Y2: y2, Stroke: strokeColor}
 
 		case "path":
-			d := el.SelectAttrValue("d", "")
-			path, err := ParsePath(d)
+			path, err := ParsePath(el.SelectAttrValue("d", ""))
 			if err != nil {
-				return nil, err
+				p.report(SeverityError, el, "d", err)
+				partial = len(path.Commands) > 0
+			}
+			if err := p.countSegments(path); err != nil {
+				p.limit = err
+				break
 			}
 			path.Fill = fillColor
-			svgElements = append(svgElements, path)
+			path.Stroke, path.StrokeWidth = p.stroke(el)
+			path.Common = common
+			element = &path
 
 		case "g":
-			childElements, err := parseElements(el.ChildElements(), fillColor)
-			if err != nil {
-				return nil, err
+			// The children before an element in error are kept, so the group is not in error itself
+			childElements, _ := p.parseElements(el.ChildElements(), st)
+			// The animation elements of the group are already in common.Animations
+			elements := childElements[:0]
+			for _, child := range childElements {
+				if _, ok := child.(*SvgAnimation); !ok {
+					elements = append(elements, child)
+				}
 			}
-			svgElements = append(svgElements, SvgGroup{childElements, fillColor})
+			element = &SvgGroup{Common: common, Elements: elements, Fill: fillColor}
+			partial = true
+
+		case "text":
+			text := p.parseText(el, st, common, fillColor)
+			element = &text
+
+		case "textArea":
+			area := p.parseTextArea(el, st, common, fillColor)
+			element = &area
+
+		case "defs":
+			defs, _ := p.parseElements(el.ChildElements(), st)
+			p.defs = append(p.defs, defs...)
+
+		case "image":
+			if img, ok := p.parseImage(el, common); ok {
+				element = &img
+			}
+
+		case "use":
+			p.report(SeverityWarning, el, "", errors.New("use elements are not rendered"))
+
+		case "title", "desc", "metadata":
+			// These are read by parseDocument, or describe the element they are in
+
+		default:
+			p.report(SeverityWarning, el, "", fmt.Errorf("%s elements are not rendered", el.FullTag()))
+		}
+
+		// In lenient mode, the default values are used for invalid attributes, so only the elements
+		// that could not be parsed at all are nil here
+		if element != nil && (p.err == nil || partial) {
+			svgElements = append(svgElements, p.locate(element, el))
 		}
 	}
+	return svgElements, p.err
+}
 
-	return svgElements, nil
+// locate records where the source of a parsed element is, and returns the element
+func (p *parser) locate(element SvgElement, el *etree.Element) SvgElement {
+	if p.locations == nil {
+		p.locations = make(map[SvgElement]location)
+	}
+	p.locations[element] = location{path: p.elementPath(el), position: p.positions[el]}
+	return element
 }
 
+// elementPath returns the same path as the package-level elementPath in diagnostic.go, which counts the
+// preceding siblings of every element on the way to the root. The first time a child of a parent is
+// looked up, the paths of all the children of that parent are found in one pass over them and kept in
+// p.paths, so that locating every element of a document takes linear time, also when a parent has many
+// children.
+func (p *parser) elementPath(el *etree.Element) string {
+	if path, ok := p.paths[el]; ok {
+		return path
+	}
+	parent := el.Parent()
+	if parent == nil || parent.Tag == "" && parent.Parent() == nil {
+		return elementPath(el)
+	}
+	if p.paths == nil {
+		p.paths = make(map[*etree.Element]string)
+	}
+	prefix := p.elementPath(parent)
+	counts := make(map[string]int)
+	for _, sibling := range parent.ChildElements() {
+		tag := sibling.FullTag()
+		counts[tag]++
+		p.paths[sibling] = prefix + "/" + tag + "[" + strconv.Itoa(counts[tag]) + "]"
+	}
+	return p.paths[el]
+}
+
+// GetColor parses a color, like "red", "#fff", "#ffffff" or "rgb(255, 255, 255)". It returns nil for ""
+// and black for colors that are not recognized.
 func GetColor(colorStr string) color.Color {
 	// If the string is empty, return nil
 	if colorStr == "" {
 		return nil
 	}
+	if c, ok := parseColor(colorStr); ok {
+		return c
+	}
+	// If the string is not recognized, return black
+	return color.RGBA{0, 0, 0, 255}
+}
+
+// parseColor parses a color that is not "", and returns false if it is not recognized
+func parseColor(colorStr string) (color.Color, bool) {
+
+	// Nothing is painted with "none"
+	if colorStr == "none" || colorStr == "transparent" {
+		return color.Transparent, true
+	}
 
 	// If the string is a color name, return the corresponding color
 	if c, ok := colornames.Map[colorStr]; ok {
-		return c
+		return c, true
 	}
 
 	// If the string is an RGB hex code on the form "#fff", convert it to RGBA color
 	if len(colorStr) == 4 && colorStr[0] == '#' {
-		r, _ := strconv.ParseInt(strings.Repeat(string(colorStr[1]), 2), 16, 64)
-		g, _ := strconv.ParseInt(strings.Repeat(string(colorStr[2]), 2), 16, 64)
-		b, _ := strconv.ParseInt(strings.Repeat(string(colorStr[3]), 2), 16, 64)
-		return color.RGBA{uint8(r), uint8(g), uint8(b), 255}
+		r, errR := strconv.ParseUint(strings.Repeat(string(colorStr[1]), 2), 16, 8)
+		g, errG := strconv.ParseUint(strings.Repeat(string(colorStr[2]), 2), 16, 8)
+		b, errB := strconv.ParseUint(strings.Repeat(string(colorStr[3]), 2), 16, 8)
+		return color.RGBA{uint8(r), uint8(g), uint8(b), 255}, errR == nil && errG == nil && errB == nil
 	}
 
 	// If the string is an RGB hex code on the form "#ffffff", convert it to RGBA color
 	if len(colorStr) == 7 && colorStr[0] == '#' {
-		r, _ := strconv.ParseInt(colorStr[1:3], 16, 64)
-		g, _ := strconv.ParseInt(colorStr[3:5], 16, 64)
-		b, _ := strconv.ParseInt(colorStr[5:7], 16, 64)
-		return color.RGBA{uint8(r), uint8(g), uint8(b), 255}
+		r, errR := strconv.ParseUint(colorStr[1:3], 16, 8)
+		g, errG := strconv.ParseUint(colorStr[3:5], 16, 8)
+		b, errB := strconv.ParseUint(colorStr[5:7], 16, 8)
+		return color.RGBA{uint8(r), uint8(g), uint8(b), 255}, errR == nil && errG == nil && errB == nil
 	}
 
 	// If the string is an RGB functional notation on the form "rgb(255, 255, 255)",
@@ -165,13 +549,23 @@ func GetColor(colorStr string) color.Color {
 		rgbStr = strings.TrimSuffix(rgbStr, ")")
 		rgbValues := strings.Split(rgbStr, ",")
 		if len(rgbValues) == 3 {
-			r, _ := strconv.Atoi(strings.TrimSpace(rgbValues[0]))
-			g, _ := strconv.Atoi(strings.TrimSpace(rgbValues[1]))
-			b, _ := strconv.Atoi(strings.TrimSpace(rgbValues[2]))
-			return color.RGBA{uint8(r), uint8(g), uint8(b), 255}
+			// Values outside of 0 to 255 are clamped
+			var rgb [3]uint8
+			for i, v := range rgbValues {
+				n, err := strconv.Atoi(strings.TrimSpace(v))
+				if err != nil {
+					return nil, false
+				}
+				if n < 0 {
+					n = 0
+				} else if n > 255 {
+					n = 255
+				}
+				rgb[i] = uint8(n)
+			}
+			return color.RGBA{rgb[0], rgb[1], rgb[2], 255}, true
 		}
 	}
 
-	// If the string is not recognized, return black
-	return color.RGBA{0, 0, 0, 255}
+	return nil, false
 }
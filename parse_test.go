@@ -14,11 +14,12 @@ func TestParseFile(t *testing.T) {
 	})
 
 	t.Run("test parsing SVG with single circle", func(t *testing.T) {
-		elements, err := ParseFile("testdata/circle.svg")
+		doc, err := ParseFile("testdata/circle.svg")
 		assert.NoError(t, err)
+		elements := doc.Elements
 		assert.Len(t, elements, 1)
 
-		circle, ok := elements[0].(SvgCircle)
+		circle, ok := elements[0].(*SvgCircle)
 		assert.True(t, ok)
 		assert.Equal(t, circle.Cx, 50)
 		assert.Equal(t, circle.Cy, 50)
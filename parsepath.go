@@ -2,78 +2,176 @@ package surrender
 
 import (
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
-	"log"
+	"math"
 	"strconv"
 	"strings"
-	"unicode"
 )
 
-// ParsePath can parse TinySVG 1.2 path attributes
+// pathArgs is the number of numeric arguments each TinySVG 1.2 path command takes
+var pathArgs = map[byte]int{
+	'M': 2, 'm': 2,
+	'L': 2, 'l': 2,
+	'H': 1, 'h': 1,
+	'V': 1, 'v': 1,
+	'C': 6, 'c': 6,
+	'S': 4, 's': 4,
+	'Q': 4, 'q': 4,
+	'T': 2, 't': 2,
+	'Z': 0, 'z': 0,
+}
+
+// PathError is an error in path data. The commands before it are valid, and are rendered.
+type PathError struct {
+	Offset int // the byte offset in the path data of the command or number that is in error
+	Err    error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("invalid path data at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// ParsePath can parse TinySVG 1.2 path attributes.
+// Each command holds all of its coordinate pairs as points, so "M0 0 10 0" is one M command
+// with two points where the second one is an implicit lineto. For H and V, which take a single
+// value, the value is stored in X for H and in Y for V. Coordinates are rounded to integers.
+// If the path data is in error, the path holds the commands up to the last valid segment, as they
+// are rendered, and a *PathError is returned.
 func ParsePath(d string) (SvgPath, error) {
 	var commands []PathCommand
-	var currentCmd PathCommand
-
-	// Function to process current command before starting a new one
-	processCurrentCmd := func() {
-		if currentCmd.Type != "" {
-			commands = append(commands, currentCmd)
-		}
-		currentCmd = PathCommand{}
+	var current *PathCommand
+	var pending []float64
+	start := 0 // the offset of the current command
+	path := func() SvgPath {
+		return SvgPath{Commands: commands, Fill: color.RGBA{R: 0, G: 0, B: 0, A: 255}}
 	}
 
-	var currentCoords []string
-
-	for _, c := range d {
-		if unicode.IsLetter(c) {
-			processCurrentCmd()
-			currentCmd.Type = string(c)
-			continue
+	// flush adds the current command, with the segments that have all their coordinates
+	flush := func() error {
+		if current == nil {
+			if len(pending) > 0 {
+				return &PathError{start, errors.New("path data must start with a command")}
+			}
+			return nil
 		}
-
-		switch c {
-		case ' ', ',':
-			if len(currentCoords) > 0 {
-				coords := strings.Join(currentCoords, "")
-				x, y, err := ParseCoordinates(coords)
-				if err != nil {
-					log.Printf("Parsing error: %v", err)
-					return SvgPath{}, err
-				}
-				currentCmd.Points = append(currentCmd.Points, image.Point{X: x, Y: y})
-				currentCoords = nil
+		n := pathArgs[current.Type[0]]
+		if n == 0 {
+			commands = append(commands, *current)
+			if len(pending) > 0 {
+				return &PathError{start, fmt.Errorf("unexpected coordinates after %s", current.Type)}
 			}
-		case '-':
-			// Handle "-" as a negative sign
-			if len(currentCoords) > 0 {
-				currentCoords = append(currentCoords, string(c))
-			} else {
-				currentCoords = []string{string(c)}
+			return nil
+		}
+		for i := 0; i+n <= len(pending); i += n {
+			switch current.Type {
+			case "H", "h":
+				current.Points = append(current.Points, image.Point{X: roundCoordinate(pending[i])})
+			case "V", "v":
+				current.Points = append(current.Points, image.Point{Y: roundCoordinate(pending[i])})
+			default:
+				for j := i; j < i+n; j += 2 {
+					current.Points = append(current.Points, image.Point{X: roundCoordinate(pending[j]), Y: roundCoordinate(pending[j+1])})
+				}
 			}
-		default:
-			currentCoords = append(currentCoords, string(c))
 		}
+		if len(current.Points) > 0 {
+			commands = append(commands, *current)
+		}
+		if len(pending) == 0 || len(pending)%n != 0 {
+			return &PathError{start, fmt.Errorf("wrong number of coordinates for %s: %d", current.Type, len(pending))}
+		}
+		return nil
 	}
 
-	if len(currentCoords) > 0 {
-		coords := strings.Join(currentCoords, "")
-		x, y, err := ParseCoordinates(coords)
-		if err != nil {
-			log.Printf("Parsing error: %v", err)
-			return SvgPath{}, err
+	for i := 0; i < len(d); {
+		c := d[i]
+		switch {
+		case c == ' ' || c == ',' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isPathCommand(c):
+			if err := flush(); err != nil {
+				return path(), err
+			}
+			current = &PathCommand{Type: string(c)}
+			pending = nil
+			start = i
+			i++
+		default:
+			value, n, err := scanNumber(d[i:])
+			if err != nil {
+				// The segments before the invalid number are still rendered
+				flush()
+				return path(), &PathError{i, err}
+			}
+			pending = append(pending, value)
+			i += n
 		}
-		currentCmd.Points = append(currentCmd.Points, image.Point{X: x, Y: y})
 	}
+	if err := flush(); err != nil {
+		return path(), err
+	}
+
+	return path(), nil
+}
 
-	// Make sure to process the last command
-	processCurrentCmd()
+// isPathCommand checks if the given byte is a TinySVG 1.2 path command letter
+func isPathCommand(c byte) bool {
+	_, ok := pathArgs[c]
+	return ok
+}
 
-	if cmd := currentCmd; cmd.Type != "" {
-		commands = append(commands, cmd)
+// scanNumber reads a number from the start of s and returns the value and the number of bytes consumed
+func scanNumber(s string) (float64, int, error) {
+	i := 0
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		i++
+	}
+	digits := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		if i < len(s) {
+			return 0, 0, fmt.Errorf("invalid character in number: %q", s[i])
+		}
+		return 0, 0, errors.New("empty coordinate")
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '-' || s[j] == '+') {
+			j++
+		}
+		if j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			i = j
+		}
 	}
+	value, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return value, i, nil
+}
 
-	return SvgPath{Commands: commands, Fill: color.RGBA{R: 0, G: 0, B: 0, A: 255}}, nil
+// roundCoordinate rounds a coordinate to the nearest integer
+func roundCoordinate(value float64) int {
+	return int(math.Round(value))
 }
 
 // ParseCoordinates tries to parse a TinySVG 1.2 path attribute coordinate
@@ -99,48 +197,39 @@ func ParseCoordinates(coords string) (int, int, error) {
 	return x, y, nil
 }
 
-// splitCoordinates splits the coordinate string based on separators (" " and "-")
+// splitCoordinates splits the coordinate string into numbers, separated by
+// whitespace, commas or the sign of the next number
 func splitCoordinates(coords string) []string {
 	var parts []string
-	var current string
-
-	for _, c := range coords {
-		if unicode.IsSpace(c) || c == '-' {
-			if current != "" {
-				parts = append(parts, current)
-				current = ""
-			}
+	for i := 0; i < len(coords); {
+		c := coords[i]
+		if c == ' ' || c == ',' || c == '\t' || c == '\n' || c == '\r' {
+			i++
+			continue
 		}
-		current += string(c)
-	}
-
-	if current != "" {
-		parts = append(parts, current)
+		_, n, err := scanNumber(coords[i:])
+		if err != nil {
+			// Let parseCoordinate report the problem
+			return append(parts, coords[i:])
+		}
+		parts = append(parts, coords[i:i+n])
+		i += n
 	}
-
 	return parts
 }
 
 // parseCoordinate parses a single coordinate value
 func parseCoordinate(coord string) (int, error) {
+	coord = strings.TrimSpace(coord)
 	if coord == "" {
 		return 0, errors.New("empty coordinate")
 	}
-
-	negative := false
-	if coord[0] == '-' {
-		negative = true
-		coord = coord[1:]
-	}
-
-	value, err := strconv.Atoi(coord)
+	value, n, err := scanNumber(coord)
 	if err != nil {
 		return 0, err
 	}
-
-	if negative {
-		value = -value
+	if n != len(coord) {
+		return 0, fmt.Errorf("invalid coordinate: %q", coord)
 	}
-
-	return value, nil
+	return roundCoordinate(value), nil
 }
@@ -1,6 +1,7 @@
 package surrender
 
 import (
+	"errors"
 	"image"
 	"testing"
 )
@@ -32,6 +33,37 @@ func TestParsePath(t *testing.T) {
 				{{-100, -200}},
 			},
 		},
+		{
+			name:        "Numbers_Without_Separators",
+			pathData:    "M0-1.5.5.5",
+			expectedCmd: []string{"M"},
+			expectedPts: [][]image.Point{
+				{{0, -2}, {1, 1}},
+			},
+		},
+		{
+			name:        "Exponents",
+			pathData:    "M1e1,2E+1L-1.5e1-2e-0",
+			expectedCmd: []string{"M", "L"},
+			expectedPts: [][]image.Point{
+				{{10, 20}},
+				{{-15, -2}},
+			},
+		},
+		{
+			name:        "Curves",
+			pathData:    "M0 0C1 2 3 4 5 6S7 8 9 10Q1 1 2 2T3 3h4v5",
+			expectedCmd: []string{"M", "C", "S", "Q", "T", "h", "v"},
+			expectedPts: [][]image.Point{
+				{{0, 0}},
+				{{1, 2}, {3, 4}, {5, 6}},
+				{{7, 8}, {9, 10}},
+				{{1, 1}, {2, 2}},
+				{{3, 3}},
+				{{4, 0}},
+				{{0, 5}},
+			},
+		},
 		// Add more test cases here as needed
 	}
 
@@ -74,3 +106,23 @@ func TestParsePath(t *testing.T) {
 		})
 	}
 }
+
+func TestParsePathInError(t *testing.T) {
+	for d, expected := range map[string]string{
+		"M0 0 L10 10 20":  "M0 0 10 10",
+		"M0 0 L10 10 x":   "M0 0 10 10",
+		"M0 0 A1 1 0 0 1": "M0 0",
+		"10 10":           "",
+		"M0 0 Z 5":        "M0 0Z",
+	} {
+		path, err := ParsePath(d)
+		var pathErr *PathError
+		if !errors.As(err, &pathErr) {
+			t.Errorf("Expected a *PathError for %q, but got %v", d, err)
+			continue
+		}
+		if s := path.String(); s != expected {
+			t.Errorf("Expected %q to be rendered as %q, but got %q", d, expected, s)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package surrender
+
+import (
+	"image"
+	"strconv"
+	"strings"
+)
+
+// PathFormat selects how FormatPathData writes path data
+type PathFormat int
+
+const (
+	// PathAbsolute writes every segment with an uppercase, absolute command
+	PathAbsolute PathFormat = iota
+	// PathRelative writes every segment with a lowercase, relative command
+	PathRelative
+	// PathMinimal picks the shorter of the absolute and relative form for each segment, leaves out repeated
+	// command letters and the separators before negative numbers, and writes horizontal and vertical lines as H and V
+	PathMinimal
+)
+
+// pathSegment is a single segment of a path, with absolute coordinates
+type pathSegment struct {
+	command byte          // the uppercase command letter, where the points after the first one of an M command are L
+	points  []image.Point // the absolute points, where H and V have the whole end point
+	from    image.Point   // the current point before the segment
+}
+
+// absoluteSegments splits path commands into segments with absolute coordinates
+func absoluteSegments(commands []PathCommand) []pathSegment {
+	var segments []pathSegment
+	var current, start image.Point
+	for _, command := range commands {
+		if command.Type == "" {
+			continue
+		}
+		letter := command.Type[0]
+		relative := letter >= 'a'
+		upper := strings.ToUpper(command.Type)[0]
+		if upper == 'Z' {
+			segments = append(segments, pathSegment{command: 'Z', from: current})
+			current = start
+			continue
+		}
+		n := pathArgs[letter] / 2
+		if n == 0 {
+			// H and V take a single value
+			n = 1
+		}
+		for i := 0; i+n <= len(command.Points); i += n {
+			seg := pathSegment{command: upper, from: current}
+			if upper == 'M' && i > 0 {
+				seg.command = 'L'
+			}
+			for _, p := range command.Points[i : i+n] {
+				switch {
+				case upper == 'H':
+					p.Y = current.Y
+					if relative {
+						p.X += current.X
+					}
+				case upper == 'V':
+					p.X = current.X
+					if relative {
+						p.Y += current.Y
+					}
+				case relative:
+					p = p.Add(current)
+				}
+				seg.points = append(seg.points, p)
+			}
+			current = seg.points[len(seg.points)-1]
+			if seg.command == 'M' {
+				start = current
+			}
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+// numbers returns the command letter and the coordinates of the segment, in absolute or relative form
+func (s pathSegment) numbers(relative bool) (byte, []int) {
+	letter := s.command
+	if relative {
+		letter += 'a' - 'A'
+	}
+	var numbers []int
+	for _, p := range s.points {
+		if relative {
+			p = p.Sub(s.from)
+		}
+		switch s.command {
+		case 'H':
+			numbers = append(numbers, p.X)
+		case 'V':
+			numbers = append(numbers, p.Y)
+		default:
+			numbers = append(numbers, p.X, p.Y)
+		}
+	}
+	return letter, numbers
+}
+
+// pathWriter writes path segments, keeping track of which command letter can be left out
+type pathWriter struct {
+	sb       strings.Builder
+	minimal  bool
+	implicit byte // the command that applies if the next segment has no letter, or 0
+}
+
+// format returns the text of a segment, without changing the state of the writer
+func (w *pathWriter) format(letter byte, numbers []int) string {
+	var sb strings.Builder
+	omitted := w.minimal && letter == w.implicit
+	if !omitted {
+		sb.WriteByte(letter)
+	}
+	for i, n := range numbers {
+		s := strconv.Itoa(n)
+		if (i > 0 || omitted) && !(w.minimal && s[0] == '-') {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(s)
+	}
+	return sb.String()
+}
+
+// write adds a segment with the given command letter
+func (w *pathWriter) write(letter byte, numbers []int) {
+	w.sb.WriteString(w.format(letter, numbers))
+	switch letter {
+	case 'M':
+		w.implicit = 'L'
+	case 'm':
+		w.implicit = 'l'
+	case 'Z', 'z':
+		w.implicit = 0
+	default:
+		w.implicit = letter
+	}
+}
+
+// FormatPathData formats path commands as path data, in the given format.
+// The result describes the same shape as the commands, and can be parsed with ParsePath.
+func FormatPathData(commands []PathCommand, format PathFormat) string {
+	w := &pathWriter{minimal: format == PathMinimal}
+	for _, seg := range absoluteSegments(commands) {
+		if seg.command == 'Z' {
+			if format == PathRelative {
+				w.write('z', nil)
+			} else {
+				w.write('Z', nil)
+			}
+			continue
+		}
+		if format != PathMinimal {
+			w.write(seg.numbers(format == PathRelative))
+			continue
+		}
+		if seg.command == 'L' {
+			switch end := seg.points[0]; {
+			case end.Y == seg.from.Y:
+				seg.command = 'H'
+			case end.X == seg.from.X:
+				seg.command = 'V'
+			}
+		}
+		letter, numbers := seg.numbers(false)
+		relLetter, relNumbers := seg.numbers(true)
+		if len(w.format(relLetter, relNumbers)) < len(w.format(letter, numbers)) {
+			letter, numbers = relLetter, relNumbers
+		}
+		w.write(letter, numbers)
+	}
+	return w.sb.String()
+}
+
+// String returns the path data of the path, in the shortest form that FormatPathData can write
+func (p SvgPath) String() string {
+	return FormatPathData(p.Commands, PathMinimal)
+}
@@ -0,0 +1,59 @@
+package surrender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatPathData(t *testing.T) {
+	tests := []struct {
+		d                           string
+		absolute, relative, minimal string
+	}{
+		{"M10 10 L20 10 L20 20 L10 20 Z", "M10 10L20 10L20 20L10 20Z", "m10 10l10 0l0 10l-10 0z", "M10 10H20V20H10Z"},
+		{"M100 100 101 102 103 105", "M100 100L101 102L103 105", "m100 100l1 2l2 3", "M100 100l1 2 2 3"},
+		{"M500 500 L490 495 l-10 -5", "M500 500L490 495L480 490", "m500 500l-10 -5l-10 -5", "M500 500l-10-5-10-5"},
+		{"M0 0 h10 v10 H0 z m5 5 l1 1", "M0 0H10V10H0ZM5 5L6 6", "m0 0h10v10h-10zm5 5l1 1", "M0 0H10V10H0ZM5 5 6 6"},
+		{"M0 0 C0 10 10 10 10 0 S20 -10 20 0", "M0 0C0 10 10 10 10 0S20 -10 20 0", "m0 0c0 10 10 10 10 0s10 -10 10 0", "M0 0C0 10 10 10 10 0S20-10 20 0"},
+	}
+	for _, tc := range tests {
+		path, err := ParsePath(tc.d)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.absolute, FormatPathData(path.Commands, PathAbsolute), tc.d)
+		assert.Equal(t, tc.relative, FormatPathData(path.Commands, PathRelative), tc.d)
+		assert.Equal(t, tc.minimal, FormatPathData(path.Commands, PathMinimal), tc.d)
+		assert.Equal(t, tc.minimal, path.String())
+	}
+}
+
+func TestFormatPathDataShape(t *testing.T) {
+	// All the formats describe the same shape as the original path data
+	doc, err := ParseFile("testdata/rainforest_8c_opt.svg")
+	assert.NoError(t, err)
+	var paths []*SvgPath
+	var collect func(elements []SvgElement)
+	collect = func(elements []SvgElement) {
+		for _, el := range elements {
+			switch e := el.(type) {
+			case *SvgPath:
+				paths = append(paths, e)
+			case *SvgGroup:
+				collect(e.Elements)
+			}
+		}
+	}
+	collect(doc.Elements)
+	assert.NotEmpty(t, paths)
+	for _, path := range paths {
+		for _, format := range []PathFormat{PathAbsolute, PathRelative, PathMinimal} {
+			d := FormatPathData(path.Commands, format)
+			formatted, err := ParsePath(d)
+			assert.NoError(t, err)
+			assert.Equal(t, path.segments(), formatted.segments(), d)
+			if format == PathMinimal {
+				assert.LessOrEqual(t, len(d), len(pathData(path.Commands)))
+			}
+		}
+	}
+}
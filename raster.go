@@ -0,0 +1,399 @@
+package surrender
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+// flattenTolerance is the default maximum distance, in pixels, between a curve and the line segments that approximate it
+const flattenTolerance = 0.25
+
+// fpoint is a point with floating point coordinates, used when flattening and filling outlines
+type fpoint struct {
+	X, Y float64
+}
+
+// segment is a line, or a quadratic or cubic Bézier curve, in a path
+type segment struct {
+	degree int       // 1 for lines, 2 for quadratic and 3 for cubic curves
+	points [4]fpoint // the start point, the control points and the end point
+}
+
+// end returns the end point of the segment
+func (s segment) end() fpoint {
+	return s.points[s.degree]
+}
+
+// segments converts the path commands to a list of subpaths, with absolute coordinates
+func (p SvgPath) segments() [][]segment {
+	var (
+		subpaths [][]segment
+		current  []segment
+		pos      fpoint // current point
+		start    fpoint // start of the current subpath
+		ctrl     fpoint // last control point, for S and T
+		lastType byte
+	)
+
+	pt := func(ip image.Point) fpoint {
+		return fpoint{float64(ip.X), float64(ip.Y)}
+	}
+	add := func(s segment) {
+		current = append(current, s)
+		pos = s.end()
+	}
+	lineTo := func(q fpoint) {
+		add(segment{degree: 1, points: [4]fpoint{pos, q}})
+	}
+	endSubpath := func() {
+		if len(current) > 0 {
+			subpaths = append(subpaths, current)
+		}
+		current = nil
+	}
+
+	for _, command := range p.Commands {
+		if command.Type == "" {
+			continue
+		}
+		t := command.Type[0]
+		relative := t >= 'a' && t <= 'z'
+		abs := func(q fpoint) fpoint {
+			if relative {
+				return fpoint{pos.X + q.X, pos.Y + q.Y}
+			}
+			return q
+		}
+		upper := t &^ 0x20
+		switch upper {
+		case 'M':
+			for i, ip := range command.Points {
+				q := abs(pt(ip))
+				if i == 0 {
+					endSubpath()
+					pos, start = q, q
+					continue
+				}
+				lineTo(q)
+			}
+		case 'L':
+			for _, ip := range command.Points {
+				lineTo(abs(pt(ip)))
+			}
+		case 'H':
+			for _, ip := range command.Points {
+				x := float64(ip.X)
+				if relative {
+					x += pos.X
+				}
+				lineTo(fpoint{x, pos.Y})
+			}
+		case 'V':
+			for _, ip := range command.Points {
+				y := float64(ip.Y)
+				if relative {
+					y += pos.Y
+				}
+				lineTo(fpoint{pos.X, y})
+			}
+		case 'C', 'S':
+			n := 3
+			if upper == 'S' {
+				n = 2
+			}
+			for i := 0; i+n <= len(command.Points); i += n {
+				var c1 fpoint
+				pts := command.Points[i : i+n]
+				if upper == 'C' {
+					c1 = abs(pt(pts[0]))
+					pts = pts[1:]
+				} else if lastType == 'C' || lastType == 'S' {
+					c1 = fpoint{2*pos.X - ctrl.X, 2*pos.Y - ctrl.Y}
+				} else {
+					c1 = pos
+				}
+				c2, end := abs(pt(pts[0])), abs(pt(pts[1]))
+				add(segment{degree: 3, points: [4]fpoint{pos, c1, c2, end}})
+				ctrl = c2
+				lastType = upper
+			}
+			continue
+		case 'Q', 'T':
+			n := 2
+			if upper == 'T' {
+				n = 1
+			}
+			for i := 0; i+n <= len(command.Points); i += n {
+				var c fpoint
+				pts := command.Points[i : i+n]
+				if upper == 'Q' {
+					c = abs(pt(pts[0]))
+					pts = pts[1:]
+				} else if lastType == 'Q' || lastType == 'T' {
+					c = fpoint{2*pos.X - ctrl.X, 2*pos.Y - ctrl.Y}
+				} else {
+					c = pos
+				}
+				end := abs(pt(pts[0]))
+				add(segment{degree: 2, points: [4]fpoint{pos, c, end}})
+				ctrl = c
+				lastType = upper
+			}
+			continue
+		case 'Z':
+			if current != nil {
+				lineTo(start)
+			}
+			endSubpath()
+			pos = start
+		}
+		lastType = upper
+	}
+	endSubpath()
+	return subpaths
+}
+
+// outline converts the path commands to a list of flattened subpaths, where curves are approximated by
+// line segments that are at most tolerance away from the curve
+func (p SvgPath) outline(tolerance float64) [][]fpoint {
+	var subpaths [][]fpoint
+	for _, segments := range p.segments() {
+		points := []fpoint{segments[0].points[0]}
+		for _, s := range segments {
+			switch s.degree {
+			case 1:
+				points = append(points, s.end())
+			case 2:
+				points = append(points, flattenQuad(s.points[0], s.points[1], s.points[2], tolerance)...)
+			case 3:
+				points = append(points, flattenCubic(s.points[0], s.points[1], s.points[2], s.points[3], tolerance)...)
+			}
+		}
+		subpaths = append(subpaths, points)
+	}
+	return subpaths
+}
+
+// flattenCubic approximates a cubic Bézier curve with line segments, returning the points after p0
+func flattenCubic(p0, p1, p2, p3 fpoint, tolerance float64) []fpoint {
+	ddx := math.Max(math.Abs(p0.X-2*p1.X+p2.X), math.Abs(p1.X-2*p2.X+p3.X))
+	ddy := math.Max(math.Abs(p0.Y-2*p1.Y+p2.Y), math.Abs(p1.Y-2*p2.Y+p3.Y))
+	n := segmentCount(math.Hypot(ddx, ddy)*0.75, tolerance)
+	points := make([]fpoint, 0, n)
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		mt := 1 - t
+		a, b, c, d := mt*mt*mt, 3*mt*mt*t, 3*mt*t*t, t*t*t
+		points = append(points, fpoint{
+			a*p0.X + b*p1.X + c*p2.X + d*p3.X,
+			a*p0.Y + b*p1.Y + c*p2.Y + d*p3.Y,
+		})
+	}
+	return points
+}
+
+// flattenQuad approximates a quadratic Bézier curve with line segments, returning the points after p0
+func flattenQuad(p0, p1, p2 fpoint, tolerance float64) []fpoint {
+	n := segmentCount(math.Hypot(p0.X-2*p1.X+p2.X, p0.Y-2*p1.Y+p2.Y)*0.25, tolerance)
+	points := make([]fpoint, 0, n)
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		mt := 1 - t
+		a, b, c := mt*mt, 2*mt*t, t*t
+		points = append(points, fpoint{
+			a*p0.X + b*p1.X + c*p2.X,
+			a*p0.Y + b*p1.Y + c*p2.Y,
+		})
+	}
+	return points
+}
+
+// segmentCount returns how many line segments are needed for a curve, given its scaled second difference
+func segmentCount(dd, tolerance float64) int {
+	n := int(math.Ceil(math.Sqrt(dd / tolerance)))
+	if n < 1 {
+		return 1
+	}
+	if n > 1000 {
+		return 1000
+	}
+	return n
+}
+
+// antialiasSamples is the number of sub-scanlines per pixel row, when antialiasing.
+// Within each sub-scanline, the horizontal coverage of the pixels is exact.
+const antialiasSamples = 16
+
+// edge is a non-horizontal polygon edge, stored with y0 < y1
+type edge struct {
+	x0, y0, x1, y1 float64
+	winding        int
+}
+
+// crossing is where an edge crosses a scanline
+type crossing struct {
+	x       float64
+	winding int
+}
+
+// scanner finds the edge crossings of scanlines, which must be visited from the top down
+type scanner struct {
+	edges     []edge // sorted by y0
+	active    []edge
+	crossings []crossing
+	next      int
+}
+
+// crossingsAt returns the edge crossings of the scanline at y, sorted by x
+func (sc *scanner) crossingsAt(y float64) []crossing {
+	// Add the edges that start above this scanline and drop the ones that end above it
+	for sc.next < len(sc.edges) && sc.edges[sc.next].y0 <= y {
+		sc.active = append(sc.active, sc.edges[sc.next])
+		sc.next++
+	}
+	sc.crossings = sc.crossings[:0]
+	kept := sc.active[:0]
+	for _, e := range sc.active {
+		if e.y1 <= y {
+			continue
+		}
+		kept = append(kept, e)
+		if e.y0 <= y {
+			x := e.x0 + (y-e.y0)*(e.x1-e.x0)/(e.y1-e.y0)
+			sc.crossings = append(sc.crossings, crossing{x, e.winding})
+		}
+	}
+	sc.active = kept
+	sort.Slice(sc.crossings, func(i, j int) bool { return sc.crossings[i].x < sc.crossings[j].x })
+	return sc.crossings
+}
+
+// inside checks if a winding number is inside a shape, according to the fill-rule property,
+// which is "evenodd" or "nonzero"
+func inside(winding int, fillRule string) bool {
+	if fillRule == "evenodd" {
+		return winding%2 != 0
+	}
+	return winding != 0
+}
+
+// fillPolygons fills the given closed polygons, with the fill-rule "evenodd" or "nonzero".
+// Without antialiasing, a pixel is filled if its center is inside, which keeps pixel-aligned shapes exact.
+// With antialiasing, the color is drawn with the part of each pixel that is covered as its opacity.
+// Filling stops if ctx, which may be nil, is cancelled.
+func fillPolygons(ctx context.Context, img draw.Image, polygons [][]fpoint, clr color.Color, fillRule string, antialias bool) {
+	var edges []edge
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, poly := range polygons {
+		for i := range poly {
+			a, b := poly[i], poly[(i+1)%len(poly)]
+			if a.Y == b.Y {
+				continue
+			}
+			e := edge{a.X, a.Y, b.X, b.Y, 1}
+			if a.Y > b.Y {
+				e = edge{b.X, b.Y, a.X, a.Y, -1}
+			}
+			edges = append(edges, e)
+			minY = math.Min(minY, e.y0)
+			maxY = math.Max(maxY, e.y1)
+		}
+	}
+	if len(edges) == 0 {
+		return
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].y0 < edges[j].y0 })
+
+	bounds := img.Bounds()
+	y0 := int(math.Floor(minY))
+	if y0 < bounds.Min.Y {
+		y0 = bounds.Min.Y
+	}
+	y1 := int(math.Ceil(maxY))
+	if y1 > bounds.Max.Y {
+		y1 = bounds.Max.Y
+	}
+
+	p := newPainter(img, clr)
+	sc := &scanner{edges: edges}
+	if antialias {
+		fillCoverage(ctx, p, sc, fillRule, bounds, y0, y1)
+		return
+	}
+	for y := y0; y < y1; y++ {
+		if (y-y0)%cancelRows == 0 && cancelled(ctx) {
+			return
+		}
+		crossings := sc.crossingsAt(float64(y) + 0.5)
+		winding := 0
+		for i := 0; i+1 < len(crossings); i++ {
+			winding += crossings[i].winding
+			if !inside(winding, fillRule) {
+				continue
+			}
+			// Fill the pixels whose centers lie between the two crossings
+			xa := int(math.Ceil(crossings[i].x - 0.5))
+			xb := int(math.Ceil(crossings[i+1].x - 0.5))
+			p.over(xa, xb, y)
+		}
+	}
+}
+
+// fillCoverage fills the rows from y0 to y1 with antialiasing, by sampling each row at
+// several sub-scanlines and adding up how much of each pixel the spans between the crossings cover
+func fillCoverage(ctx context.Context, p painter, sc *scanner, fillRule string, bounds image.Rectangle, y0, y1 int) {
+	const weight = 1.0 / antialiasSamples
+	minX, maxX := float64(bounds.Min.X), float64(bounds.Max.X)
+	// partial is the coverage of the pixels at the ends of spans, and full is a running sum
+	// of whole pixels, where a span adds weight at its first whole pixel and removes it after the last
+	partial := make([]float64, bounds.Dx()+1)
+	full := make([]float64, bounds.Dx()+1)
+	for y := y0; y < y1; y++ {
+		if (y-y0)%cancelRows == 0 && cancelled(ctx) {
+			return
+		}
+		lo, hi := len(partial), 0 // the range of pixels that the spans touch
+		for s := 0; s < antialiasSamples; s++ {
+			crossings := sc.crossingsAt(float64(y) + (float64(s)+0.5)*weight)
+			winding := 0
+			for i := 0; i+1 < len(crossings); i++ {
+				winding += crossings[i].winding
+				if !inside(winding, fillRule) {
+					continue
+				}
+				a := math.Max(crossings[i].x, minX) - minX
+				b := math.Min(crossings[i+1].x, maxX) - minX
+				if a >= b {
+					continue
+				}
+				ia, ib := int(a), int(b)
+				if ia < lo {
+					lo = ia
+				}
+				if ib+1 > hi {
+					hi = ib + 1
+				}
+				if ia == ib {
+					partial[ia] += (b - a) * weight
+					continue
+				}
+				partial[ia] += (float64(ia+1) - a) * weight
+				full[ia+1] += weight
+				full[ib] -= weight
+				partial[ib] += (b - float64(ib)) * weight
+			}
+		}
+		run := 0.0
+		for i := lo; i < hi; i++ {
+			run += full[i]
+			if coverage := run + partial[i]; coverage > 0.5/255 && i < bounds.Dx() {
+				p.blend(bounds.Min.X+i, y, coverage)
+			}
+			partial[i], full[i] = 0, 0
+		}
+	}
+}
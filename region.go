@@ -0,0 +1,71 @@
+package surrender
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// RenderRegion renders the part of the document that is within the rectangle, in the user coordinates of
+// the document, to a new image. The size of the image follows from the size of the rectangle in the viewport
+// of the document, and the options. Elements outside of the rectangle are cut off.
+func RenderRegion(doc *Document, region Rect, opts RenderOptions) (*image.RGBA, error) {
+	if region.Empty() {
+		return nil, fmt.Errorf("empty region: %v", region)
+	}
+	view := doc.viewTransform()
+	vb := ViewBox{region.X0, region.Y0, region.Width(), region.Height()}
+	return renderView(doc.Elements, defaultFill, Identity, vb, region.Width()*math.Abs(view.A), region.Height()*math.Abs(view.D), opts)
+}
+
+// RenderElement renders the element with the given id to a new image that is cropped to the bounding box
+// of the element. The element is drawn with the transformations and the fill color of its parent elements,
+// but without the elements around it. Elements in defs elements can also be rendered.
+func RenderElement(doc *Document, id string, opts RenderOptions) (*image.RGBA, error) {
+	el := doc.ElementByID(id)
+	if el == nil {
+		return nil, fmt.Errorf("no element with id %q", id)
+	}
+	ancestors := doc.ancestors(el)
+
+	// Find the transformation and the fill color that the element inherits
+	s := opts.state()
+	var inherited color.Color = defaultFill
+	for _, g := range ancestors {
+		s = s.with(g.Transform)
+		if g.Fill != nil {
+			inherited = g.Fill
+		}
+	}
+	b := transformedBounds(el, s.ctm, true)
+	if !b.ok || b.rect.Empty() {
+		return nil, fmt.Errorf("the element with id %q has no area", id)
+	}
+	view := doc.viewTransform()
+	vb := ViewBox{b.rect.X0, b.rect.Y0, b.rect.Width(), b.rect.Height()}
+	return renderView([]SvgElement{el}, inherited, s.ctm, vb, vb.Width*math.Abs(view.A), vb.Height*math.Abs(view.D), opts)
+}
+
+// ancestors returns the groups that the element is in, from the outermost one, in the elements or the defs
+func (d *Document) ancestors(el SvgElement) []*SvgGroup {
+	var find func(elements []SvgElement, path []*SvgGroup) ([]*SvgGroup, bool)
+	find = func(elements []SvgElement, path []*SvgGroup) ([]*SvgGroup, bool) {
+		for _, child := range elements {
+			if child == el {
+				return path, true
+			}
+			if g, ok := child.(*SvgGroup); ok {
+				if found, ok := find(g.Elements, append(path, g)); ok {
+					return found, true
+				}
+			}
+		}
+		return nil, false
+	}
+	if path, ok := find(d.Elements, nil); ok {
+		return path
+	}
+	path, _ := find(d.Defs, nil)
+	return path
+}
@@ -0,0 +1,71 @@
+package surrender
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	gray   = color.RGBA{128, 128, 128, 255}
+	blue   = color.RGBA{0, 0, 255, 255}
+	red    = color.RGBA{255, 0, 0, 255}
+	yellow = color.RGBA{255, 255, 0, 255}
+)
+
+func TestRenderRegion(t *testing.T) {
+	doc, err := ParseFile("testdata/sprites.svg")
+	assert.NoError(t, err)
+
+	// The region is scaled up by the view box, like the rest of the document
+	img, err := RenderRegion(doc, Rect{8, 0, 20, 12}, RenderOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 24, 24), img.Bounds())
+	assert.Equal(t, gray, img.RGBAAt(1, 1))
+	assert.Equal(t, blue, img.RGBAAt(5, 5))
+	assert.Equal(t, gray, img.RGBAAt(5, 22))
+
+	// Zooming in with the options
+	img, err = RenderRegion(doc, Rect{10, 2, 18, 10}, RenderOptions{Width: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 100, 100), img.Bounds())
+	assert.Equal(t, blue, img.RGBAAt(0, 0))
+	assert.Equal(t, blue, img.RGBAAt(99, 99))
+
+	_, err = RenderRegion(doc, Rect{5, 5, 5, 10}, RenderOptions{})
+	assert.Error(t, err)
+}
+
+func TestRenderElement(t *testing.T) {
+	doc, err := ParseFile("testdata/sprites.svg")
+	assert.NoError(t, err)
+
+	// The square inherits the fill color and the transform of its group, without the background
+	img, err := RenderElement(doc, "square", RenderOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 16, 16), img.Bounds())
+	assert.Equal(t, blue, img.RGBAAt(0, 0))
+	assert.Equal(t, blue, img.RGBAAt(15, 15))
+
+	img, err = RenderElement(doc, "wide", RenderOptions{Background: color.White})
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 24, 8), img.Bounds())
+	assert.Equal(t, red, img.RGBAAt(12, 4))
+
+	// A whole group is cropped to its children, and elements in defs can be rendered too
+	img, err = RenderElement(doc, "sprites", RenderOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 44, 16), img.Bounds())
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(18, 2))
+
+	img, err = RenderElement(doc, "dot", RenderOptions{Scale: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 24, 24), img.Bounds())
+	assert.Equal(t, yellow, img.RGBAAt(12, 12))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(1, 1))
+
+	_, err = RenderElement(doc, "missing", RenderOptions{})
+	assert.Error(t, err)
+}
@@ -1,64 +1,247 @@
 package surrender
 
 import (
+	"context"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/png"
+	"io"
+	"math"
 	"os"
 )
 
+// transformable is implemented by the elements that can be drawn with the
+// transformations and settings of their parent elements applied
+type transformable interface {
+	drawWith(img draw.Image, clr color.Color, s drawState)
+}
+
+// drawState holds the settings that are passed down from parent elements when drawing
+type drawState struct {
+	ctm       Matrix  // the transformation from user coordinates to pixels
+	tolerance float64 // the largest distance between a curve and its line segments, in pixels
+	antialias bool    // if the edges of filled shapes are antialiased
+
+	ctx context.Context // nil if rendering can not be cancelled
+}
+
+// defaultState is used when an element is drawn without any parent elements
+var defaultState = drawState{ctm: Identity, tolerance: flattenTolerance}
+
+// with returns the state for an element with the given transform attribute, which may be nil
+func (s drawState) with(own *Matrix) drawState {
+	s.ctm = transform(s.ctm, own)
+	return s
+}
+
+// userTolerance returns the flattening tolerance in user coordinates,
+// so that curves are just as smooth when they are scaled up
+func (s drawState) userTolerance() float64 {
+	scale := math.Sqrt(math.Abs(s.ctm.A*s.ctm.D - s.ctm.B*s.ctm.C))
+	if scale == 0 || math.IsNaN(scale) || math.IsInf(scale, 0) {
+		return s.tolerance
+	}
+	return s.tolerance / scale
+}
+
+// antialiasShape returns if the edges of a shape with the given shape-rendering property are antialiased,
+// where "auto" and "" follow the render options
+func (s drawState) antialiasShape(shapeRendering string) bool {
+	switch shapeRendering {
+	case "crispEdges", "optimizeSpeed":
+		return false
+	case "geometricPrecision":
+		return true
+	}
+	return s.antialias
+}
+
+// antialiasText returns if text with the given text-rendering property is antialiased,
+// where "auto" and "" follow the render options
+func (s drawState) antialiasText(textRendering string) bool {
+	switch textRendering {
+	case "optimizeSpeed":
+		return false
+	case "optimizeLegibility", "geometricPrecision":
+		return true
+	}
+	return s.antialias
+}
+
+// defaultFill is the fill color that is used when neither an element nor its parents set one
+var defaultFill color.Color = color.RGBA{0, 0, 0, 255}
+
+// drawElement draws an element with the given state from its parent elements,
+// using the inherited fill color if the element does not have one
+func drawElement(img draw.Image, el SvgElement, s drawState, inherited color.Color) {
+	if cancelled(s.ctx) {
+		return
+	}
+	clr := el.Color()
+	if clr == nil {
+		clr = inherited
+	}
+	if a, ok := el.(animatable); ok {
+		c := a.common()
+		if c.Display == "none" {
+			return
+		}
+		// Groups draw their children, which may be visible and have their own fill color
+		switch el.(type) {
+		case *SvgGroup:
+		case *SvgImage:
+			if c.hidden() {
+				return
+			}
+		default:
+			if c.hidden() || transparent(clr) {
+				return
+			}
+		}
+	}
+	if t, ok := el.(transformable); ok {
+		t.drawWith(img, clr, s)
+		return
+	}
+	el.Draw(img, clr)
+}
+
+// minInt returns the smallest of two integers
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the largest of two integers
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// transparent checks if nothing is painted with the color, as for fill="none"
+func transparent(clr color.Color) bool {
+	_, _, _, a := clr.RGBA()
+	return a == 0
+}
+
+// circleOutline returns a polygon that approximates a circle, using four cubic Bézier curves
+func circleOutline(cx, cy, r, tolerance float64) []fpoint {
+	const k = 0.5522847498 // distance to the control points, for a circle with radius 1
+	start := fpoint{cx + r, cy}
+	outline := []fpoint{start}
+	outline = append(outline, flattenCubic(start, fpoint{cx + r, cy + k*r}, fpoint{cx + k*r, cy + r}, fpoint{cx, cy + r}, tolerance)...)
+	outline = append(outline, flattenCubic(fpoint{cx, cy + r}, fpoint{cx - k*r, cy + r}, fpoint{cx - r, cy + k*r}, fpoint{cx - r, cy}, tolerance)...)
+	outline = append(outline, flattenCubic(fpoint{cx - r, cy}, fpoint{cx - r, cy - k*r}, fpoint{cx - k*r, cy - r}, fpoint{cx, cy - r}, tolerance)...)
+	outline = append(outline, flattenCubic(fpoint{cx, cy - r}, fpoint{cx + k*r, cy - r}, fpoint{cx + r, cy - k*r}, start, tolerance)...)
+	return outline
+}
+
 // Draw method for SvgCircle
-func (c SvgCircle) Draw(img *image.RGBA, clr color.Color) {
-	for y := -c.R; y <= c.R; y++ {
-		for x := -c.R; x <= c.R; x++ {
+func (c SvgCircle) Draw(img draw.Image, clr color.Color) {
+	c.drawWith(img, clr, defaultState)
+}
+
+func (c SvgCircle) drawWith(img draw.Image, clr color.Color, s drawState) {
+	s = s.with(c.Transform)
+	m := s.ctm
+	antialias := s.antialiasShape(c.ShapeRendering)
+	dx, dy, ok := m.integerTranslation()
+	if !ok || antialias {
+		fillPolygons(s.ctx, img, transformPolygons([][]fpoint{circleOutline(float64(c.Cx), float64(c.Cy), float64(c.R), s.userTolerance())}, m), clr, c.FillRule, antialias)
+		return
+	}
+	// Only the part of the circle that is within the image is visited
+	b := img.Bounds()
+	p := newPainter(img, clr)
+	for y := maxInt(-c.R, b.Min.Y-c.Cy-dy); y <= minInt(c.R, b.Max.Y-c.Cy-dy); y++ {
+		if y%cancelRows == 0 && cancelled(s.ctx) {
+			return
+		}
+		for x := maxInt(-c.R, b.Min.X-c.Cx-dx); x <= minInt(c.R, b.Max.X-c.Cx-dx); x++ {
 			if x*x+y*y <= c.R*c.R {
-				img.Set(c.Cx+x, c.Cy+y, clr)
+				p.set(c.Cx+x+dx, c.Cy+y+dy)
 			}
 		}
 	}
 }
 
 // Draw method for SvgRectangle
-func (r SvgRectangle) Draw(img *image.RGBA, clr color.Color) {
-	rect := image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
-	draw.Draw(img, rect, &image.Uniform{clr}, image.Point{}, draw.Src)
-}
-
-// Draw method for SvgPath
-func (p SvgPath) Draw(img *image.RGBA, clr color.Color) {
-	for _, command := range p.Commands {
-		switch command.Type {
-		case "M", "m", "L", "l":
-			for i := 0; i < len(command.Points)-1; i++ {
-				DrawLine(img, command.Points[i], command.Points[i+1], clr)
-			}
-		case "H", "h", "V", "v":
-			for i := 0; i < len(command.Points)-1; i++ {
-				DrawLine(img, command.Points[i], image.Point{X: command.Points[i+1].X, Y: command.Points[i].Y}, clr)
-			}
-		case "Z", "z":
-			if len(command.Points) > 1 {
-				DrawLine(img, command.Points[len(command.Points)-1], command.Points[0], clr)
-			}
-		}
+func (r SvgRectangle) Draw(img draw.Image, clr color.Color) {
+	r.drawWith(img, clr, defaultState)
+}
+
+func (r SvgRectangle) drawWith(img draw.Image, clr color.Color, s drawState) {
+	s = s.with(r.Transform)
+	m := s.ctm
+	dx, dy, ok := m.integerTranslation()
+	if !ok {
+		x0, y0 := float64(r.X), float64(r.Y)
+		x1, y1 := x0+float64(r.Width), y0+float64(r.Height)
+		fillPolygons(s.ctx, img, transformPolygons([][]fpoint{{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}}, m), clr, r.FillRule, s.antialiasShape(r.ShapeRendering))
+		return
 	}
+	// Only the rows that are within the image are visited
+	b := img.Bounds()
+	p := newPainter(img, clr)
+	for y := maxInt(r.Y+dy, b.Min.Y); y < minInt(r.Y+dy+r.Height, b.Max.Y); y++ {
+		p.replace(r.X+dx, r.X+dx+r.Width, y)
+	}
+}
+
+// Draw method for SvgPath, which fills the path using the non-zero winding rule
+func (p SvgPath) Draw(img draw.Image, clr color.Color) {
+	p.drawWith(img, clr, defaultState)
+}
+
+func (p SvgPath) drawWith(img draw.Image, clr color.Color, s drawState) {
+	s = s.with(p.Transform)
+	fillPolygons(s.ctx, img, transformPolygons(p.outline(s.userTolerance()), s.ctm), clr, p.FillRule, s.antialiasShape(p.ShapeRendering))
+}
+
+// Draw method for SvgGroup, where the given color is inherited by the elements that have no fill color
+func (g SvgGroup) Draw(img draw.Image, clr color.Color) {
+	g.drawWith(img, clr, defaultState)
 }
 
-// Draw method for SvgGroup
-func (g SvgGroup) Draw(img *image.RGBA, _ color.Color) {
+func (g SvgGroup) drawWith(img draw.Image, clr color.Color, s drawState) {
+	if clr == nil {
+		clr = defaultFill
+	}
+	s = s.with(g.Transform)
 	for _, el := range g.Elements {
-		el.Draw(img, el.Color())
+		drawElement(img, el, s, clr)
 	}
 }
 
 // Draw method for SvgLine
-func (l SvgLine) Draw(img *image.RGBA, clr color.Color) {
-	DrawLine(img, image.Point{X: l.X1, Y: l.Y1}, image.Point{X: l.X2, Y: l.Y2}, clr)
+func (l SvgLine) Draw(img draw.Image, clr color.Color) {
+	l.drawWith(img, clr, defaultState)
+}
+
+func (l SvgLine) drawWith(img draw.Image, clr color.Color, s drawState) {
+	m := s.with(l.Transform).ctm
+	x1, y1 := m.Apply(float64(l.X1), float64(l.Y1))
+	x2, y2 := m.Apply(float64(l.X2), float64(l.Y2))
+	drawLine(s.ctx, img, image.Point{X: roundCoordinate(x1), Y: roundCoordinate(y1)}, image.Point{X: roundCoordinate(x2), Y: roundCoordinate(y2)}, clr)
 }
 
 // DrawLine function to draw a line on an image
-func DrawLine(img *image.RGBA, p1, p2 image.Point, clr color.Color) {
+func DrawLine(img draw.Image, p1, p2 image.Point, clr color.Color) {
+	drawLine(nil, img, p1, p2, clr)
+}
+
+// drawLine is DrawLine, which stops if ctx, which may be nil, is cancelled
+func drawLine(ctx context.Context, img draw.Image, p1, p2 image.Point, clr color.Color) {
+	// Lines that are entirely on one side of the image do not touch it
+	b := img.Bounds()
+	if maxInt(p1.X, p2.X) < b.Min.X || minInt(p1.X, p2.X) >= b.Max.X || maxInt(p1.Y, p2.Y) < b.Min.Y || minInt(p1.Y, p2.Y) >= b.Max.Y {
+		return
+	}
 	// Bresenham's line algorithm
 	dx := abs(p2.X - p1.X)
 	dy := abs(p2.Y - p1.Y)
@@ -72,8 +255,12 @@ func DrawLine(img *image.RGBA, p1, p2 image.Point, clr color.Color) {
 	}
 	err := dx - dy
 
-	for {
-		img.Set(p1.X, p1.Y, clr)
+	p := newPainter(img, clr)
+	for steps := 0; ; steps++ {
+		if steps%(cancelRows*cancelRows) == 0 && cancelled(ctx) {
+			return
+		}
+		p.set(p1.X, p1.Y)
 		if p1 == p2 {
 			break
 		}
@@ -90,27 +277,48 @@ func DrawLine(img *image.RGBA, p1, p2 image.Point, clr color.Color) {
 }
 
 // Render function takes SVG elements and an image, and renders the elements onto the image
-func Render(elements []SvgElement, img *image.RGBA) {
+func Render(elements []SvgElement, img draw.Image) {
 	for _, el := range elements {
-		el.Draw(img, el.Color())
+		drawElement(img, el, defaultState, defaultFill)
 	}
 }
 
 // SavePNG function to save image as PNG
-func SavePNG(img *image.RGBA, filename string) error {
+func SavePNG(img image.Image, filename string) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	return png.Encode(file, img)
+	if err := WritePNG(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WritePNG function to write image as PNG to the given writer
+func WritePNG(w io.Writer, img image.Image) error {
+	return png.Encode(w, img)
 }
 
-// RenderAndSaveSVG takes SVG elements and a background color, creates an image,
-// renders the elements onto the image and saves it as PNG
-func RenderAndSaveSVG(elements []SvgElement, filename string, bgColor color.Color) error {
-	width, height, _ := GetSVGDimensions(filename)
-	img := NewColoredImage(width, height, bgColor)
-	Render(elements, img)
+// RenderAndSaveSVG takes a parsed SVG document and a background color, creates an image with the size
+// of the document, renders the document onto the image and saves it as PNG
+func RenderAndSaveSVG(doc *Document, filename string, bgColor color.Color) error {
+	if err := checkPixels(doc.Width, doc.Height, 0); err != nil {
+		return err
+	}
+	img := NewColoredImage(doc.Width, doc.Height, bgColor)
+	doc.Render(img)
 	return SavePNG(img, filename)
 }
+
+// RenderAndWritePNG takes a parsed SVG document and a background color, creates an image with the size
+// of the document, renders the document onto the image and writes it as PNG to the given writer
+func RenderAndWritePNG(doc *Document, w io.Writer, bgColor color.Color) error {
+	if err := checkPixels(doc.Width, doc.Height, 0); err != nil {
+		return err
+	}
+	img := NewColoredImage(doc.Width, doc.Height, bgColor)
+	doc.Render(img)
+	return WritePNG(w, img)
+}
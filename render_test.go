@@ -24,18 +24,13 @@ func TestRender(t *testing.T) {
 
 	for _, tc := range tt {
 		t.Run(tc.svgFile, func(t *testing.T) {
-			elements, err := ParseFile(tc.svgFile)
+			doc, err := ParseFile(tc.svgFile)
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			width, height, err := GetSVGDimensions(tc.svgFile)
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			img := image.NewRGBA(image.Rect(0, 0, width, height))
-			Render(elements, img)
+			img := image.NewRGBA(image.Rect(0, 0, doc.Width, doc.Height))
+			doc.Render(img)
 
 			for i, point := range tc.points {
 				if i >= len(tc.colors) {
@@ -54,3 +49,26 @@ func TestRender(t *testing.T) {
 		})
 	}
 }
+
+func TestFillPath(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	for d, expected := range map[string]color.Color{
+		"M1 1H9V9H1Z":              red,          // filled, not only outlined
+		"M1 1H9V9H1ZM3 3H7V7H3Z":   red,          // the inner square winds the same way
+		"M1 1H9V9H1ZM3 3V7H7V3Z":   color.RGBA{}, // the inner square winds the other way
+		"M1 1L9 1L9 9C5 9 1 5 1 1": red,          // curves are filled too
+	} {
+		path, err := ParsePath(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+		path.Draw(img, red)
+		if c := img.At(5, 5); c != expected {
+			t.Errorf("Expected %q to be %v in the middle, but got %v", d, expected, c)
+		}
+		if c := img.At(0, 0); c != (color.RGBA{}) {
+			t.Errorf("Expected %q to leave the corner empty, but got %v", d, c)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package surrender
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxDecompressedSize is the largest size that the input may have, or that gzip compressed input
+// may decompress to, if ParseOptions.MaxDecompressedSize is not set
+const DefaultMaxDecompressedSize = 64 << 20 // 64 MiB
+
+var (
+	// ErrDecompressedSize is returned when gzip compressed input decompresses to more than the allowed size
+	ErrDecompressedSize = errors.New("decompressed SVG is too large")
+	// ErrInputSize is returned when input that is not compressed is larger than the allowed size
+	ErrInputSize = errors.New("SVG is too large")
+)
+
+// decompress returns a reader that decompresses the input if it starts with the gzip magic bytes,
+// and that fails with ErrDecompressedSize if it decompresses to more than max bytes.
+// Input that is not compressed is read as it is, and fails with ErrInputSize if it is more than max bytes.
+func decompress(r io.Reader, max int64) (io.Reader, error) {
+	if max <= 0 {
+		max = DefaultMaxDecompressedSize
+	}
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		// Not gzip, or too short to tell, so let the XML parser report any errors
+		return &capReader{r: br, remaining: max, err: ErrInputSize}, nil
+	}
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip data: %w", err)
+	}
+	return &capReader{r: gz, remaining: max, err: ErrDecompressedSize}, nil
+}
+
+// capReader reads from r, and fails with err if there is more than remaining bytes to read
+type capReader struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+func (c *capReader) Read(p []byte) (int, error) {
+	if c.remaining < 0 {
+		return 0, c.err
+	}
+	// Read one byte more than allowed, to find out if the input is too large
+	if int64(len(p)) > c.remaining+1 {
+		p = p[:c.remaining+1]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if c.remaining < 0 {
+		return n + int(c.remaining), c.err
+	}
+	return n, err
+}
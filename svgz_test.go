@@ -0,0 +1,51 @@
+package surrender
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSVGZ(t *testing.T) {
+	plain, err := ParseFile("testdata/circle.svg")
+	assert.NoError(t, err)
+	compressed, err := ParseFile("testdata/circle.svgz")
+	assert.NoError(t, err)
+	assert.Equal(t, plain.Elements, compressed.Elements)
+	assert.Equal(t, plain.Width, compressed.Width)
+}
+
+func TestDecompressedSizeLimit(t *testing.T) {
+	// A small gzip file that decompresses to a large SVG file
+	svg := `<svg xmlns="http://www.w3.org/2000/svg"><desc>` + strings.Repeat(" ", 1<<20) + `</desc></svg>`
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(svg))
+	gz.Close()
+	data := buf.Bytes()
+	assert.Less(t, len(data), 4096)
+
+	_, err := ParseWithOptions(bytes.NewReader(data), ParseOptions{MaxDecompressedSize: 1 << 16})
+	assert.ErrorIs(t, err, ErrDecompressedSize)
+	_, err = ParseBytes(data)
+	assert.NoError(t, err)
+
+	// The limit is exact
+	_, err = ParseWithOptions(bytes.NewReader(data), ParseOptions{MaxDecompressedSize: int64(len(svg))})
+	assert.NoError(t, err)
+	_, err = ParseWithOptions(bytes.NewReader(data), ParseOptions{MaxDecompressedSize: int64(len(svg) - 1)})
+	assert.ErrorIs(t, err, ErrDecompressedSize)
+
+	// Input that is not compressed has the same limit
+	_, err = ParseWithOptions(strings.NewReader(svg), ParseOptions{MaxDecompressedSize: int64(len(svg) - 1)})
+	assert.ErrorIs(t, err, ErrInputSize)
+	_, err = ParseWithOptions(strings.NewReader(svg), ParseOptions{MaxDecompressedSize: int64(len(svg))})
+	assert.NoError(t, err)
+
+	// Broken gzip data
+	_, err = ParseBytes(data[:10])
+	assert.Error(t, err)
+}
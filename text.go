@@ -0,0 +1,409 @@
+package surrender
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	"github.com/beevik/etree"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// defaultFontSize is the font size used when none is given, which is the native size of the built-in font
+const defaultFontSize = 13
+
+// AutoSize is used for the Width and Height of an SvgTextArea when they are "auto"
+const AutoSize = -1
+
+// SvgText struct
+type SvgText struct {
+	Common
+	X, Y     int
+	FontSize int
+	Anchor   string // text-anchor: "start", "middle" or "end"
+	Text     string
+	Fill     color.Color
+}
+
+func (t SvgText) Color() color.Color {
+	return t.Fill
+}
+
+// SvgTextArea struct, for the TinySVG 1.2 textArea element
+type SvgTextArea struct {
+	Common
+	X, Y, Width, Height int    // Width and Height can be AutoSize
+	FontSize            int    // in pixels
+	LineIncrement       int    // distance between baselines, 0 is "auto"
+	DisplayAlign        string // "auto", "before", "center" or "after"
+	TextAlign           string // "start", "center" or "end"
+	Text                string // the text content, where "\n" marks a tbreak element
+	Fill                color.Color
+}
+
+func (t SvgTextArea) Color() color.Color {
+	return t.Fill
+}
+
+// parseText parses a text element, using the inherited style
+func (p *parser) parseText(el *etree.Element, st style, common Common, fill color.Color) SvgText {
+	x, y := p.length(el, "x", 0), p.length(el, "y", 0)
+	text := textContent(el, st.preserveSpace)
+	// tbreak is only meaningful inside a textArea
+	text = strings.ReplaceAll(text, "\n", " ")
+	return SvgText{Common: common, X: x, Y: y, FontSize: st.fontSize, Anchor: st.textAnchor, Text: text, Fill: fill}
+}
+
+// parseTextArea parses a textArea element, using the inherited style
+func (p *parser) parseTextArea(el *etree.Element, st style, common Common, fill color.Color) SvgTextArea {
+	x, y := p.length(el, "x", 0), p.length(el, "y", 0)
+	size := func(name string) int {
+		if v := strings.TrimSpace(el.SelectAttrValue(name, "auto")); v == "auto" {
+			return AutoSize
+		}
+		n := p.length(el, name, AutoSize)
+		if n < 0 {
+			p.report(SeverityError, el, name, fmt.Errorf("%q is negative", el.SelectAttrValue(name, "")))
+			return AutoSize
+		}
+		return n
+	}
+	return SvgTextArea{
+		Common:        common,
+		X:             x,
+		Y:             y,
+		Width:         size("width"),
+		Height:        size("height"),
+		FontSize:      st.fontSize,
+		LineIncrement: st.lineIncrement,
+		DisplayAlign:  st.displayAlign,
+		TextAlign:     st.textAlign,
+		Text:          textContent(el, st.preserveSpace),
+		Fill:          fill,
+	}
+}
+
+// textContent collects the character data of a text element and its tspan children,
+// and handles white space according to xml:space. A tbreak element is returned as "\n".
+func textContent(el *etree.Element, preserveSpace bool) string {
+	var sb strings.Builder
+	var collect func(el *etree.Element)
+	collect = func(el *etree.Element) {
+		for _, token := range el.Child {
+			switch t := token.(type) {
+			case *etree.CharData:
+				s := t.Data
+				if !preserveSpace {
+					s = strings.ReplaceAll(s, "\r", "")
+					s = strings.ReplaceAll(s, "\n", "")
+				}
+				s = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ", "\t", " ").Replace(s)
+				sb.WriteString(s)
+			case *etree.Element:
+				switch t.Tag {
+				case "tbreak":
+					sb.WriteString("\n")
+				case "tspan":
+					collect(t)
+				}
+			}
+		}
+	}
+	collect(el)
+	if preserveSpace {
+		return sb.String()
+	}
+
+	// Collapse runs of spaces and strip spaces at the start and end of each line
+	lines := strings.Split(sb.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// textFace is the built-in 7x13 bitmap font, scaled to a font size with nearest-neighbor sampling
+type textFace struct {
+	scale float64
+}
+
+// newTextFace returns the built-in font at the given font size, in pixels
+func newTextFace(fontSize int) textFace {
+	return textFace{float64(fontSize) / float64(basicfont.Face7x13.Height)}
+}
+
+// advance returns the horizontal distance between two glyphs
+func (f textFace) advance() float64 {
+	return float64(basicfont.Face7x13.Advance) * f.scale
+}
+
+// ascent returns the distance from the top of a line to the baseline
+func (f textFace) ascent() float64 {
+	return float64(basicfont.Face7x13.Ascent) * f.scale
+}
+
+// height returns the height of a line of text
+func (f textFace) height() float64 {
+	return float64(basicfont.Face7x13.Ascent+basicfont.Face7x13.Descent) * f.scale
+}
+
+// measure returns the width of the given string
+func (f textFace) measure(s string) float64 {
+	return float64(len([]rune(s))) * f.advance()
+}
+
+// clipRect is a rectangle in user space that drawing is limited to
+type clipRect struct {
+	x0, y0, x1, y1 float64
+}
+
+// noClip is a clipping rectangle that does not clip anything
+var noClip = clipRect{math.Inf(-1), math.Inf(-1), math.Inf(1), math.Inf(1)}
+
+// contains checks if the given point is within the clipping rectangle
+func (c clipRect) contains(x, y float64) bool {
+	return x >= c.x0 && x < c.x1 && y >= c.y0 && y < c.y1
+}
+
+// deviceBounds returns the pixels that the rectangle from (x0, y0) to (x1, y1) covers when transformed by m
+func deviceBounds(m Matrix, x0, y0, x1, y1 float64) image.Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range []fpoint{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}} {
+		p = m.applyPoint(p)
+		minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+		maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+	}
+	// Avoid overflows for huge or infinite bounds
+	limit := float64(math.MaxInt32 / 2)
+	clamp := func(v float64) int {
+		return int(math.Max(-limit, math.Min(limit, v)))
+	}
+	return image.Rect(clamp(math.Floor(minX)), clamp(math.Floor(minY)), clamp(math.Ceil(maxX)), clamp(math.Ceil(maxY)))
+}
+
+// textSamples is the number of samples per pixel in each direction, when text is antialiased
+const textSamples = 4
+
+// draw draws the string with the left end of the baseline at (x, y), transformed by m,
+// and only sets the pixels where the user space position is within clip. With antialiasing,
+// each pixel is sampled several times, and the color is drawn with the part that is inked as the opacity.
+func (f textFace) draw(img draw.Image, m Matrix, x, y float64, s string, clr color.Color, clip clipRect, antialias bool) {
+	face := basicfont.Face7x13
+	inverse, ok := m.Invert()
+	if !ok || f.scale <= 0 {
+		return
+	}
+	top := y - f.ascent()
+	bottom := top + f.height()
+	width := float64(face.Width) * f.scale
+	p := newPainter(img, clr)
+	for i, r := range []rune(s) {
+		// Runes that are missing from the font are drawn as the replacement character
+		_, mask, maskp, _, _ := face.Glyph(fixed.Point26_6{}, r)
+		if mask == nil {
+			continue
+		}
+		left := x + float64(i)*f.advance()
+		glyph := clipRect{left, top, left + width, bottom}
+		// inked checks if the device position is on a pixel of the glyph that is set
+		inked := func(dx, dy float64) bool {
+			ux, uy := inverse.Apply(dx, dy)
+			if !glyph.contains(ux, uy) || !clip.contains(ux, uy) {
+				return false
+			}
+			sx := int(math.Floor((ux - left) / f.scale))
+			sy := int(math.Floor((uy - top) / f.scale))
+			if sx < 0 || sx >= face.Width || sy < 0 || sy >= face.Ascent+face.Descent {
+				return false
+			}
+			_, _, _, a := mask.At(maskp.X+sx, maskp.Y+sy).RGBA()
+			return a >= 0x8000
+		}
+		pixels := deviceBounds(m, glyph.x0, glyph.y0, glyph.x1, glyph.y1).Intersect(img.Bounds())
+		for py := pixels.Min.Y; py < pixels.Max.Y; py++ {
+			for px := pixels.Min.X; px < pixels.Max.X; px++ {
+				if !antialias {
+					// Use the pixel center
+					if inked(float64(px)+0.5, float64(py)+0.5) {
+						p.set(px, py)
+					}
+					continue
+				}
+				hits := 0
+				for sy := 0; sy < textSamples; sy++ {
+					for sx := 0; sx < textSamples; sx++ {
+						if inked(float64(px)+(float64(sx)+0.5)/textSamples, float64(py)+(float64(sy)+0.5)/textSamples) {
+							hits++
+						}
+					}
+				}
+				if hits > 0 {
+					p.blend(px, py, float64(hits)/(textSamples*textSamples))
+				}
+			}
+		}
+	}
+}
+
+// Draw method for SvgText
+func (t SvgText) Draw(img draw.Image, clr color.Color) {
+	t.drawWith(img, clr, defaultState)
+}
+
+func (t SvgText) drawWith(img draw.Image, clr color.Color, s drawState) {
+	face := newTextFace(t.FontSize)
+	face.draw(img, s.with(t.Transform).ctm, t.left(face), float64(t.Y), t.Text, clr, noClip, s.antialiasText(t.TextRendering))
+}
+
+// left returns where the text starts, after aligning it according to the text-anchor property
+func (t SvgText) left(face textFace) float64 {
+	x := float64(t.X)
+	switch t.Anchor {
+	case "middle":
+		x -= face.measure(t.Text) / 2
+	case "end":
+		x -= face.measure(t.Text)
+	}
+	return x
+}
+
+// Lines returns the lines of text that fit in the text area, after word wrapping
+// and explicit line breaks, and after dropping the lines that overflow the height
+func (t SvgTextArea) Lines() []string {
+	if t.Width == 0 || t.Height == 0 {
+		return nil
+	}
+	face := newTextFace(t.FontSize)
+	var lines []string
+	for _, paragraph := range strings.Split(t.Text, "\n") {
+		if t.Width == AutoSize {
+			lines = append(lines, paragraph)
+			continue
+		}
+		lines = append(lines, wrapText(face, paragraph, float64(t.Width))...)
+	}
+	if t.Height != AutoSize {
+		fitting := 0
+		for i := range lines {
+			if float64(i)*t.lineIncrement(face)+face.height() > float64(t.Height) {
+				break
+			}
+			fitting++
+		}
+		lines = lines[:fitting]
+	}
+	return lines
+}
+
+// lineIncrement returns the distance between two baselines, resolving "auto" from the font
+func (t SvgTextArea) lineIncrement(face textFace) float64 {
+	if t.LineIncrement > 0 {
+		return float64(t.LineIncrement)
+	}
+	return math.Round(face.height())
+}
+
+// wrapText breaks a paragraph into lines that fit within the given width. Lines are broken
+// at spaces, and words that are wider than a whole line are broken between characters.
+func wrapText(face textFace, paragraph string, width float64) []string {
+	if paragraph == "" {
+		return []string{""}
+	}
+	var lines []string
+	line := ""
+	started := false
+	for _, word := range strings.Split(paragraph, " ") {
+		candidate := word
+		if started {
+			candidate = line + " " + word
+		}
+		if face.measure(candidate) <= width {
+			line, started = candidate, true
+			continue
+		}
+		if started {
+			lines = append(lines, line)
+		}
+		// Break words that do not fit on a line of their own
+		runes := []rune(word)
+		perLine := int(width / face.advance())
+		if perLine < 1 {
+			perLine = 1
+		}
+		for len(runes) > perLine {
+			lines = append(lines, string(runes[:perLine]))
+			runes = runes[perLine:]
+		}
+		line, started = string(runes), true
+	}
+	return append(lines, line)
+}
+
+// Draw method for SvgTextArea
+func (t SvgTextArea) Draw(img draw.Image, clr color.Color) {
+	t.drawWith(img, clr, defaultState)
+}
+
+func (t SvgTextArea) drawWith(img draw.Image, clr color.Color, s drawState) {
+	lines, face, clip := t.layout()
+	m := s.with(t.Transform).ctm
+	for _, line := range lines {
+		face.draw(img, m, line.x, line.y, line.text, clr, clip, s.antialiasText(t.TextRendering))
+	}
+}
+
+// placedLine is a line of text, with the left end of its baseline at (x, y)
+type placedLine struct {
+	text string
+	x, y float64
+}
+
+// layout returns the lines of the text area where they are drawn, the font and the clipping rectangle
+func (t SvgTextArea) layout() ([]placedLine, textFace, clipRect) {
+	face := newTextFace(t.FontSize)
+	clip := noClip
+	lines := t.Lines()
+	if len(lines) == 0 {
+		return nil, face, clip
+	}
+	inc := t.lineIncrement(face)
+
+	if t.Width != AutoSize {
+		clip.x0, clip.x1 = float64(t.X), float64(t.X+t.Width)
+	}
+	offset := 0.0
+	if t.Height != AutoSize {
+		clip.y0, clip.y1 = float64(t.Y), float64(t.Y+t.Height)
+		total := float64(len(lines)-1)*inc + face.height()
+		switch t.DisplayAlign {
+		case "center":
+			offset = (float64(t.Height) - total) / 2
+		case "after":
+			offset = float64(t.Height) - total
+		}
+	}
+
+	placed := make([]placedLine, 0, len(lines))
+	for i, line := range lines {
+		w := face.measure(line)
+		x := float64(t.X)
+		switch {
+		case t.TextAlign == "center" && t.Width == AutoSize:
+			x -= w / 2
+		case t.TextAlign == "center":
+			x += (float64(t.Width) - w) / 2
+		case t.TextAlign == "end" && t.Width == AutoSize:
+			x -= w
+		case t.TextAlign == "end":
+			x += float64(t.Width) - w
+		}
+		baseline := float64(t.Y) + offset + float64(i)*inc + face.ascent()
+		placed = append(placed, placedLine{line, math.Round(x), math.Round(baseline)})
+	}
+	return placed, face, clip
+}
@@ -0,0 +1,88 @@
+package surrender
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTextArea(t *testing.T) {
+	doc, err := ParseFile("testdata/textarea.svg")
+	assert.NoError(t, err)
+	elements := doc.Elements
+	assert.Len(t, elements, 1)
+
+	group, ok := elements[0].(*SvgGroup)
+	assert.True(t, ok)
+	assert.Len(t, group.Elements, 3)
+
+	area, ok := group.Elements[0].(*SvgTextArea)
+	assert.True(t, ok)
+	assert.Equal(t, 10, area.X)
+	assert.Equal(t, 70, area.Width)
+	assert.Equal(t, 40, area.Height)
+	assert.Equal(t, 13, area.FontSize)
+	assert.Equal(t, "The quick brown fox\njumps over the lazy dog", area.Text)
+	// The fill is inherited from the group when rendering
+	assert.Equal(t, colorNavy, group.Fill)
+	assert.Nil(t, area.Fill)
+
+	auto, ok := group.Elements[1].(*SvgTextArea)
+	assert.True(t, ok)
+	assert.Equal(t, AutoSize, auto.Width)
+	assert.Equal(t, AutoSize, auto.Height)
+	assert.Equal(t, "end", auto.TextAlign)
+	assert.Equal(t, "right aligned", auto.Text)
+
+	text, ok := group.Elements[2].(*SvgText)
+	assert.True(t, ok)
+	assert.Equal(t, "Hi", text.Text)
+	assert.Equal(t, 26, text.FontSize)
+	assert.Equal(t, "middle", text.Anchor)
+}
+
+var colorNavy = color.RGBA{0, 0, 128, 255}
+
+func TestTextAreaLines(t *testing.T) {
+	area := SvgTextArea{Width: 70, Height: AutoSize, FontSize: 13, Text: "The quick brown fox\njumps over the lazy dog"}
+	// The built-in font is 7 pixels wide per character at size 13, so 10 characters fit on a line
+	assert.Equal(t, []string{"The quick", "brown fox", "jumps over", "the lazy", "dog"}, area.Lines())
+
+	// Only the lines that fit within the height are kept
+	area.Height = 40
+	assert.Equal(t, []string{"The quick", "brown fox", "jumps over"}, area.Lines())
+
+	area.LineIncrement = 20
+	assert.Equal(t, []string{"The quick", "brown fox"}, area.Lines())
+
+	// Words that are wider than the area are broken between characters
+	area = SvgTextArea{Width: 28, Height: AutoSize, FontSize: 13, Text: "abcdefghij"}
+	assert.Equal(t, []string{"abcd", "efgh", "ij"}, area.Lines())
+
+	// A width or height of zero disables rendering
+	area.Height = 0
+	assert.Empty(t, area.Lines())
+}
+
+func TestTextAreaDraw(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	area := SvgTextArea{X: 10, Y: 10, Width: 30, Height: 13, FontSize: 13, Text: "IIIIIIIIIIII", Fill: colorNavy}
+	area.Draw(img, area.Fill)
+
+	// Nothing is drawn outside of the text area
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if x >= 10 && x < 40 && y >= 10 && y < 23 {
+				continue
+			}
+			if img.RGBAAt(x, y) != (color.RGBA{}) {
+				t.Fatalf("Pixel at (%d, %d) is outside of the text area, but was drawn", x, y)
+			}
+		}
+	}
+
+	// The vertical bar of the first "I" is drawn
+	assert.Equal(t, colorNavy, img.RGBAAt(13, 15))
+}
@@ -0,0 +1,203 @@
+package surrender
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Matrix is an affine transformation matrix, as given by "matrix(a b c d e f)" in a transform attribute.
+// A point (x, y) is transformed to (a*x + c*y + e, b*x + d*y + f).
+type Matrix struct {
+	A, B, C, D, E, F float64
+}
+
+// Identity is the transformation matrix that leaves points unchanged
+var Identity = Matrix{1, 0, 0, 1, 0, 0}
+
+// Translate returns a matrix that moves points by (tx, ty)
+func Translate(tx, ty float64) Matrix {
+	return Matrix{1, 0, 0, 1, tx, ty}
+}
+
+// Scale returns a matrix that scales points by (sx, sy)
+func Scale(sx, sy float64) Matrix {
+	return Matrix{sx, 0, 0, sy, 0, 0}
+}
+
+// Rotate returns a matrix that rotates points around the origin by the given number of degrees
+func Rotate(degrees float64) Matrix {
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	return Matrix{cos, sin, -sin, cos, 0, 0}
+}
+
+// Multiply returns the matrix that first applies n and then m
+func (m Matrix) Multiply(n Matrix) Matrix {
+	return Matrix{
+		A: m.A*n.A + m.C*n.B,
+		B: m.B*n.A + m.D*n.B,
+		C: m.A*n.C + m.C*n.D,
+		D: m.B*n.C + m.D*n.D,
+		E: m.A*n.E + m.C*n.F + m.E,
+		F: m.B*n.E + m.D*n.F + m.F,
+	}
+}
+
+// Apply transforms the point (x, y)
+func (m Matrix) Apply(x, y float64) (float64, float64) {
+	return m.A*x + m.C*y + m.E, m.B*x + m.D*y + m.F
+}
+
+// Invert returns the inverse matrix, and false if the matrix can not be inverted
+func (m Matrix) Invert() (Matrix, bool) {
+	det := m.A*m.D - m.B*m.C
+	if det == 0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return Matrix{}, false
+	}
+	return Matrix{
+		A: m.D / det,
+		B: -m.B / det,
+		C: -m.C / det,
+		D: m.A / det,
+		E: (m.C*m.F - m.D*m.E) / det,
+		F: (m.B*m.E - m.A*m.F) / det,
+	}, true
+}
+
+// integerTranslation checks if the matrix only moves points by a whole number of pixels, and returns the offset
+func (m Matrix) integerTranslation() (int, int, bool) {
+	if m.A != 1 || m.B != 0 || m.C != 0 || m.D != 1 || m.E != math.Trunc(m.E) || m.F != math.Trunc(m.F) {
+		return 0, 0, false
+	}
+	return int(m.E), int(m.F), true
+}
+
+// transform returns the combined transformation of a parent transformation and an element transform attribute,
+// where a nil transform attribute means that the element has no transform
+func transform(ctm Matrix, own *Matrix) Matrix {
+	if own == nil {
+		return ctm
+	}
+	return ctm.Multiply(*own)
+}
+
+// applyPoint transforms a point
+func (m Matrix) applyPoint(p fpoint) fpoint {
+	x, y := m.Apply(p.X, p.Y)
+	return fpoint{x, y}
+}
+
+// transformPolygons returns the given polygons with all points transformed
+func transformPolygons(polygons [][]fpoint, m Matrix) [][]fpoint {
+	if m == Identity {
+		return polygons
+	}
+	transformed := make([][]fpoint, len(polygons))
+	for i, poly := range polygons {
+		transformed[i] = make([]fpoint, len(poly))
+		for j, p := range poly {
+			transformed[i][j] = m.applyPoint(p)
+		}
+	}
+	return transformed
+}
+
+// ParseTransform parses a TinySVG 1.2 transform attribute, which is a list of
+// matrix, translate, scale, rotate, skewX and skewY transformations
+func ParseTransform(s string) (Matrix, error) {
+	m := Identity
+	rest := strings.TrimSpace(s)
+	for rest != "" {
+		open := strings.IndexByte(rest, '(')
+		if open < 0 {
+			return Identity, fmt.Errorf("invalid transform: %q", s)
+		}
+		closing := strings.IndexByte(rest, ')')
+		if closing < open {
+			return Identity, fmt.Errorf("invalid transform: %q", s)
+		}
+		name := strings.TrimSpace(rest[:open])
+		args, err := parseNumberList(rest[open+1 : closing])
+		if err != nil {
+			return Identity, fmt.Errorf("invalid transform %q: %w", s, err)
+		}
+		t, err := transformFunction(name, args)
+		if err != nil {
+			return Identity, err
+		}
+		m = m.Multiply(t)
+		rest = strings.TrimLeft(rest[closing+1:], " \t\r\n,")
+	}
+	return m, nil
+}
+
+// transformFunction returns the matrix for one function in a transform list
+func transformFunction(name string, args []float64) (Matrix, error) {
+	argc := len(args)
+	wrongCount := fmt.Errorf("wrong number of arguments for %s: %d", name, argc)
+	switch name {
+	case "matrix":
+		if argc != 6 {
+			return Identity, wrongCount
+		}
+		return Matrix{args[0], args[1], args[2], args[3], args[4], args[5]}, nil
+	case "translate":
+		switch argc {
+		case 1:
+			return Translate(args[0], 0), nil
+		case 2:
+			return Translate(args[0], args[1]), nil
+		}
+		return Identity, wrongCount
+	case "scale":
+		switch argc {
+		case 1:
+			return Scale(args[0], args[0]), nil
+		case 2:
+			return Scale(args[0], args[1]), nil
+		}
+		return Identity, wrongCount
+	case "rotate":
+		switch argc {
+		case 1:
+			return Rotate(args[0]), nil
+		case 3:
+			// Rotate around the point (cx, cy)
+			return Translate(args[1], args[2]).Multiply(Rotate(args[0])).Multiply(Translate(-args[1], -args[2])), nil
+		}
+		return Identity, wrongCount
+	case "skewX":
+		if argc != 1 {
+			return Identity, wrongCount
+		}
+		return Matrix{1, 0, math.Tan(args[0] * math.Pi / 180), 1, 0, 0}, nil
+	case "skewY":
+		if argc != 1 {
+			return Identity, wrongCount
+		}
+		return Matrix{1, math.Tan(args[0] * math.Pi / 180), 0, 1, 0, 0}, nil
+	case "":
+		return Identity, errors.New("missing transform function name")
+	}
+	return Identity, fmt.Errorf("unknown transform function: %s", name)
+}
+
+// parseNumberList parses a list of numbers separated by whitespace and/or commas
+func parseNumberList(s string) ([]float64, error) {
+	var numbers []float64
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c == ' ' || c == ',' || c == '\t' || c == '\n' || c == '\r' {
+			i++
+			continue
+		}
+		value, n, err := scanNumber(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, value)
+		i += n
+	}
+	return numbers, nil
+}
@@ -0,0 +1,73 @@
+package surrender
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTransform(t *testing.T) {
+	tests := []struct {
+		transform string
+		expected  Matrix
+	}{
+		{"translate(10)", Matrix{1, 0, 0, 1, 10, 0}},
+		{"translate(10, 20)", Matrix{1, 0, 0, 1, 10, 20}},
+		{"scale(2)", Matrix{2, 0, 0, 2, 0, 0}},
+		{"scale(2 3)", Matrix{2, 0, 0, 3, 0, 0}},
+		{"matrix(1,2,3,4,5,6)", Matrix{1, 2, 3, 4, 5, 6}},
+		{"translate(10,20) scale(2)", Matrix{2, 0, 0, 2, 10, 20}},
+		{"rotate(90)", Matrix{0, 1, -1, 0, 0, 0}},
+		{"rotate(180 5 5)", Matrix{-1, 0, 0, -1, 10, 10}},
+		{"skewX(45)", Matrix{1, 0, 1, 1, 0, 0}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.transform, func(t *testing.T) {
+			m, err := ParseTransform(tc.transform)
+			assert.NoError(t, err)
+			for i, v := range []float64{m.A - tc.expected.A, m.B - tc.expected.B, m.C - tc.expected.C, m.D - tc.expected.D, m.E - tc.expected.E, m.F - tc.expected.F} {
+				if math.Abs(v) > 1e-9 {
+					t.Errorf("Component %d of %v differs from %v", i, m, tc.expected)
+				}
+			}
+		})
+	}
+
+	for _, invalid := range []string{"translate(1, 2, 3)", "spin(10)", "scale(2", "(1)"} {
+		_, err := ParseTransform(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestMatrixInvert(t *testing.T) {
+	m := Translate(10, 20).Multiply(Rotate(30)).Multiply(Scale(2, 3))
+	inverse, ok := m.Invert()
+	assert.True(t, ok)
+	x, y := inverse.Apply(m.Apply(7, -3))
+	assert.InDelta(t, 7, x, 1e-9)
+	assert.InDelta(t, -3, y, 1e-9)
+
+	_, ok = Scale(0, 1).Invert()
+	assert.False(t, ok)
+}
+
+func TestRenderTransformedGroup(t *testing.T) {
+	m, err := ParseTransform("translate(50 50) rotate(45)")
+	assert.NoError(t, err)
+	red := color.RGBA{255, 0, 0, 255}
+	group := SvgGroup{
+		Elements: []SvgElement{SvgRectangle{X: -10, Y: -10, Width: 20, Height: 20, Fill: red}},
+		Common:   Common{Transform: &m},
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	Render([]SvgElement{group}, img)
+
+	// The rotated square is a diamond around (50, 50), that reaches about 14 pixels out
+	assert.Equal(t, red, img.RGBAAt(50, 50))
+	assert.Equal(t, red, img.RGBAAt(62, 50))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(58, 42))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(66, 50))
+}
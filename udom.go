@@ -0,0 +1,338 @@
+package surrender
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// The errors of the uDOM methods, which correspond to the exceptions in the TinySVG 1.2 uDOM
+var (
+	// ErrNotSupported is returned for traits that the element does not have
+	ErrNotSupported = errors.New("trait not supported")
+	// ErrTypeMismatch is returned when a trait can not be read or written as the requested type
+	ErrTypeMismatch = errors.New("trait type mismatch")
+	// ErrNotFound is returned when an element is not in the document, or not a child of the given parent
+	ErrNotFound = errors.New("element not found")
+	// ErrHierarchy is returned when an element would be inserted into itself or one of its descendants
+	ErrHierarchy = errors.New("invalid hierarchy")
+)
+
+// traits returns the element as an element with traits, or ErrNotSupported
+func traits(el SvgElement) (animatable, error) {
+	a, ok := el.(animatable)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotSupported, el)
+	}
+	return a, nil
+}
+
+// Trait returns the value of a trait of the element, like getTrait in the uDOM.
+// Traits are the attributes and properties of the element, like "x", "fill" or "transform".
+func (d *Document) Trait(el SvgElement, name string) (string, error) {
+	a, err := traits(el)
+	if err != nil {
+		return "", err
+	}
+	if value, ok := a.attribute(name); ok {
+		return value, nil
+	}
+	if _, err := a.withAttribute(name, ""); errors.Is(err, errUnknownAttribute) {
+		return "", fmt.Errorf("%w: %s", ErrNotSupported, name)
+	}
+	// The trait exists, but is not set
+	return "", nil
+}
+
+// SetTrait changes a trait of the element, like setTrait in the uDOM. The element is changed in place,
+// so that the document can be rendered again. Setting an inherited property like visibility on a group
+// also sets it on the descendants that inherit it, but not on those that set it themselves.
+func (d *Document) SetTrait(el SvgElement, name, value string) error {
+	a, err := traits(el)
+	if err != nil {
+		return err
+	}
+	changed, err := a.withAttribute(name, value)
+	if errors.Is(err, errUnknownAttribute) {
+		return fmt.Errorf("%w: %s", ErrNotSupported, name)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTypeMismatch, err)
+	}
+	if err := replaceElement(el, changed); err != nil {
+		return err
+	}
+	if g, ok := el.(*SvgGroup); ok && propertyValues[name] != nil {
+		inheritProperty(g.Elements, name, strings.TrimSpace(value))
+	}
+	if name == "id" {
+		d.index()
+	}
+	return nil
+}
+
+// inheritProperty sets an inherited property on the elements, and their children, that inherit it.
+// The elements that set the property themselves keep their value, and so do their children.
+func inheritProperty(elements []SvgElement, name, value string) {
+	for _, el := range elements {
+		a, ok := el.(animatable)
+		if !ok || a.common().specified&propertyBits[name] != 0 {
+			continue
+		}
+		replaceElement(el, changed(el, func(c *Common, _ *color.Color) {
+			*c.property(name) = value
+		}))
+		if g, ok := el.(*SvgGroup); ok {
+			inheritProperty(g.Elements, name, value)
+		}
+	}
+}
+
+// replaceElement overwrites the element that el points to with the changed copy
+func replaceElement(el, changed SvgElement) error {
+	switch e := el.(type) {
+	case *SvgCircle:
+		*e = *changed.(*SvgCircle)
+	case *SvgRectangle:
+		*e = *changed.(*SvgRectangle)
+	case *SvgPath:
+		*e = *changed.(*SvgPath)
+	case *SvgGroup:
+		*e = *changed.(*SvgGroup)
+	case *SvgLine:
+		*e = *changed.(*SvgLine)
+	case *SvgText:
+		*e = *changed.(*SvgText)
+	case *SvgTextArea:
+		*e = *changed.(*SvgTextArea)
+	case *SvgImage:
+		*e = *changed.(*SvgImage)
+	default:
+		return fmt.Errorf("%w: %T", ErrNotSupported, el)
+	}
+	return nil
+}
+
+// FloatTrait returns a numeric trait of the element, like getFloatTrait in the uDOM
+func (d *Document) FloatTrait(el SvgElement, name string) (float64, error) {
+	value, err := d.Trait(el, name)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s is %q", ErrTypeMismatch, name, value)
+	}
+	return f, nil
+}
+
+// SetFloatTrait changes a numeric trait of the element, like setFloatTrait in the uDOM.
+// Coordinates and lengths are rounded to whole numbers.
+func (d *Document) SetFloatTrait(el SvgElement, name string, value float64) error {
+	return d.SetTrait(el, name, formatNumber(value))
+}
+
+// PathTrait returns the path commands of a path trait, like getPathTrait in the uDOM
+func (d *Document) PathTrait(el SvgElement, name string) ([]PathCommand, error) {
+	value, err := d.Trait(el, name)
+	if err != nil {
+		return nil, err
+	}
+	if name != "d" {
+		return nil, fmt.Errorf("%w: %s is not a path", ErrTypeMismatch, name)
+	}
+	path, err := ParsePath(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTypeMismatch, err)
+	}
+	return path.Commands, nil
+}
+
+// SetPathTrait changes a path trait to the given path commands, like setPathTrait in the uDOM
+func (d *Document) SetPathTrait(el SvgElement, name string, commands []PathCommand) error {
+	if name != "d" {
+		if _, err := d.Trait(el, name); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s is not a path", ErrTypeMismatch, name)
+	}
+	return d.SetTrait(el, name, pathData(commands))
+}
+
+// MatrixTrait returns the transform trait of the element, like getMatrixTrait in the uDOM.
+// Elements without a transform have the identity matrix.
+func (d *Document) MatrixTrait(el SvgElement, name string) (Matrix, error) {
+	value, err := d.Trait(el, name)
+	if err != nil {
+		return Matrix{}, err
+	}
+	if name != "transform" {
+		return Matrix{}, fmt.Errorf("%w: %s is not a transform", ErrTypeMismatch, name)
+	}
+	if value == "" {
+		return Identity, nil
+	}
+	return ParseTransform(value)
+}
+
+// SetMatrixTrait changes the transform trait of the element, like setMatrixTrait in the uDOM
+func (d *Document) SetMatrixTrait(el SvgElement, name string, m Matrix) error {
+	if name != "transform" {
+		if _, err := d.Trait(el, name); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s is not a transform", ErrTypeMismatch, name)
+	}
+	return d.SetTrait(el, name, formatMatrix(m))
+}
+
+// RGBColorTrait returns a color trait, like "fill" or "stroke", like getRGBColorTrait in the uDOM.
+// The color is nil if it is inherited, and fully transparent for "none".
+func (d *Document) RGBColorTrait(el SvgElement, name string) (color.Color, error) {
+	value, err := d.Trait(el, name)
+	if err != nil {
+		return nil, err
+	}
+	if name != "fill" && name != "stroke" {
+		return nil, fmt.Errorf("%w: %s is not a color", ErrTypeMismatch, name)
+	}
+	return parseFill(value), nil
+}
+
+// SetRGBColorTrait changes a color trait, like setRGBColorTrait in the uDOM.
+// A nil color is inherited from the parent elements.
+func (d *Document) SetRGBColorTrait(el SvgElement, name string, c color.Color) error {
+	if name != "fill" && name != "stroke" {
+		if _, err := d.Trait(el, name); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s is not a color", ErrTypeMismatch, name)
+	}
+	value, ok := formatColor(c)
+	if !ok {
+		value = "inherit"
+	}
+	return d.SetTrait(el, name, value)
+}
+
+// Parent returns the group that the element is in, or nil if the element is at the top level of the document
+// or in defs. ErrNotFound is returned if the element is not in the document.
+func (d *Document) Parent(el SvgElement) (*SvgGroup, error) {
+	if !d.contains(el) {
+		return nil, ErrNotFound
+	}
+	ancestors := d.ancestors(el)
+	if len(ancestors) == 0 {
+		return nil, nil
+	}
+	return ancestors[len(ancestors)-1], nil
+}
+
+// contains checks if the element is in the document, including in defs
+func (d *Document) contains(el SvgElement) bool {
+	var find func(elements []SvgElement) bool
+	find = func(elements []SvgElement) bool {
+		for _, child := range elements {
+			if child == el {
+				return true
+			}
+			if g, ok := child.(*SvgGroup); ok && find(g.Elements) {
+				return true
+			}
+		}
+		return false
+	}
+	return find(d.Elements) || find(d.Defs)
+}
+
+// children returns the list of elements of the parent, where a nil parent is the top level of the document
+func (d *Document) children(parent *SvgGroup) *[]SvgElement {
+	if parent == nil {
+		return &d.Elements
+	}
+	return &parent.Elements
+}
+
+// InsertBefore inserts the element into the parent group before ref, like insertBefore in the uDOM.
+// A nil parent is the top level of the document, and a nil ref appends the element at the end.
+// If the element is already in the document, it is moved.
+func (d *Document) InsertBefore(parent *SvgGroup, el, ref SvgElement) error {
+	if el == nil {
+		return ErrNotFound
+	}
+	if parent != nil && !d.contains(parent) {
+		return fmt.Errorf("%w: the parent is not in the document", ErrNotFound)
+	}
+	// The element can not be put inside itself
+	if g, ok := el.(*SvgGroup); ok && parent != nil {
+		if parent == g {
+			return ErrHierarchy
+		}
+		for _, ancestor := range d.ancestors(parent) {
+			if ancestor == g {
+				return ErrHierarchy
+			}
+		}
+	}
+	if ref != nil && indexOf(*d.children(parent), ref) < 0 {
+		return fmt.Errorf("%w: the reference element is not a child of the parent", ErrNotFound)
+	}
+	if el == ref {
+		return nil
+	}
+	if d.contains(el) {
+		if err := d.RemoveChild(nil, el); err != nil {
+			return err
+		}
+	}
+	children := d.children(parent)
+	i := len(*children)
+	if ref != nil {
+		i = indexOf(*children, ref)
+	}
+	*children = append(*children, nil)
+	copy((*children)[i+1:], (*children)[i:])
+	(*children)[i] = el
+	d.index()
+	return nil
+}
+
+// AppendChild adds the element at the end of the parent group, where a nil parent is the top level of the document
+func (d *Document) AppendChild(parent *SvgGroup, el SvgElement) error {
+	return d.InsertBefore(parent, el, nil)
+}
+
+// RemoveChild removes the element from the parent group, like removeChild in the uDOM. A nil parent
+// removes the element from wherever it is in the document, including defs.
+func (d *Document) RemoveChild(parent *SvgGroup, el SvgElement) error {
+	if parent == nil {
+		var err error
+		if parent, err = d.Parent(el); err != nil {
+			return err
+		}
+	}
+	lists := []*[]SvgElement{d.children(parent)}
+	if parent == nil {
+		lists = append(lists, &d.Defs)
+	}
+	for _, children := range lists {
+		if i := indexOf(*children, el); i >= 0 {
+			*children = append((*children)[:i], (*children)[i+1:]...)
+			d.index()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// indexOf returns the position of the element in the list, or -1
+func indexOf(elements []SvgElement, el SvgElement) int {
+	for i, child := range elements {
+		if child == el {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,162 @@
+package surrender
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraits(t *testing.T) {
+	doc, err := ParseFile("testdata/sprites.svg")
+	assert.NoError(t, err)
+	square := doc.ElementByID("square")
+
+	value, err := doc.Trait(square, "width")
+	assert.NoError(t, err)
+	assert.Equal(t, "8", value)
+	f, err := doc.FloatTrait(square, "x")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, f)
+
+	// The fill color is inherited from the group, so it is not set on the square
+	value, err = doc.Trait(square, "fill")
+	assert.NoError(t, err)
+	assert.Equal(t, "", value)
+	c, err := doc.RGBColorTrait(square, "fill")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	_, err = doc.Trait(square, "r")
+	assert.True(t, errors.Is(err, ErrNotSupported))
+	_, err = doc.FloatTrait(square, "visibility")
+	assert.True(t, errors.Is(err, ErrTypeMismatch))
+	assert.True(t, errors.Is(doc.SetTrait(square, "width", "wide"), ErrTypeMismatch))
+	assert.True(t, errors.Is(doc.SetTrait(square, "visibility", "maybe"), ErrTypeMismatch))
+
+	// Changes are made to the element in the document, which is then rendered again
+	assert.NoError(t, doc.SetFloatTrait(square, "width", 4))
+	assert.NoError(t, doc.SetRGBColorTrait(square, "fill", color.RGBA{0, 255, 0, 255}))
+	assert.Equal(t, 4, doc.ElementByID("square").(*SvgRectangle).Width)
+	img, err := RenderDocument(doc, RenderOptions{Scale: 0.5})
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA{0, 255, 0, 255}, img.RGBAAt(11, 3))
+	assert.Equal(t, gray, img.RGBAAt(15, 3))
+
+	assert.NoError(t, doc.SetRGBColorTrait(square, "fill", color.Transparent))
+	value, _ = doc.Trait(square, "fill")
+	assert.Equal(t, "none", value)
+
+	// Changing the id updates the index
+	assert.NoError(t, doc.SetTrait(square, "id", "box"))
+	assert.Nil(t, doc.ElementByID("square"))
+	assert.Same(t, square, doc.ElementByID("box"))
+}
+
+func TestTypedTraits(t *testing.T) {
+	doc, err := ParseFile("testdata/sprites.svg")
+	assert.NoError(t, err)
+	sprites := doc.ElementByID("sprites")
+
+	m, err := doc.MatrixTrait(sprites, "transform")
+	assert.NoError(t, err)
+	assert.Equal(t, Translate(10, 2), m)
+	m, err = doc.MatrixTrait(doc.ElementByID("background"), "transform")
+	assert.NoError(t, err)
+	assert.Equal(t, Identity, m)
+	assert.NoError(t, doc.SetMatrixTrait(sprites, "transform", Scale(2, 2)))
+	assert.Equal(t, Scale(2, 2), *sprites.(*SvgGroup).Transform)
+	_, err = doc.MatrixTrait(sprites, "fill")
+	assert.True(t, errors.Is(err, ErrTypeMismatch))
+
+	path := &SvgPath{}
+	assert.NoError(t, doc.AppendChild(nil, path))
+	parsed, err := ParsePath("M1 2 L3 4 Z")
+	assert.NoError(t, err)
+	assert.NoError(t, doc.SetPathTrait(path, "d", parsed.Commands))
+	got, err := doc.PathTrait(path, "d")
+	assert.NoError(t, err)
+	assert.Equal(t, parsed.Commands, got)
+}
+
+func TestInheritedTraits(t *testing.T) {
+	doc, err := ParseFile("testdata/sprites.svg")
+	assert.NoError(t, err)
+	sprites := doc.ElementByID("sprites")
+	square := doc.ElementByID("square")
+
+	assert.NoError(t, doc.SetTrait(square, "pointer-events", "none"))
+	assert.NoError(t, doc.SetTrait(sprites, "pointer-events", "all"))
+	assert.NoError(t, doc.SetTrait(sprites, "visibility", "hidden"))
+
+	// The children that inherited the values of the group follow it
+	value, _ := doc.Trait(doc.ElementByID("wide"), "visibility")
+	assert.Equal(t, "hidden", value)
+	value, _ = doc.Trait(doc.ElementByID("wide"), "pointer-events")
+	assert.Equal(t, "all", value)
+	value, _ = doc.Trait(square, "pointer-events")
+	assert.Equal(t, "none", value)
+
+	img, err := RenderDocument(doc, RenderOptions{Scale: 0.5})
+	assert.NoError(t, err)
+	assert.Equal(t, gray, img.RGBAAt(12, 4))
+
+	// Children that set a property themselves keep it, even if it was the same as the value of the group
+	doc, err = ParseBytes([]byte(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10">
+  <g id="g" visibility="visible">
+    <rect id="set" width="1" height="1" visibility="visible"/>
+    <g><rect id="inherited" width="1" height="1"/></g>
+  </g>
+</svg>`))
+	assert.NoError(t, err)
+	assert.NoError(t, doc.SetTrait(doc.ElementByID("g"), "visibility", "hidden"))
+	value, _ = doc.Trait(doc.ElementByID("set"), "visibility")
+	assert.Equal(t, "visible", value)
+	value, _ = doc.Trait(doc.ElementByID("inherited"), "visibility")
+	assert.Equal(t, "hidden", value)
+}
+
+func TestTreeMutation(t *testing.T) {
+	doc, err := ParseFile("testdata/sprites.svg")
+	assert.NoError(t, err)
+	sprites := doc.ElementByID("sprites").(*SvgGroup)
+	square := doc.ElementByID("square")
+	wide := doc.ElementByID("wide")
+	background := doc.ElementByID("background")
+
+	parent, err := doc.Parent(square)
+	assert.NoError(t, err)
+	assert.Same(t, sprites, parent)
+	parent, err = doc.Parent(background)
+	assert.NoError(t, err)
+	assert.Nil(t, parent)
+
+	// Move the square to the top level, before the background
+	assert.NoError(t, doc.InsertBefore(nil, square, background))
+	assert.Equal(t, []SvgElement{square, background, sprites}, doc.Elements)
+	assert.Equal(t, []SvgElement{wide}, sprites.Elements)
+
+	// A new element gets into the id index
+	dot := &SvgCircle{Common: Common{ID: "new"}, Cx: 1, Cy: 1, R: 1}
+	assert.NoError(t, doc.InsertBefore(sprites, dot, wide))
+	assert.Equal(t, []SvgElement{dot, wide}, sprites.Elements)
+	assert.Same(t, dot, doc.ElementByID("new"))
+
+	assert.NoError(t, doc.RemoveChild(sprites, dot))
+	assert.Nil(t, doc.ElementByID("new"))
+	assert.True(t, errors.Is(doc.RemoveChild(sprites, dot), ErrNotFound))
+	_, err = doc.Parent(dot)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// Elements in defs can be removed too
+	assert.NoError(t, doc.RemoveChild(nil, doc.ElementByID("dot")))
+	assert.Empty(t, doc.Defs)
+
+	// A group can not be put inside itself
+	inner := &SvgGroup{}
+	assert.NoError(t, doc.AppendChild(sprites, inner))
+	assert.True(t, errors.Is(doc.AppendChild(inner, sprites), ErrHierarchy))
+	assert.True(t, errors.Is(doc.AppendChild(sprites, sprites), ErrHierarchy))
+	assert.True(t, errors.Is(doc.InsertBefore(nil, dot, wide), ErrNotFound))
+}
@@ -0,0 +1,488 @@
+package surrender
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// svgNamespace is the namespace of SVG elements
+const svgNamespace = "http://www.w3.org/2000/svg"
+
+// tinyElements are the elements of the SVG Tiny 1.2 profile
+var tinyElements = map[string]bool{
+	"a": true, "animate": true, "animateColor": true, "animateMotion": true, "animateTransform": true,
+	"animation": true, "audio": true, "circle": true, "defs": true, "desc": true, "discard": true,
+	"ellipse": true, "font": true, "font-face": true, "font-face-src": true, "font-face-uri": true,
+	"foreignObject": true, "g": true, "glyph": true, "handler": true, "hkern": true, "image": true,
+	"line": true, "linearGradient": true, "listener": true, "metadata": true, "missing-glyph": true,
+	"mpath": true, "path": true, "polygon": true, "polyline": true, "prefetch": true, "radialGradient": true,
+	"rect": true, "script": true, "set": true, "solidColor": true, "stop": true, "svg": true, "switch": true,
+	"tbreak": true, "text": true, "textArea": true, "title": true, "tspan": true, "use": true, "video": true,
+}
+
+// tinyAttributes are the attributes and properties of the SVG Tiny 1.2 profile, on any element
+var tinyAttributes = map[string]bool{}
+
+func init() {
+	for _, names := range []string{
+		// Core, XML and XLink attributes
+		"id xml:id xml:base xml:lang xml:space class role rel rev typeof content datatype resource about property",
+		"xlink:href xlink:type xlink:role xlink:arcrole xlink:title xlink:show xlink:actuate",
+		// The svg element
+		"version baseProfile width height viewBox preserveAspectRatio snapshotTime playbackOrder timelineBegin zoomAndPan contentScriptType",
+		// Geometry and conditional processing
+		"x y cx cy r rx ry x1 y1 x2 y2 points d pathLength transform rotate editable type opacity target",
+		"requiredExtensions requiredFeatures requiredFormats requiredFonts systemLanguage externalResourcesRequired",
+		"focusable focusHighlight nav-next nav-prev nav-up nav-up-right nav-right nav-down-right nav-down nav-down-left nav-left nav-up-left",
+		// Properties
+		"audio-level buffered-rendering color color-rendering direction display display-align fill fill-opacity fill-rule",
+		"font-family font-size font-style font-variant font-weight image-rendering line-increment pointer-events",
+		"shape-rendering solid-color solid-opacity stop-color stop-opacity stroke stroke-dasharray stroke-dashoffset",
+		"stroke-linecap stroke-linejoin stroke-miterlimit stroke-opacity stroke-width text-align text-anchor",
+		"text-rendering unicode-bidi vector-effect viewport-fill viewport-fill-opacity visibility",
+		// Animation and media
+		"attributeName attributeType begin dur end min max restart repeatCount repeatDur calcMode values keyTimes",
+		"keySplines from to by additive accumulate path keyPoints origin initialVisibility transformBehavior overlay",
+		"syncBehavior syncTolerance syncMaster syncBehaviorDefault syncToleranceDefault",
+		// Gradients, events and fonts
+		"gradientUnits fx fy offset event observer handler phase propagate defaultAction",
+		"horiz-adv-x horiz-origin-x unicode glyph-name arabic-form lang u1 u2 g1 g2 k units-per-em ascent descent",
+		"alphabetic mathematical hanging ideographic underline-position underline-thickness overline-position",
+		"overline-thickness strikethrough-position strikethrough-thickness font-stretch slope cap-height x-height",
+		"widths bbox stemv stemh unicode-range panose-1 accent-height baseProfile",
+	} {
+		for _, name := range strings.Fields(names) {
+			tinyAttributes[name] = true
+		}
+	}
+}
+
+// requiredAttributes are the attributes that elements need, with how serious it is if they are missing.
+// Some of them have default values, but the element is not rendered without them.
+var requiredAttributes = map[string]map[string]Severity{
+	"rect":             {"width": SeverityWarning, "height": SeverityWarning},
+	"circle":           {"r": SeverityWarning},
+	"ellipse":          {"rx": SeverityWarning, "ry": SeverityWarning},
+	"path":             {"d": SeverityWarning},
+	"polyline":         {"points": SeverityWarning},
+	"polygon":          {"points": SeverityWarning},
+	"image":            {"xlink:href": SeverityError},
+	"use":              {"xlink:href": SeverityError},
+	"mpath":            {"xlink:href": SeverityError},
+	"animate":          {"attributeName": SeverityError},
+	"animateColor":     {"attributeName": SeverityError},
+	"animateTransform": {"attributeName": SeverityError},
+	"set":              {"attributeName": SeverityError, "to": SeverityError},
+}
+
+// tinyColors are the color keywords of SVG Tiny 1.2
+var tinyColors = map[string]bool{
+	"black": true, "silver": true, "gray": true, "white": true, "maroon": true, "red": true, "purple": true, "fuchsia": true,
+	"green": true, "lime": true, "olive": true, "yellow": true, "navy": true, "blue": true, "teal": true, "aqua": true,
+}
+
+// tinyValues are the values that enumerated attributes can have, in addition to "inherit" for the properties
+var tinyValues = map[string][]string{
+	"display":         {"inline", "block", "list-item", "run-in", "compact", "marker", "table", "inline-table", "table-row-group", "table-header-group", "table-footer-group", "table-row", "table-column-group", "table-column", "table-cell", "table-caption", "none"},
+	"visibility":      {"visible", "hidden", "collapse"},
+	"fill-rule":       {"nonzero", "evenodd"},
+	"pointer-events":  {"visiblePainted", "visibleFill", "visibleStroke", "visible", "painted", "fill", "stroke", "all", "none"},
+	"shape-rendering": {"auto", "optimizeSpeed", "crispEdges", "geometricPrecision"},
+	"text-rendering":  {"auto", "optimizeSpeed", "optimizeLegibility", "geometricPrecision"},
+	"image-rendering": {"auto", "optimizeSpeed", "optimizeQuality"},
+	"text-anchor":     {"start", "middle", "end"},
+	"text-align":      {"start", "center", "end"},
+	"display-align":   {"auto", "before", "center", "after"},
+	"stroke-linecap":  {"butt", "round", "square"},
+	"stroke-linejoin": {"miter", "round", "bevel"},
+	"font-style":      {"normal", "italic", "oblique"},
+	"xml:space":       {"default", "preserve"},
+	"calcMode":        {"discrete", "linear", "paced", "spline"},
+	"additive":        {"replace", "sum"},
+	"accumulate":      {"none", "sum"},
+	"restart":         {"always", "whenNotActive", "never"},
+	"zoomAndPan":      {"disable", "magnify"},
+}
+
+// preserveAspectRatioPattern matches the values of preserveAspectRatio
+var preserveAspectRatioPattern = regexp.MustCompile(`^(defer\s+)?(none|x(Min|Mid|Max)Y(Min|Mid|Max))(\s+(meet|slice))?$`)
+
+// colorPattern matches the hexadecimal and functional color values
+var colorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|rgb\(\s*\d+\s*,\s*\d+\s*,\s*\d+\s*\)|rgb\(\s*[\d.]+%\s*,\s*[\d.]+%\s*,\s*[\d.]+%\s*\))$`)
+
+// ValidateReader checks if a document conforms to the SVG Tiny 1.2 profile. It reports elements and attributes
+// that are not in the profile, like filter, clipPath or arcs in path data, missing attributes, invalid values
+// and references to ids that do not exist, with the position in the source. An error is only returned if
+// the document can not be read or is not well-formed XML.
+func ValidateReader(r io.Reader) ([]Diagnostic, error) {
+	r, err := decompress(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return nil, &DocumentError{Err: err}
+	}
+	root := doc.Root()
+	if root == nil || root.Tag != "svg" {
+		return []Diagnostic{{Severity: SeverityError, Line: 1, Column: 1, Message: "the root element is not svg"}}, nil
+	}
+	v := newValidator(elementPositions(doc, data))
+	v.validate(root)
+	return sortDiagnostics(v.diagnostics), nil
+}
+
+// ValidateFile checks if the given file conforms to the SVG Tiny 1.2 profile, like ValidateReader
+func ValidateFile(filename string) ([]Diagnostic, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ValidateReader(f)
+}
+
+// Validate checks if a parsed document, or a document that is made with a Builder, conforms to the SVG Tiny 1.2
+// profile. A parsed document is checked like ValidateReader checks its source, including the elements and
+// attributes that were not parsed. The document is also checked as WriteTo writes it, so that changes made
+// after parsing are checked too. The diagnostics for parsed elements have their path and position in the source.
+func Validate(doc *Document) []Diagnostic {
+	var diagnostics []Diagnostic
+	reported := make(map[string]bool)
+	if doc.source != nil {
+		v := newValidator(doc.source.positions)
+		v.validate(doc.source.tree.Root())
+		for _, d := range v.diagnostics {
+			diagnostics = append(diagnostics, d)
+			reported[d.String()] = true
+		}
+	}
+	wr := &writer{written: make(map[*etree.Element]SvgElement)}
+	tree, err := doc.tree(wr)
+	if err != nil {
+		return sortDiagnostics(append(diagnostics, Diagnostic{Severity: SeverityError, Message: err.Error()}))
+	}
+	v := newValidator(make(map[*etree.Element]position))
+	if doc.source != nil {
+		for e, el := range wr.written {
+			if loc, ok := doc.source.locations[el]; ok {
+				v.positions[e], v.paths[e] = loc.position, loc.path
+			}
+		}
+	}
+	v.validate(tree.Root())
+	// The problems that are in the source are only reported once
+	for _, d := range v.diagnostics {
+		if !reported[d.String()] {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	return sortDiagnostics(diagnostics)
+}
+
+// sortDiagnostics sorts diagnostics by their position in the source, and returns them
+func sortDiagnostics(diagnostics []Diagnostic) []Diagnostic {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return diagnostics
+}
+
+// validator holds the state that is used while validating a document
+type validator struct {
+	positions   map[*etree.Element]position
+	paths       map[*etree.Element]string // the paths of the elements that are not where elementPath finds them
+	ids         map[string]bool
+	diagnostics []Diagnostic
+}
+
+// newValidator returns a validator for a document with the given element positions
+func newValidator(positions map[*etree.Element]position) *validator {
+	return &validator{positions: positions, paths: make(map[*etree.Element]string), ids: make(map[string]bool)}
+}
+
+// validate checks the root svg element and all the elements in it
+func (v *validator) validate(root *etree.Element) {
+	v.collectIDs(root)
+	v.root(root)
+	v.element(root)
+}
+
+// report adds a diagnostic for an element, or for one of its attributes if attr is not nil
+func (v *validator) report(severity Severity, el *etree.Element, attr *etree.Attr, format string, args ...interface{}) {
+	pos := v.positions[el]
+	path, ok := v.paths[el]
+	if !ok {
+		path = elementPath(el)
+	}
+	d := Diagnostic{
+		Severity: severity,
+		Path:     path,
+		Element:  el.FullTag(),
+		Line:     pos.line,
+		Column:   pos.column,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if attr != nil {
+		d.Attribute, d.Value = attr.FullKey(), attr.Value
+	}
+	v.diagnostics = append(v.diagnostics, d)
+}
+
+// foreign checks if an element is in another namespace than SVG, where elements without a namespace are SVG
+func foreign(el *etree.Element) bool {
+	ns := el.NamespaceURI()
+	return ns != "" && ns != svgNamespace
+}
+
+// collectIDs finds the ids of all the elements, and reports ids that are used more than once
+func (v *validator) collectIDs(el *etree.Element) {
+	for _, name := range []string{"id", "xml:id"} {
+		if attr := el.SelectAttr(name); attr != nil {
+			if v.ids[attr.Value] && (name == "id" || el.SelectAttrValue("id", "") != attr.Value) {
+				v.report(SeverityError, el, attr, "the id %q is used more than once", attr.Value)
+			}
+			v.ids[attr.Value] = true
+		}
+	}
+	for _, child := range el.ChildElements() {
+		v.collectIDs(child)
+	}
+}
+
+// root checks the attributes that only the root svg element has
+func (v *validator) root(el *etree.Element) {
+	if el.NamespaceURI() != svgNamespace {
+		v.report(SeverityWarning, el, nil, "the svg element should be in the %s namespace", svgNamespace)
+	}
+	if attr := el.SelectAttr("version"); attr == nil || attr.Value != "1.2" {
+		v.report(SeverityWarning, el, attr, `the version should be "1.2"`)
+	}
+	if attr := el.SelectAttr("baseProfile"); attr == nil || attr.Value != "tiny" {
+		v.report(SeverityWarning, el, attr, `the baseProfile should be "tiny"`)
+	}
+}
+
+// element checks an element, its attributes and its children
+func (v *validator) element(el *etree.Element) {
+	if foreign(el) {
+		return
+	}
+	if !tinyElements[el.Tag] {
+		v.report(SeverityError, el, nil, "the %s element is not part of SVG Tiny 1.2", el.Tag)
+		return
+	}
+	required := requiredAttributes[el.Tag]
+	names := make([]string, 0, len(required))
+	for name := range required {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if el.SelectAttr(name) == nil {
+			v.report(required[name], el, nil, "the %s element has no %s attribute", el.Tag, name)
+		}
+	}
+	for i := range el.Attr {
+		v.attribute(el, &el.Attr[i])
+	}
+	switch el.Tag {
+	case "title", "desc", "metadata", "foreignObject", "script", "handler":
+		// Their contents are not SVG
+		return
+	}
+	for _, child := range el.ChildElements() {
+		v.element(child)
+	}
+}
+
+// attribute checks an attribute of an element
+func (v *validator) attribute(el *etree.Element, attr *etree.Attr) {
+	name := attr.FullKey()
+	switch {
+	case attr.Space == "xmlns" || name == "xmlns":
+		return
+	case attr.Space != "" && attr.Space != "xml" && attr.Space != "xlink":
+		// Attributes in other namespaces are allowed
+		return
+	case !tinyAttributes[name]:
+		v.report(SeverityError, el, attr, "the %s attribute is not part of SVG Tiny 1.2", name)
+		return
+	}
+	value := strings.TrimSpace(attr.Value)
+	if err := v.checkValue(el, name, value); err != nil {
+		v.report(SeverityError, el, attr, "invalid %s: %v", name, err)
+	}
+}
+
+// checkValue checks the value of an attribute, and returns what is wrong with it
+func (v *validator) checkValue(el *etree.Element, name, value string) error {
+	animation := isAnimationTag(el.Tag)
+	if values, ok := tinyValues[name]; ok {
+		for _, allowed := range append(values, "inherit") {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", value, strings.Join(values, ", "))
+	}
+	switch name {
+	case "x", "y", "cx", "cy", "x1", "y1", "x2", "y2":
+		if el.Tag == "svg" {
+			return nil
+		}
+		_, err := number(value)
+		return err
+	case "width", "height":
+		if el.Tag == "svg" {
+			if _, ok := parseAbsoluteLength(value); ok || strings.HasSuffix(value, "%") {
+				return nil
+			}
+			return fmt.Errorf("%q is not a length", value)
+		}
+		if el.Tag == "textArea" && value == "auto" {
+			return nil
+		}
+		return nonNegative(value)
+	case "r", "rx", "ry", "stroke-width":
+		if value == "inherit" {
+			return nil
+		}
+		return nonNegative(value)
+	case "font-size":
+		if f, err := number(value); value != "inherit" && (err != nil || f <= 0) {
+			return fmt.Errorf("%q is not a positive number", value)
+		}
+	case "line-increment":
+		if value == "auto" || value == "inherit" {
+			return nil
+		}
+		return nonNegative(value)
+	case "opacity", "fill-opacity", "stroke-opacity", "stop-opacity", "solid-opacity", "viewport-fill-opacity":
+		if f, err := number(value); value != "inherit" && (err != nil || f < 0 || f > 1) {
+			return fmt.Errorf("%q is not a number from 0 to 1", value)
+		}
+	case "fill":
+		if animation {
+			if value != "freeze" && value != "remove" {
+				return fmt.Errorf("%q is not freeze or remove", value)
+			}
+			return nil
+		}
+		return v.checkPaint(value)
+	case "stroke", "color", "stop-color", "solid-color", "viewport-fill":
+		return v.checkPaint(value)
+	case "transform":
+		_, err := ParseTransform(value)
+		return err
+	case "viewBox":
+		numbers, err := parseNumberList(value)
+		if err != nil || len(numbers) != 4 || numbers[2] < 0 || numbers[3] < 0 {
+			return fmt.Errorf("%q is not four numbers with a non-negative width and height", value)
+		}
+	case "preserveAspectRatio":
+		if !preserveAspectRatioPattern.MatchString(value) {
+			return fmt.Errorf("%q is not an alignment with meet or slice", value)
+		}
+	case "points":
+		numbers, err := parseNumberList(value)
+		if err != nil || len(numbers)%2 != 0 {
+			return fmt.Errorf("%q is not a list of coordinate pairs", value)
+		}
+	case "d", "path":
+		if strings.ContainsAny(value, "Aa") {
+			return errors.New("arcs (A and a) are not part of SVG Tiny 1.2 path data")
+		}
+		_, err := ParsePath(value)
+		return err
+	case "xlink:href":
+		if strings.HasPrefix(value, "#") && !v.ids[value[1:]] {
+			return fmt.Errorf("unresolved reference to %s", value)
+		}
+	case "dur", "repeatDur":
+		_, err := parseDuration(value)
+		return err
+	case "begin", "end":
+		_, err := parseTimeList(value)
+		return err
+	case "repeatCount":
+		if f, err := number(value); value != "indefinite" && (err != nil || f <= 0) {
+			return fmt.Errorf("%q is not a positive number or indefinite", value)
+		}
+	case "keyTimes", "keyPoints":
+		numbers, err := parseFloatList(value)
+		if err != nil {
+			return err
+		}
+		for _, f := range numbers {
+			if f < 0 || f > 1 {
+				return fmt.Errorf("%v is not from 0 to 1", f)
+			}
+		}
+	case "keySplines":
+		for _, spline := range splitList(value) {
+			numbers, err := parseNumberList(spline)
+			if err != nil || len(numbers) != 4 {
+				return fmt.Errorf("%q is not four numbers", spline)
+			}
+		}
+	case "type":
+		if el.Tag == "animateTransform" {
+			switch value {
+			case "translate", "scale", "rotate", "skewX", "skewY":
+			default:
+				return fmt.Errorf("%q is not translate, scale, rotate, skewX or skewY", value)
+			}
+		}
+	}
+	return nil
+}
+
+// checkPaint checks a fill, stroke or color value, including references to gradients and solid colors
+func (v *validator) checkPaint(value string) error {
+	switch {
+	case value == "none" || value == "currentColor" || value == "inherit" || tinyColors[value] || colorPattern.MatchString(value):
+		return nil
+	case strings.HasPrefix(value, "url(#") && strings.HasSuffix(value, ")"):
+		if id := value[5 : len(value)-1]; !v.ids[id] {
+			return fmt.Errorf("unresolved reference to #%s", id)
+		}
+		return nil
+	}
+	return fmt.Errorf("%q is not a color of SVG Tiny 1.2", value)
+}
+
+// number parses a number, without units
+func number(value string) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%q is not a number", value)
+	}
+	return f, nil
+}
+
+// nonNegative checks that the value is a number that is not negative
+func nonNegative(value string) error {
+	f, err := number(value)
+	if err != nil {
+		return err
+	}
+	if f < 0 {
+		return fmt.Errorf("%q is negative", value)
+	}
+	return nil
+}
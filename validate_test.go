@@ -0,0 +1,140 @@
+package surrender
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConforming(t *testing.T) {
+	for _, filename := range []string{"testdata/document.svg", "testdata/animation.svg", "testdata/textarea.svg", "testdata/circle.svgz"} {
+		diagnostics, err := ValidateFile(filename)
+		assert.NoError(t, err, filename)
+		assert.Empty(t, diagnostics, filename)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	diagnostics, err := ValidateFile("testdata/invalid.svg")
+	assert.NoError(t, err)
+
+	// find returns the first diagnostic for the element path and attribute
+	find := func(path, attribute string) *Diagnostic {
+		for i, d := range diagnostics {
+			if d.Path == path && d.Attribute == attribute {
+				return &diagnostics[i]
+			}
+		}
+		t.Errorf("no diagnostic for %s@%s", path, attribute)
+		return &Diagnostic{}
+	}
+
+	d := find("/svg", "")
+	assert.Equal(t, SeverityWarning, d.Severity)
+	assert.Equal(t, 2, d.Line)
+
+	d = find("/svg/defs[1]/filter[1]", "")
+	assert.Equal(t, SeverityError, d.Severity)
+	assert.Equal(t, 4, d.Line)
+	assert.Equal(t, 5, d.Column)
+	assert.Contains(t, d.Message, "not part of SVG Tiny 1.2")
+	assert.Equal(t, SeverityError, find("/svg/defs[1]/clipPath[1]", "").Severity)
+
+	assert.Contains(t, find("/svg/rect[1]", "width").Message, "negative")
+	assert.Contains(t, find("/svg/rect[1]", "fill").Message, "unresolved reference to #missing")
+	assert.Contains(t, find("/svg/rect[1]", "style").Message, "not part of SVG Tiny 1.2")
+	assert.Equal(t, 7, find("/svg/rect[1]", "style").Line)
+
+	d = find("/svg/circle[1]", "")
+	assert.Equal(t, SeverityWarning, d.Severity)
+	assert.Contains(t, d.Message, "no r attribute")
+	assert.Contains(t, find("/svg/circle[1]", "fill-opacity").Message, "from 0 to 1")
+
+	d = find("/svg/path[1]", "d")
+	assert.Equal(t, SeverityError, d.Severity)
+	assert.Contains(t, d.Message, "arcs")
+
+	assert.Contains(t, find("/svg/use[1]", "xlink:href").Message, "unresolved reference to #nowhere")
+	assert.Contains(t, find("/svg/rect[3]", "id").Message, "used more than once")
+	assert.Contains(t, find("/svg/rect[2]", "fill").Message, "not a color")
+	assert.Contains(t, find("/svg/rect[3]", "visibility").Message, "not one of")
+	assert.Contains(t, find("/svg/animate[1]", "fill").Message, "freeze or remove")
+
+	// The clipPath contents are not checked, since the clipPath is already invalid
+	for _, d := range diagnostics {
+		assert.False(t, strings.HasPrefix(d.Path, "/svg/defs[1]/clipPath[1]/"), d.String())
+	}
+}
+
+func TestValidateDocument(t *testing.T) {
+	// Parsed documents keep the positions of their elements
+	doc, err := ParseFile("testdata/invalid.svg")
+	assert.NoError(t, err)
+	var messages []string
+	for _, d := range Validate(doc) {
+		messages = append(messages, d.String())
+	}
+	assert.Contains(t, messages, `7:3: error: /svg/rect[1]@width: invalid width: "-4" is negative`)
+	assert.Contains(t, messages, `12:3: error: /svg/rect[3]@id: the id "dup" is used more than once`)
+	assert.Contains(t, messages, `13:3: error: /svg/animate[1]@fill: invalid fill: "blue" is not freeze or remove`)
+
+	// Documents that are made with a Builder have no positions, but have paths
+	doc, err = NewDocument(10, 10).Rect(0, 0, -1, 2, nil).ID("a").Circle(1, 1, 1, nil).ID("a").Build()
+	assert.NoError(t, err)
+	diagnostics := Validate(doc)
+	assert.Len(t, diagnostics, 2)
+	assert.Equal(t, "/svg/rect[1]", diagnostics[1].Path)
+	assert.Equal(t, "width", diagnostics[1].Attribute)
+	assert.Equal(t, 0, diagnostics[1].Line)
+
+	doc, err = ParseFile("testdata/document.svg")
+	assert.NoError(t, err)
+	assert.Empty(t, Validate(doc))
+}
+
+func TestValidateParsed(t *testing.T) {
+	// A parsed document gets the same diagnostics as its source, also for what the parser skips
+	data := `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" version="1.2" baseProfile="tiny">
+  <defs><filter id="f"/><clipPath id="c"><rect width="1" height="1"/></clipPath></defs>
+  <rect width="4" height="4" filter="url(#f)" clip-path="url(#c)"/>
+  <use xlink:href="#nowhere"/>
+</svg>`
+	for _, source := range []string{data, "testdata/invalid.svg"} {
+		var expected []Diagnostic
+		var doc *Document
+		var err error
+		if source == data {
+			expected, err = ValidateReader(strings.NewReader(data))
+			assert.NoError(t, err)
+			doc, err = ParseBytes([]byte(data))
+		} else {
+			expected, err = ValidateFile(source)
+			assert.NoError(t, err)
+			doc, err = ParseFile(source)
+		}
+		assert.NoError(t, err)
+		assert.NotEmpty(t, expected)
+		assert.Equal(t, expected, Validate(doc))
+	}
+
+	// Changes that are made after parsing are checked too
+	doc, err := ParseBytes([]byte(data))
+	assert.NoError(t, err)
+	doc.Elements[0].(*SvgRectangle).Width = -1
+	found := false
+	for _, d := range Validate(doc) {
+		found = found || d.Attribute == "width" && d.Path == "/svg/rect[1]"
+	}
+	assert.True(t, found)
+}
+
+func TestValidateNotWellFormed(t *testing.T) {
+	_, err := ValidateReader(strings.NewReader("<svg><rect></svg>"))
+	assert.Error(t, err)
+}
+
+func TestDiagnosticString(t *testing.T) {
+	d := Diagnostic{Severity: SeverityError, Path: "/svg/rect[1]", Attribute: "width", Line: 3, Column: 5, Message: "invalid width"}
+	assert.Equal(t, "3:5: error: /svg/rect[1]@width: invalid width", d.String())
+}
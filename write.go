@@ -0,0 +1,453 @@
+package surrender
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// WriteOptions holds the settings that are used when writing a document
+type WriteOptions struct {
+	// Indent is the indentation for each level of nested elements, like "  " or "\t".
+	// If it is empty, the elements are written without line breaks between them.
+	Indent string
+	// Precision is the largest number of decimals that are written for numbers that are not whole,
+	// like in transforms, the view box and animation key times. If it is 0, numbers are written exactly.
+	Precision int
+	// SortAttributes writes the attributes of each element in alphabetical order, instead of with the id
+	// first, followed by the geometry, the paint, the transform and the properties
+	SortAttributes bool
+	// MinimalPaths writes path data in the shortest form of FormatPathData, instead of with the commands as they are
+	MinimalPaths bool
+}
+
+// WriteTo writes the document as TinySVG 1.2 XML, indented with two spaces
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	return d.WriteWithOptions(w, WriteOptions{Indent: "  "})
+}
+
+// WriteWithOptions writes the document as TinySVG 1.2 XML, using the given options.
+// Inherited properties are only written where they change, and attributes with default values are left out.
+func (d *Document) WriteWithOptions(w io.Writer, options WriteOptions) (int64, error) {
+	doc, err := d.tree(&writer{options: options})
+	if err != nil {
+		return 0, err
+	}
+	return doc.WriteTo(w)
+}
+
+// tree returns the document as XML, as it is written with the settings of wr
+func (d *Document) tree(wr *writer) (*etree.Document, error) {
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+	doc.CreateCharData("\n")
+	root := doc.CreateElement("svg")
+	root.CreateAttr("xmlns", "http://www.w3.org/2000/svg")
+	root.CreateAttr("xmlns:xlink", "http://www.w3.org/1999/xlink")
+	root.CreateAttr("version", "1.2")
+	root.CreateAttr("baseProfile", "tiny")
+	root.CreateAttr("width", strconv.Itoa(d.Width))
+	root.CreateAttr("height", strconv.Itoa(d.Height))
+	if vb := d.ViewBox; vb != nil {
+		root.CreateAttr("viewBox", wr.numbers(" ", vb.X, vb.Y, vb.Width, vb.Height))
+	}
+	if d.PreserveAspectRatio != "" && d.PreserveAspectRatio != "xMidYMid meet" {
+		root.CreateAttr("preserveAspectRatio", d.PreserveAspectRatio)
+	}
+	if d.Title != "" {
+		root.CreateElement("title").SetText(d.Title)
+	}
+	if d.Description != "" {
+		root.CreateElement("desc").SetText(d.Description)
+	}
+	if d.Metadata != "" {
+		// The metadata is kept as XML, which is written as it is if it can be parsed
+		metadata := etree.NewDocument()
+		if err := metadata.ReadFromString("<metadata>" + d.Metadata + "</metadata>"); err == nil {
+			root.AddChild(metadata.Root())
+		} else {
+			root.CreateElement("metadata").SetText(d.Metadata)
+		}
+	}
+	if len(d.Defs) > 0 {
+		if err := wr.elements(root.CreateElement("defs"), d.Defs, Common{}); err != nil {
+			return nil, err
+		}
+	}
+	if err := wr.elements(root, d.Elements, Common{}); err != nil {
+		return nil, err
+	}
+	// The XLink namespace is only declared if it is used
+	if len(root.FindElements("//[@xlink:href]")) == 0 {
+		root.RemoveAttr("xmlns:xlink")
+	}
+	if wr.options.Indent != "" {
+		indent(root, "", wr.options.Indent)
+	}
+	doc.CreateCharData("\n")
+	return doc, nil
+}
+
+// indent adds line breaks and indentation before the child elements, except in the elements
+// where whitespace is part of the content
+func indent(el *etree.Element, prefix, step string) {
+	switch el.Tag {
+	case "text", "textArea", "metadata":
+		return
+	}
+	if len(el.ChildElements()) == 0 {
+		return
+	}
+	for i := len(el.Child) - 1; i >= 0; i-- {
+		if child, ok := el.Child[i].(*etree.Element); ok {
+			indent(child, prefix+step, step)
+			el.InsertChildAt(i, etree.NewCharData("\n"+prefix+step))
+		}
+	}
+	el.CreateCharData("\n" + prefix)
+}
+
+// writer holds the settings that are used while writing a document
+type writer struct {
+	options WriteOptions
+	written map[*etree.Element]SvgElement // the element that each XML element is written from, if not nil
+}
+
+// number formats a number with at most the configured number of decimals
+func (w *writer) number(f float64) string {
+	if w.options.Precision <= 0 {
+		return formatNumber(f)
+	}
+	s := strconv.FormatFloat(f, 'f', w.options.Precision, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// numbers formats the numbers with the given separator between them
+func (w *writer) numbers(sep string, fs ...float64) string {
+	strs := make([]string, len(fs))
+	for i, f := range fs {
+		strs[i] = w.number(f)
+	}
+	return strings.Join(strs, sep)
+}
+
+// matrix formats a transform, where a translation is written as translate
+func (w *writer) matrix(m Matrix) string {
+	if m.A == 1 && m.B == 0 && m.C == 0 && m.D == 1 {
+		return "translate(" + w.numbers(" ", m.E, m.F) + ")"
+	}
+	return "matrix(" + w.numbers(" ", m.A, m.B, m.C, m.D, m.E, m.F) + ")"
+}
+
+// paint writes a fill or stroke color, unless it is inherited. Colors like "#ff0000" are written as "#f00".
+func paint(e *etree.Element, name string, c color.Color) {
+	value, ok := formatColor(c)
+	if !ok {
+		return
+	}
+	if len(value) == 7 && value[1] == value[2] && value[3] == value[4] && value[5] == value[6] {
+		value = "#" + value[1:2] + value[3:4] + value[5:6]
+	}
+	e.CreateAttr(name, value)
+}
+
+// stroke writes the stroke color of a shape, and its width if there is a stroke and the width is not 1
+func (w *writer) stroke(e *etree.Element, c color.Color, width float64) {
+	paint(e, "stroke", c)
+	if c != nil && width != 1 {
+		e.CreateAttr("stroke-width", w.number(width))
+	}
+}
+
+// elements writes the elements as children of parent, where inherited holds the properties of the parent
+func (w *writer) elements(parent *etree.Element, elements []SvgElement, inherited Common) error {
+	for _, el := range elements {
+		if err := w.element(parent, el, inherited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// element writes an element as a child of parent
+func (w *writer) element(parent *etree.Element, el SvgElement, inherited Common) error {
+	if a, ok := el.(*SvgAnimation); ok {
+		w.record(w.animation(parent, *a), el)
+		return nil
+	}
+	a, ok := el.(animatable)
+	if !ok {
+		return fmt.Errorf("can not write %T", el)
+	}
+	c := a.common()
+	create := func(tag string) *etree.Element {
+		e := parent.CreateElement(tag)
+		if c.ID != "" {
+			e.CreateAttr("id", c.ID)
+		}
+		return e
+	}
+	var e *etree.Element
+	switch v := el.(type) {
+	case *SvgCircle:
+		e = create("circle")
+		e.CreateAttr("cx", strconv.Itoa(v.Cx))
+		e.CreateAttr("cy", strconv.Itoa(v.Cy))
+		e.CreateAttr("r", strconv.Itoa(v.R))
+		paint(e, "fill", v.Fill)
+		w.stroke(e, v.Stroke, v.StrokeWidth)
+	case *SvgRectangle:
+		e = create("rect")
+		e.CreateAttr("x", strconv.Itoa(v.X))
+		e.CreateAttr("y", strconv.Itoa(v.Y))
+		e.CreateAttr("width", strconv.Itoa(v.Width))
+		e.CreateAttr("height", strconv.Itoa(v.Height))
+		paint(e, "fill", v.Fill)
+		w.stroke(e, v.Stroke, v.StrokeWidth)
+	case *SvgLine:
+		e = create("line")
+		e.CreateAttr("x1", strconv.Itoa(v.X1))
+		e.CreateAttr("y1", strconv.Itoa(v.Y1))
+		e.CreateAttr("x2", strconv.Itoa(v.X2))
+		e.CreateAttr("y2", strconv.Itoa(v.Y2))
+		paint(e, "stroke", v.Stroke)
+	case *SvgPath:
+		e = create("path")
+		if w.options.MinimalPaths {
+			e.CreateAttr("d", FormatPathData(v.Commands, PathMinimal))
+		} else {
+			e.CreateAttr("d", pathData(v.Commands))
+		}
+		paint(e, "fill", v.Fill)
+		w.stroke(e, v.Stroke, v.StrokeWidth)
+	case *SvgGroup:
+		e = create("g")
+		paint(e, "fill", v.Fill)
+	case *SvgText:
+		e = create("text")
+		e.CreateAttr("x", strconv.Itoa(v.X))
+		e.CreateAttr("y", strconv.Itoa(v.Y))
+		paint(e, "fill", v.Fill)
+		if v.FontSize != defaultFontSize {
+			e.CreateAttr("font-size", strconv.Itoa(v.FontSize))
+		}
+		if v.Anchor != "" && v.Anchor != "start" {
+			e.CreateAttr("text-anchor", v.Anchor)
+		}
+	case *SvgTextArea:
+		e = create("textArea")
+		e.CreateAttr("x", strconv.Itoa(v.X))
+		e.CreateAttr("y", strconv.Itoa(v.Y))
+		if v.Width != AutoSize {
+			e.CreateAttr("width", strconv.Itoa(v.Width))
+		}
+		if v.Height != AutoSize {
+			e.CreateAttr("height", strconv.Itoa(v.Height))
+		}
+		paint(e, "fill", v.Fill)
+		if v.FontSize != defaultFontSize {
+			e.CreateAttr("font-size", strconv.Itoa(v.FontSize))
+		}
+		if v.LineIncrement != 0 {
+			e.CreateAttr("line-increment", strconv.Itoa(v.LineIncrement))
+		}
+		if v.DisplayAlign != "" && v.DisplayAlign != "auto" {
+			e.CreateAttr("display-align", v.DisplayAlign)
+		}
+		if v.TextAlign != "" && v.TextAlign != "start" {
+			e.CreateAttr("text-align", v.TextAlign)
+		}
+	case *SvgImage:
+		e = create("image")
+		e.CreateAttr("x", strconv.Itoa(v.X))
+		e.CreateAttr("y", strconv.Itoa(v.Y))
+		e.CreateAttr("width", strconv.Itoa(v.Width))
+		e.CreateAttr("height", strconv.Itoa(v.Height))
+		if v.PreserveAspectRatio != "" && v.PreserveAspectRatio != "xMidYMid meet" {
+			e.CreateAttr("preserveAspectRatio", v.PreserveAspectRatio)
+		}
+		if v.Opacity != 1 {
+			e.CreateAttr("opacity", w.number(v.Opacity))
+		}
+		if v.Href != "" {
+			e.CreateAttr("xlink:href", v.Href)
+		}
+	default:
+		return fmt.Errorf("can not write %T", el)
+	}
+	w.record(e, el)
+
+	if c.Transform != nil {
+		e.CreateAttr("transform", w.matrix(*c.Transform))
+	}
+	if c.Display == "none" {
+		e.CreateAttr("display", "none")
+	}
+	// Inherited properties are only written where the element sets them, or where they differ from the parent
+	for _, name := range []string{"visibility", "pointer-events", "fill-rule", "shape-rendering", "text-rendering", "image-rendering"} {
+		value, parentValue := *c.property(name), *inherited.property(name)
+		if value == parentValue && c.specified&propertyBits[name] == 0 {
+			continue
+		}
+		if value == "" {
+			value = propertyValues[name][0]
+		}
+		e.CreateAttr(name, value)
+	}
+
+	switch v := el.(type) {
+	case *SvgGroup:
+		if err := w.elements(e, v.Elements, c); err != nil {
+			return err
+		}
+	case *SvgText:
+		writeText(e, v.Text)
+	case *SvgTextArea:
+		writeText(e, v.Text)
+	}
+	for _, animation := range c.Animations {
+		w.animation(e, animation)
+	}
+	if w.options.SortAttributes {
+		e.SortAttrs()
+	}
+	return nil
+}
+
+// record remembers which element an XML element is written from, if the writer records them
+func (w *writer) record(e *etree.Element, el SvgElement) {
+	if w.written != nil {
+		w.written[e] = el
+	}
+}
+
+// writeText writes the text content of a text or textArea element, where "\n" becomes a tbreak element.
+// Whitespace that would be collapsed when parsing is preserved with xml:space.
+func writeText(e *etree.Element, text string) {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.Join(strings.Fields(line), " ") != line {
+			e.CreateAttr("xml:space", "preserve")
+		}
+		if i > 0 {
+			e.CreateElement("tbreak")
+		}
+		if line != "" {
+			e.CreateText(line)
+		}
+	}
+}
+
+// duration formats a clock value in seconds
+func duration(d time.Duration) string {
+	if d < 0 {
+		return "-" + duration(-d)
+	}
+	return formatNumber(d.Seconds()) + "s"
+}
+
+// times formats a begin or end attribute
+func times(ds []time.Duration) string {
+	strs := make([]string, len(ds))
+	for i, d := range ds {
+		strs[i] = duration(d)
+	}
+	return strings.Join(strs, ";")
+}
+
+// animation writes an animation element as a child of parent, leaving out the attributes that have default values,
+// and returns it
+func (w *writer) animation(parent *etree.Element, a SvgAnimation) *etree.Element {
+	e := parent.CreateElement(a.Tag)
+	if a.Target != "" {
+		e.CreateAttr("xlink:href", "#"+a.Target)
+	}
+	if a.AttributeName != "" {
+		e.CreateAttr("attributeName", a.AttributeName)
+	}
+	if a.Type != "" && a.Type != "translate" {
+		e.CreateAttr("type", a.Type)
+	}
+	switch {
+	case len(a.Begin) == 0:
+		e.CreateAttr("begin", "indefinite")
+	case len(a.Begin) > 1 || a.Begin[0] != 0:
+		e.CreateAttr("begin", times(a.Begin))
+	}
+	if a.Dur != Indefinite && a.Dur > 0 {
+		e.CreateAttr("dur", duration(a.Dur))
+	}
+	if len(a.End) > 0 {
+		e.CreateAttr("end", times(a.End))
+	}
+	switch {
+	case math.IsInf(a.RepeatCount, 1):
+		e.CreateAttr("repeatCount", "indefinite")
+	case a.RepeatCount > 0:
+		e.CreateAttr("repeatCount", formatNumber(a.RepeatCount))
+	}
+	switch {
+	case a.RepeatDur == Indefinite:
+		e.CreateAttr("repeatDur", "indefinite")
+	case a.RepeatDur > 0:
+		e.CreateAttr("repeatDur", duration(a.RepeatDur))
+	}
+	if a.Fill != "" && a.Fill != "remove" {
+		e.CreateAttr("fill", a.Fill)
+	}
+	if a.CalcMode != "" {
+		e.CreateAttr("calcMode", a.CalcMode)
+	}
+	if len(a.Values) > 0 {
+		e.CreateAttr("values", strings.Join(a.Values, ";"))
+	}
+	for _, attr := range []struct{ name, value string }{{"from", a.From}, {"to", a.To}, {"by", a.By}} {
+		if attr.value != "" {
+			e.CreateAttr(attr.name, attr.value)
+		}
+	}
+	if len(a.KeyTimes) > 0 {
+		e.CreateAttr("keyTimes", w.numbers(";", a.KeyTimes...))
+	}
+	if len(a.KeySplines) > 0 {
+		splines := make([]string, len(a.KeySplines))
+		for i, s := range a.KeySplines {
+			splines[i] = w.numbers(" ", s[:]...)
+		}
+		e.CreateAttr("keySplines", strings.Join(splines, ";"))
+	}
+	if len(a.KeyPoints) > 0 {
+		e.CreateAttr("keyPoints", w.numbers(";", a.KeyPoints...))
+	}
+	if a.Additive != "" && a.Additive != "replace" {
+		e.CreateAttr("additive", a.Additive)
+	}
+	if a.Accumulate != "" && a.Accumulate != "none" {
+		e.CreateAttr("accumulate", a.Accumulate)
+	}
+	if a.Path != nil {
+		e.CreateAttr("path", pathData(a.Path.Commands))
+	}
+	if a.Rotate != "" && a.Rotate != "0" {
+		e.CreateAttr("rotate", a.Rotate)
+	}
+	if a.MPath != "" {
+		e.CreateElement("mpath").CreateAttr("xlink:href", "#"+a.MPath)
+	}
+	if w.options.SortAttributes {
+		e.SortAttrs()
+	}
+	return e
+}
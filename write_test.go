@@ -0,0 +1,74 @@
+package surrender
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteRoundTrip(t *testing.T) {
+	filenames, err := filepath.Glob("testdata/*.svg*")
+	assert.NoError(t, err)
+	options := ParseOptions{ImageDir: "testdata", Mode: ParseStrict}
+	// The files that are meant to fail, and the errors they fail with
+	failing := map[string]string{
+		"testdata/invalid.svg": `7:3: error: /svg/rect[1]@width: "-4" is negative`,
+		"testdata/remote.svg":  "2:5: error: /svg/image[1]@xlink:href: remote images are not supported: https://example.com/image.png",
+	}
+	for _, filename := range filenames {
+		doc, err := ParseFileWithOptions(filename, options)
+		if expected, ok := failing[filename]; ok {
+			assert.EqualError(t, err, expected, filename)
+			continue
+		}
+		if !assert.NoError(t, err, filename) {
+			continue
+		}
+		var buf bytes.Buffer
+		_, err = doc.WriteTo(&buf)
+		assert.NoError(t, err, filename)
+		written, err := ParseWithOptions(&buf, options)
+		if !assert.NoError(t, err, filename) {
+			continue
+		}
+		// The written documents are always TinySVG 1.2, and their elements are elsewhere in the source
+		doc.Version, doc.BaseProfile = "1.2", "tiny"
+		doc.source, written.source = nil, nil
+		assert.Equal(t, doc, written, filename)
+	}
+}
+
+func TestWriteOptions(t *testing.T) {
+	m := Rotate(30)
+	doc := &Document{
+		Width:  20,
+		Height: 10,
+		Elements: []SvgElement{
+			&SvgGroup{Common: Common{ID: "g", Visibility: "hidden"}, Elements: []SvgElement{
+				&SvgRectangle{Common: Common{Transform: &m, Visibility: "hidden"}, Width: 4, Height: 2, Fill: red},
+				&SvgTextArea{Common: Common{Visibility: "visible"}, Width: AutoSize, Height: AutoSize, FontSize: defaultFontSize, Text: "a\nb"},
+			}},
+		},
+	}
+
+	var buf bytes.Buffer
+	_, err := doc.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" version="1.2" baseProfile="tiny" width="20" height="10">
+  <g id="g" visibility="hidden">
+    <rect x="0" y="0" width="4" height="2" fill="#f00" transform="matrix(0.8660254037844387 0.49999999999999994 -0.49999999999999994 0.8660254037844387 0 0)"/>
+    <textArea x="0" y="0" visibility="visible">a<tbreak/>b</textArea>
+  </g>
+</svg>
+`, buf.String())
+
+	buf.Reset()
+	_, err = doc.WriteWithOptions(&buf, WriteOptions{Precision: 3, SortAttributes: true})
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `<g id="g" visibility="hidden"><rect fill="#f00" height="2" transform="matrix(0.866 0.5 -0.5 0.866 0 0)" width="4" x="0" y="0"/>`)
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+}